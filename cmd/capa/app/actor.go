@@ -0,0 +1,193 @@
+package app
+
+import (
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+// actorOptions are the flags of the actor commands.
+type actorOptions struct {
+	clientOptions
+	actorType string
+	actorId   string
+}
+
+func (o *actorOptions) addFlags(flags *pflag.FlagSet) {
+	o.clientOptions.addFlags(flags)
+	flags.StringVar(&o.actorType, "type", "", "Type of the actor")
+	flags.StringVar(&o.actorId, "id", "", "Id of the actor")
+}
+
+func newActorCommand() *cobra.Command {
+	o := &actorOptions{}
+	c := &cobra.Command{
+		Use:   "actor",
+		Short: "Call the actors of a running sidecar.",
+		Long:  "Call the actors of a running sidecar. The sidecars without an actor runtime fail the calls as unimplemented.",
+	}
+	o.addFlags(c.PersistentFlags())
+	c.MarkPersistentFlagRequired("type")
+	c.MarkPersistentFlagRequired("id")
+
+	state := &cobra.Command{
+		Use:   "state",
+		Short: "Read the state of an actor.",
+	}
+	state.AddCommand(newActorStateGetCommand(o))
+
+	reminder := &cobra.Command{
+		Use:   "reminder",
+		Short: "Manage the reminders of an actor.",
+	}
+	reminder.AddCommand(
+		newActorReminderRegisterCommand(o),
+		newActorReminderUnregisterCommand(o),
+		newActorReminderRenameCommand(o))
+
+	c.AddCommand(newActorInvokeCommand(o), state, reminder)
+	return c
+}
+
+func newActorInvokeCommand(o *actorOptions) *cobra.Command {
+	var method string
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "invoke",
+		Short: "Invoke a method of an actor.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.InvokeActor(ctx, &runtimev1pb.InvokeActorRequest{
+					ActorType: o.actorType,
+					ActorId:   o.actorId,
+					Method:    method,
+					Data:      data,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					writeData(w, res.Data)
+				})
+			})
+		},
+	}
+	c.Flags().StringVar(&method, "method", "", "Method to invoke")
+	c.MarkFlagRequired("method")
+	payload.addFlags(c.Flags())
+	return c
+}
+
+func newActorStateGetCommand(o *actorOptions) *cobra.Command {
+	var key string
+	c := &cobra.Command{
+		Use:   "get",
+		Short: "Get a state value of an actor.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.GetActorState(ctx, &runtimev1pb.GetActorStateRequest{
+					ActorType: o.actorType,
+					ActorId:   o.actorId,
+					Key:       key,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					writeData(w, res.Data)
+				})
+			})
+		},
+	}
+	c.Flags().StringVar(&key, "key", "", "Key of the state")
+	c.MarkFlagRequired("key")
+	return c
+}
+
+func newActorReminderRegisterCommand(o *actorOptions) *cobra.Command {
+	req := &runtimev1pb.RegisterActorReminderRequest{}
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "register",
+		Short: "Register a reminder of an actor.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			req.ActorType, req.ActorId, req.Data = o.actorType, o.actorId, data
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.RegisterActorReminder(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, nil)
+			})
+		},
+	}
+	flags := c.Flags()
+	flags.StringVar(&req.Name, "name", "", "Name of the reminder")
+	flags.StringVar(&req.DueTime, "due-time", "", "Time of the first reminder, as a duration or a RFC3339 time")
+	flags.StringVar(&req.Period, "period", "", "Period of the reminder, as a duration or a ISO 8601 period")
+	flags.StringVar(&req.Ttl, "ttl", "", "Time after which the reminder expires, as a duration or a RFC3339 time")
+	c.MarkFlagRequired("name")
+	payload.addFlags(flags)
+	return c
+}
+
+func newActorReminderUnregisterCommand(o *actorOptions) *cobra.Command {
+	req := &runtimev1pb.UnregisterActorReminderRequest{}
+	c := &cobra.Command{
+		Use:   "unregister",
+		Short: "Unregister a reminder of an actor.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			req.ActorType, req.ActorId = o.actorType, o.actorId
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.UnregisterActorReminder(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, nil)
+			})
+		},
+	}
+	c.Flags().StringVar(&req.Name, "name", "", "Name of the reminder")
+	c.MarkFlagRequired("name")
+	return c
+}
+
+func newActorReminderRenameCommand(o *actorOptions) *cobra.Command {
+	req := &runtimev1pb.RenameActorReminderRequest{}
+	c := &cobra.Command{
+		Use:   "rename",
+		Short: "Rename a reminder of an actor.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			req.ActorType, req.ActorId = o.actorType, o.actorId
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.RenameActorReminder(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, nil)
+			})
+		},
+	}
+	c.Flags().StringVar(&req.OldName, "old-name", "", "Current name of the reminder")
+	c.Flags().StringVar(&req.NewName, "new-name", "", "New name of the reminder")
+	c.MarkFlagRequired("old-name")
+	c.MarkFlagRequired("new-name")
+	return c
+}
@@ -0,0 +1,378 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+// The RPCs the bench can drive.
+const (
+	benchSayHello    = "say_hello"
+	benchInvokeActor = "invoke_actor"
+)
+
+// benchOptions are the flags of the bench command.
+type benchOptions struct {
+	clientOptions
+
+	rpc         string
+	qps         float64
+	concurrency int
+	payloadSize int
+	duration    time.Duration
+	resultsFile string
+
+	actorType string
+	actorId   string
+	method    string
+}
+
+// benchResult is the result of a bench, written as json to compare releases.
+type benchResult struct {
+	RPC         string  `json:"rpc"`
+	Concurrency int     `json:"concurrency"`
+	TargetQPS   float64 `json:"target_qps,omitempty"`
+	PayloadSize int     `json:"payload_size"`
+	// Duration is the duration of the bench in seconds.
+	Duration   float64          `json:"duration"`
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"errors"`
+	ErrorCodes map[string]int64 `json:"error_codes,omitempty"`
+	// Throughput is the number of successful requests per second.
+	Throughput float64 `json:"throughput"`
+	// Latency is the latency of the successful requests in milliseconds. With a target
+	// QPS, it is measured from when the call was due, not from when a caller was free
+	// to make it, so the calls delayed by slow ones count their wait.
+	Latency latencyStats `json:"latency"`
+	// Histogram counts the successful requests per latency bucket.
+	Histogram []histogramBucket `json:"histogram"`
+}
+
+// histogramBucket counts the requests slower than the previous bucket and at most as
+// slow as LE milliseconds. The last bucket has no LE and counts the slower requests.
+type histogramBucket struct {
+	LE    float64 `json:"le,omitempty"`
+	Count int64   `json:"count"`
+}
+
+// histogramBounds are the upper bounds of the histogram buckets in milliseconds.
+var histogramBounds = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+type latencyStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+func newBenchCommand() *cobra.Command {
+	o := &benchOptions{}
+	c := &cobra.Command{
+		Use:   "bench",
+		Short: "Load test a running sidecar.",
+		Long: "Bench calls SayHello, or InvokeActor, on a running sidecar for a duration, at a target " +
+			"QPS or as fast as the concurrent callers can, and reports the throughput, the errors and " +
+			"the latency percentiles.",
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+			// The calls share the connection, and --timeout applies to each of them.
+			return o.call(func(_ context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := o.bench(client)
+				if err != nil {
+					return err
+				}
+				if err := o.writeResultsFile(res); err != nil {
+					return err
+				}
+				return o.printResult(c.OutOrStdout(), res)
+			})
+		},
+	}
+	flags := c.Flags()
+	o.clientOptions.addFlags(flags)
+	flags.StringVar(&o.rpc, "rpc", benchSayHello, "RPC to call: say_hello or invoke_actor")
+	flags.Float64Var(&o.qps, "qps", 0, "Target number of calls per second, as many as possible when 0")
+	flags.IntVar(&o.concurrency, "concurrency", 10, "Number of concurrent callers")
+	flags.IntVar(&o.payloadSize, "payload-size", 0, "Size of the payload of each call in bytes")
+	flags.DurationVar(&o.duration, "duration", 10*time.Second, "Duration of the bench")
+	flags.StringVar(&o.resultsFile, "results-file", "", "File the result is also written to as json, to compare runs")
+	flags.StringVar(&o.actorType, "actor-type", "", "Type of the invoked actor")
+	flags.StringVar(&o.actorId, "actor-id", "", "Id of the invoked actor")
+	flags.StringVar(&o.method, "method", "", "Method of the invoked actor")
+	return c
+}
+
+func (o *benchOptions) validate() error {
+	switch o.rpc {
+	case benchSayHello:
+	case benchInvokeActor:
+		if o.actorType == "" || o.actorId == "" || o.method == "" {
+			return fmt.Errorf("--actor-type, --actor-id and --method are required for %s", benchInvokeActor)
+		}
+	default:
+		return fmt.Errorf("invalid rpc %q", o.rpc)
+	}
+	if o.qps < 0 {
+		return fmt.Errorf("--qps must not be negative")
+	}
+	if o.concurrency <= 0 {
+		return fmt.Errorf("--concurrency must be positive")
+	}
+	if o.payloadSize < 0 {
+		return fmt.Errorf("--payload-size must not be negative")
+	}
+	if o.duration <= 0 {
+		return fmt.Errorf("--duration must be positive")
+	}
+	return nil
+}
+
+// caller returns the function making one call of the bench.
+func (o *benchOptions) caller(client runtimev1pb.RuntimeClient) (func(ctx context.Context) error, error) {
+	payload := make([]byte, o.payloadSize)
+	switch o.rpc {
+	case benchInvokeActor:
+		req := &runtimev1pb.InvokeActorRequest{ActorType: o.actorType, ActorId: o.actorId, Method: o.method, Data: payload}
+		return func(ctx context.Context) error {
+			_, err := client.InvokeActor(ctx, req)
+			return err
+		}, nil
+	default:
+		data, err := anypb.New(wrapperspb.Bytes(payload))
+		if err != nil {
+			return nil, err
+		}
+		req := &runtimev1pb.SayHelloRequest{ServiceName: "bench", Name: "bench", Data: data}
+		return func(ctx context.Context) error {
+			_, err := client.SayHello(ctx, req)
+			return err
+		}, nil
+	}
+}
+
+// bench runs the concurrent callers for the duration. When a QPS is set, the callers
+// wait for the tokens of a limiter, which carry when their call was due.
+func (o *benchOptions) bench(client runtimev1pb.RuntimeClient) (*benchResult, error) {
+	call, err := o.caller(client)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.duration)
+	defer cancel()
+
+	var tokens chan time.Time
+	if o.qps > 0 {
+		tokens = make(chan time.Time)
+		go limit(ctx, o.qps, tokens)
+	}
+
+	var (
+		lock       sync.Mutex
+		latencies  []time.Duration
+		errorCodes = map[string]int64{}
+		wg         sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < o.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []time.Duration
+			localErrors := map[string]int64{}
+			for {
+				callStart := time.Now()
+				if tokens != nil {
+					select {
+					case callStart = <-tokens:
+					case <-ctx.Done():
+					}
+				}
+				if ctx.Err() != nil {
+					break
+				}
+				callCtx, callCancel := context.WithTimeout(ctx, o.timeout)
+				err := call(callCtx)
+				latency := time.Since(callStart)
+				callCancel()
+				// The calls cut by the end of the bench are not counted.
+				if ctx.Err() != nil {
+					break
+				}
+				if err != nil {
+					localErrors[status.Code(err).String()]++
+					continue
+				}
+				local = append(local, latency)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			latencies = append(latencies, local...)
+			for code, count := range localErrors {
+				errorCodes[code] += count
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	res := &benchResult{
+		RPC:         o.rpc,
+		Concurrency: o.concurrency,
+		TargetQPS:   o.qps,
+		PayloadSize: o.payloadSize,
+		Duration:    elapsed.Seconds(),
+		Throughput:  float64(len(latencies)) / elapsed.Seconds(),
+		Latency:     percentiles(latencies),
+		Histogram:   histogram(latencies),
+	}
+	for _, count := range errorCodes {
+		res.Errors += count
+	}
+	if res.Errors > 0 {
+		res.ErrorCodes = errorCodes
+	}
+	res.Requests = int64(len(latencies)) + res.Errors
+	return res, nil
+}
+
+// limit sends the tokens of the qps until the context is done, each with the time it
+// was due. A token waits for a free caller, so the rate is also bounded by the
+// concurrency.
+func limit(ctx context.Context, qps float64, tokens chan<- time.Time) {
+	start := time.Now()
+	sent := 0
+	for {
+		due := int(time.Since(start).Seconds() * qps)
+		for ; sent < due; sent++ {
+			select {
+			case tokens <- start.Add(time.Duration(float64(sent+1) / qps * float64(time.Second))):
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// percentiles returns the latency percentiles, by nearest rank, in milliseconds.
+func percentiles(latencies []time.Duration) latencyStats {
+	if len(latencies) == 0 {
+		return latencyStats{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	at := func(p float64) float64 {
+		rank := int(math.Ceil(p*float64(len(latencies)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return ms(latencies[rank])
+	}
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	return latencyStats{
+		Min:  ms(latencies[0]),
+		Mean: ms(total / time.Duration(len(latencies))),
+		P50:  at(0.5),
+		P90:  at(0.9),
+		P99:  at(0.99),
+		P999: at(0.999),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+}
+
+// histogram counts the latencies per bucket of histogramBounds.
+func histogram(latencies []time.Duration) []histogramBucket {
+	buckets := make([]histogramBucket, len(histogramBounds)+1)
+	for i, bound := range histogramBounds {
+		buckets[i].LE = bound
+	}
+	for _, latency := range latencies {
+		ms := float64(latency) / float64(time.Millisecond)
+		buckets[sort.SearchFloat64s(histogramBounds, ms)].Count++
+	}
+	return buckets
+}
+
+func (o *benchOptions) writeResultsFile(res *benchResult) error {
+	if o.resultsFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.resultsFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write the results file: %v", err)
+	}
+	return nil
+}
+
+func (o *benchOptions) printResult(w io.Writer, res *benchResult) error {
+	if o.output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	fmt.Fprintf(w, "rpc:          %s\n", res.RPC)
+	fmt.Fprintf(w, "concurrency:  %d\n", res.Concurrency)
+	if res.TargetQPS > 0 {
+		fmt.Fprintf(w, "target qps:   %g\n", res.TargetQPS)
+	}
+	fmt.Fprintf(w, "payload size: %d bytes\n", res.PayloadSize)
+	fmt.Fprintf(w, "duration:     %.2fs\n", res.Duration)
+	fmt.Fprintf(w, "requests:     %d\n", res.Requests)
+	fmt.Fprintf(w, "errors:       %d\n", res.Errors)
+	codes := make([]string, 0, len(res.ErrorCodes))
+	for code := range res.ErrorCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %s: %d\n", code, res.ErrorCodes[code])
+	}
+	fmt.Fprintf(w, "throughput:   %.1f req/s\n", res.Throughput)
+	l := res.Latency
+	fmt.Fprintf(w, "latency (ms): min %.3f, mean %.3f, max %.3f\n", l.Min, l.Mean, l.Max)
+	fmt.Fprintf(w, "  p50 %.3f, p90 %.3f, p99 %.3f, p999 %.3f\n", l.P50, l.P90, l.P99, l.P999)
+	fmt.Fprintf(w, "histogram (ms):\n")
+	for _, bucket := range res.Histogram {
+		if bucket.Count == 0 {
+			continue
+		}
+		if bucket.LE == 0 {
+			fmt.Fprintf(w, "  > %g: %d\n", histogramBounds[len(histogramBounds)-1], bucket.Count)
+			continue
+		}
+		fmt.Fprintf(w, "  <= %g: %d\n", bucket.LE, bucket.Count)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	buckets := histogram([]time.Duration{
+		50 * time.Microsecond,
+		time.Millisecond,
+		1100 * time.Microsecond,
+		20 * time.Second,
+	})
+	if len(buckets) != len(histogramBounds)+1 {
+		t.Fatalf("expected %d buckets, got %d", len(histogramBounds)+1, len(buckets))
+	}
+	counts := map[float64]int64{}
+	for _, bucket := range buckets {
+		counts[bucket.LE] += bucket.Count
+	}
+	// The upper bound of a bucket is inclusive, the last bucket has none.
+	if counts[0.1] != 1 || counts[1] != 1 || counts[2.5] != 1 || counts[0] != 1 {
+		t.Fatalf("unexpected histogram %+v", buckets)
+	}
+}
+
+func TestLimitTokensCarryDueTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokens := make(chan time.Time)
+	go limit(ctx, 100, tokens)
+
+	first := <-tokens
+	// Let the due tokens pile up behind a slow caller.
+	time.Sleep(100 * time.Millisecond)
+	previous := first
+	for i := 0; i < 5; i++ {
+		due := <-tokens
+		if gap := due.Sub(previous); gap < 10*time.Millisecond-time.Microsecond || gap > 10*time.Millisecond+time.Microsecond {
+			t.Fatalf("expected the tokens to be due every 10ms, got %s", gap)
+		}
+		previous = due
+	}
+	if time.Since(previous) < 40*time.Millisecond {
+		t.Fatalf("expected the delayed tokens to keep their due time, got %s ago", time.Since(previous))
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+func newBindingCommand() *cobra.Command {
+	o := &clientOptions{}
+	c := &cobra.Command{
+		Use:   "binding",
+		Short: "Call the bindings of a running sidecar.",
+	}
+	o.addFlags(c.PersistentFlags())
+	c.AddCommand(newBindingInvokeCommand(o))
+	return c
+}
+
+func newBindingInvokeCommand(o *clientOptions) *cobra.Command {
+	var (
+		name      string
+		operation string
+		metadata  map[string]string
+	)
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "invoke",
+		Short: "Invoke an operation of an output binding.",
+		Long: "Invoke an operation of an output binding. The data of the response is written as is, " +
+			"and its metadata to stderr.",
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.InvokeBinding(ctx, &runtimev1pb.InvokeBindingRequest{
+					Name:      name,
+					Operation: operation,
+					Data:      data,
+					Metadata:  metadata,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					writeBindingMetadata(c.ErrOrStderr(), res.Metadata)
+					writeData(w, res.Data)
+				})
+			})
+		},
+	}
+	c.Flags().StringVar(&name, "name", "", "Name of the binding")
+	c.Flags().StringVar(&operation, "operation", "", "Operation of the binding, like get or create")
+	c.Flags().StringToStringVarP(&metadata, "metadata", "m", nil, "Metadata of the request, as key=value pairs")
+	c.MarkFlagRequired("name")
+	c.MarkFlagRequired("operation")
+	payload.addFlags(c.Flags())
+	return c
+}
+
+// writeBindingMetadata writes the metadata of a response sorted by key.
+func writeBindingMetadata(w io.Writer, metadata map[string]string) {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s: %s\n", key, metadata[key])
+	}
+}
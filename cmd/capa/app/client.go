@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	grpc_go "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/runtime"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// clientOptions are the flags of the commands talking to a running sidecar.
+type clientOptions struct {
+	address string
+	output  string
+	timeout time.Duration
+}
+
+func (o *clientOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.address, "address", fmt.Sprintf("127.0.0.1:%d", runtime.DefaultRuntimePort),
+		"Address of the runtime gRPC API of the sidecar")
+	flags.StringVarP(&o.output, "output", "o", outputText, "Output format: text or json")
+	flags.DurationVar(&o.timeout, "timeout", 10*time.Second, "Timeout of the call")
+}
+
+// call calls the sidecar with a client of its runtime API.
+func (o *clientOptions) call(fn func(ctx context.Context, client runtimev1pb.RuntimeClient) error) error {
+	if o.output != outputText && o.output != outputJSON {
+		return fmt.Errorf("invalid output format %q", o.output)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	conn, err := grpc_go.DialContext(ctx, o.address, grpc_go.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", o.address, err)
+	}
+	defer conn.Close()
+	err = fn(ctx, runtimev1pb.NewRuntimeClient(conn))
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("the sidecar at %s doesn't implement this call: %s", o.address, status.Convert(err).Message())
+	}
+	return err
+}
+
+// print writes the response as json, or calls text to write it as text.
+func (o *clientOptions) print(c *cobra.Command, res proto.Message, text func(w io.Writer)) error {
+	w := c.OutOrStdout()
+	if o.output == outputJSON {
+		data, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(res)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	if text != nil {
+		text(w)
+	}
+	return nil
+}
+
+// payloadOptions are the flags of the commands sending a payload.
+type payloadOptions struct {
+	data     string
+	dataFile string
+}
+
+func (o *payloadOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.data, "data", "d", "", "Payload of the call")
+	flags.StringVarP(&o.dataFile, "data-file", "f", "", "File holding the payload of the call, - for stdin")
+}
+
+// payload returns the payload of the flags, empty when none is given.
+func (o *payloadOptions) payload(c *cobra.Command) ([]byte, error) {
+	if o.data != "" && o.dataFile != "" {
+		return nil, fmt.Errorf("--data and --data-file are mutually exclusive")
+	}
+	switch o.dataFile {
+	case "":
+		return []byte(o.data), nil
+	case "-":
+		return io.ReadAll(c.InOrStdin())
+	default:
+		return os.ReadFile(o.dataFile)
+	}
+}
+
+// writeData writes the data of a response as is, ending it with a newline.
+func writeData(w io.Writer, data []byte) {
+	w.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		io.WriteString(w, "\n")
+	}
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	grpc_go "google.golang.org/grpc"
+
+	"group.rxcloud/capa/pkg/grpc"
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/proxy"
+)
+
+// serveRuntime serves the runtime API of a sidecar without components, and returns its address.
+func serveRuntime(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc_go.NewServer()
+	api := grpc.NewAPI("app", func() *proxy.Node { return nil }, nil, nil, nil, nil, func() {})
+	runtimev1pb.RegisterRuntimeServer(server, api)
+	go server.Serve(l)
+	t.Cleanup(server.Stop)
+	return l.Addr().String()
+}
+
+// execute runs the capa command with the args and the stdin, and returns its output.
+func execute(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	root := NewRootCommand()
+	root.SetArgs(args)
+	root.SetIn(strings.NewReader(stdin))
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestPayload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(file, []byte("from file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		options  payloadOptions
+		expected string
+		err      bool
+	}{
+		{name: "none", expected: ""},
+		{name: "flag", options: payloadOptions{data: "from flag"}, expected: "from flag"},
+		{name: "file", options: payloadOptions{dataFile: file}, expected: "from file"},
+		{name: "stdin", options: payloadOptions{dataFile: "-"}, expected: "from stdin"},
+		{name: "both", options: payloadOptions{data: "from flag", dataFile: file}, err: true},
+		{name: "missing file", options: payloadOptions{dataFile: file + ".missing"}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.SetIn(strings.NewReader("from stdin"))
+			data, err := c.options.payload(cmd)
+			if (err != nil) != c.err {
+				t.Fatalf("expected error=%v, got %v", c.err, err)
+			}
+			if string(data) != c.expected {
+				t.Fatalf("expected %q, got %q", c.expected, data)
+			}
+		})
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	for data, expected := range map[string]string{"": "", "value": "value\n", "value\n": "value\n"} {
+		w := &bytes.Buffer{}
+		writeData(w, []byte(data))
+		if w.String() != expected {
+			t.Errorf("expected %q written for %q, got %q", expected, data, w.String())
+		}
+	}
+}
+
+func TestMetadataSetAndGet(t *testing.T) {
+	address := serveRuntime(t)
+
+	if _, err := execute(t, "blue\n", "metadata", "set", "--address", address, "--key", "color", "-f", "-"); err != nil {
+		t.Fatalf("metadata set failed: %v", err)
+	}
+
+	out, err := execute(t, "", "metadata", "get", "--address", address)
+	if err != nil {
+		t.Fatalf("metadata get failed: %v", err)
+	}
+	if !strings.Contains(out, "id: app\n") || !strings.Contains(out, "extended metadata:\n  color: blue\n") {
+		t.Fatalf("unexpected text output:\n%s", out)
+	}
+
+	out, err = execute(t, "", "metadata", "get", "--address", address, "-o", "json")
+	if err != nil {
+		t.Fatalf("metadata get failed: %v", err)
+	}
+	res := struct {
+		Id               string            `json:"id"`
+		ExtendedMetadata map[string]string `json:"extendedMetadata"`
+	}{}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json output %q: %v", out, err)
+	}
+	if res.Id != "app" || res.ExtendedMetadata["color"] != "blue" {
+		t.Fatalf("unexpected json output %+v", res)
+	}
+}
+
+func TestInvalidOutput(t *testing.T) {
+	_, err := execute(t, "", "metadata", "get", "--address", serveRuntime(t), "-o", "yaml")
+	if err == nil || !strings.Contains(err.Error(), "invalid output format") {
+		t.Fatalf("expected the output format to be rejected, got %v", err)
+	}
+}
+
+func TestActorCallsUnimplemented(t *testing.T) {
+	address := serveRuntime(t)
+	calls := [][]string{
+		{"actor", "invoke", "--method", "hello"},
+		{"actor", "state", "get", "--key", "counter"},
+		{"actor", "reminder", "register", "--name", "tick", "--period", "1m"},
+		{"actor", "reminder", "unregister", "--name", "tick"},
+		{"actor", "reminder", "rename", "--old-name", "tick", "--new-name", "tock"},
+	}
+	for _, args := range calls {
+		args = append(args, "--address", address, "--type", "counter", "--id", "1")
+		_, err := execute(t, "", args...)
+		if err == nil || !strings.Contains(err.Error(), "doesn't implement this call") {
+			t.Errorf("expected %q to fail as unimplemented, got %v", strings.Join(args[:3], " "), err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"group.rxcloud/capa/pkg/runtime"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKey matches the names of the config values that are redacted.
+var secretKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private_?key|api_?key|access_?key)`)
+
+func newConfigCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "config",
+		Short: "Check and show the runtime config of the sidecar.",
+	}
+	c.AddCommand(newConfigValidateCommand(), newConfigViewCommand())
+	return c
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	o := &sidecarOptions{}
+	c := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the runtime config.",
+		Long: "Validate loads the runtime config like the sidecar does, with the same environment and " +
+			"flags, and reports all the errors of its sections with their position in the config file.",
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			cfg, err := o.effectiveConfig(c.Flags())
+			if err != nil {
+				return err
+			}
+			var positions runtime.Positions
+			if o.configFile != "" {
+				data, err := os.ReadFile(o.configFile)
+				if err != nil {
+					return err
+				}
+				positions = runtime.ParsePositions(data)
+			}
+
+			// The errors of the extend configs are located at their section. The values
+			// missing from the file, or set by the environment or the flags, have no position.
+			position := func(path string) (runtime.Position, bool) {
+				if strings.HasPrefix(path, "extends.") {
+					return positions.Lookup(path)
+				}
+				pos, ok := positions[path]
+				return pos, ok
+			}
+
+			// The errors are listed in file order, the ones without a position last.
+			errs := cfg.ValidateAll()
+			sort.SliceStable(errs, func(i, j int) bool {
+				pi, oki := position(errs[i].Path)
+				pj, okj := position(errs[j].Path)
+				if oki != okj {
+					return oki
+				}
+				return pi.Line < pj.Line || (pi.Line == pj.Line && pi.Column < pj.Column)
+			})
+			w := c.OutOrStdout()
+			errCount := 0
+			for _, err := range errs {
+				prefix := ""
+				if err.Warning {
+					prefix = "warning: "
+				} else {
+					errCount++
+				}
+				if pos, ok := position(err.Path); ok {
+					fmt.Fprintf(w, "%s:%s: %s%v\n", o.configFile, pos, prefix, err)
+				} else {
+					fmt.Fprintf(w, "%s%v\n", prefix, err)
+				}
+			}
+			if errCount > 0 {
+				return fmt.Errorf("the config has %d errors", errCount)
+			}
+			fmt.Fprintln(w, "the config is valid")
+			return nil
+		},
+	}
+	o.addFlags(c.Flags())
+	return c
+}
+
+func newConfigViewCommand() *cobra.Command {
+	o := &sidecarOptions{}
+	var effective bool
+	c := &cobra.Command{
+		Use:   "view",
+		Short: "Show the runtime config with its secrets redacted.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := o.viewConfig(c.Flags(), effective)
+			if err != nil {
+				return err
+			}
+			return writeRedacted(c.OutOrStdout(), data)
+		},
+	}
+	c.Flags().BoolVar(&effective, "effective", false,
+		"Show the effective config, with the environment, the flags and the defaults applied")
+	o.addFlags(c.Flags())
+	return c
+}
+
+// viewConfig returns the config file, or the effective config, as json.
+func (o *sidecarOptions) viewConfig(flags *pflag.FlagSet, effective bool) ([]byte, error) {
+	if effective {
+		cfg, err := o.effectiveConfig(flags)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(cfg)
+	}
+	if err := o.applyEnv(flags); err != nil {
+		return nil, err
+	}
+	if o.configFile == "" {
+		return nil, fmt.Errorf("--config is required, or --effective")
+	}
+	cfg, err := runtime.LoadRuntimeConfig(o.configFile)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cfg)
+}
+
+// writeRedacted writes the json document indented, with its secret values redacted.
+func writeRedacted(w io.Writer, data []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(redact(doc), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// redact replaces the values of the secret keys. Component metadata written as a list
+// of {"name": ..., "value": ...} has the values of its secret names redacted too.
+func redact(doc interface{}) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, isObject := value.(map[string]interface{}); !isObject && secretKey.MatchString(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redact(value)
+		}
+		if name, ok := v["name"].(string); ok && secretKey.MatchString(name) {
+			if _, ok := v["value"]; ok {
+				v["value"] = redacted
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return doc
+}
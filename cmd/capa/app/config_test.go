@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		name     string
+		doc      string
+		expected string
+	}{
+		{
+			name:     "top level keys",
+			doc:      `{"password": "p", "api_key": 1, "AccessKey": true, "name": "app"}`,
+			expected: `{"password": "[REDACTED]", "api_key": "[REDACTED]", "AccessKey": "[REDACTED]", "name": "app"}`,
+		},
+		{
+			name:     "nested keys",
+			doc:      `{"extends": {"proxy": {"jwt": {"providers": [{"issuer": "i", "client_secret": "s"}]}}}}`,
+			expected: `{"extends": {"proxy": {"jwt": {"providers": [{"issuer": "i", "client_secret": "[REDACTED]"}]}}}}`,
+		},
+		{
+			name:     "objects under secret keys are walked",
+			doc:      `{"secrets": {"store": "local", "token": "t"}}`,
+			expected: `{"secrets": {"store": "local", "token": "[REDACTED]"}}`,
+		},
+		{
+			name:     "lists under secret keys",
+			doc:      `{"tokens": ["a", "b"]}`,
+			expected: `{"tokens": "[REDACTED]"}`,
+		},
+		{
+			name: "metadata lists",
+			doc: `{"components": [{"name": "redis", "metadata": [
+				{"name": "redisHost", "value": "localhost:6379"},
+				{"name": "redisPassword", "value": "p"},
+				{"name": "accessToken"}
+			]}]}`,
+			expected: `{"components": [{"name": "redis", "metadata": [
+				{"name": "redisHost", "value": "localhost:6379"},
+				{"name": "redisPassword", "value": "[REDACTED]"},
+				{"name": "accessToken"}
+			]}]}`,
+		},
+		{
+			name:     "metadata values that are objects",
+			doc:      `{"metadata": [{"name": "privateKey", "value": {"pem": "k"}}]}`,
+			expected: `{"metadata": [{"name": "privateKey", "value": "[REDACTED]"}]}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var doc, expected interface{}
+			if err := json.Unmarshal([]byte(c.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(redact(doc))
+			want, _ := json.Marshal(expected)
+			if string(got) != string(want) {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
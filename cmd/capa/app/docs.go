@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// The formats of the generated docs.
+const (
+	docsMarkdown = "markdown"
+	docsMan      = "man"
+	docsYAML     = "yaml"
+)
+
+func newDocsCommand(root *cobra.Command) *cobra.Command {
+	var (
+		dir     string
+		formats []string
+	)
+	c := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate the reference docs of the capa commands.",
+		Long:  "Docs generates the reference of all the capa commands and their flags as markdown, man pages or yaml.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			// The generated docs don't carry the date, so that they only change with the commands.
+			root.DisableAutoGenTag = true
+			for _, format := range formats {
+				var err error
+				switch format {
+				case docsMarkdown:
+					err = doc.GenMarkdownTree(root, dir)
+				case docsMan:
+					err = doc.GenManTree(root, &doc.GenManHeader{
+						Title:   "CAPA",
+						Section: "1",
+						Manual:  "Capa Manual",
+						Source:  "Capa",
+					}, dir)
+				case docsYAML:
+					err = doc.GenYamlTree(root, dir)
+				default:
+					err = fmt.Errorf("unknown format %q", format)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(c.OutOrStdout(), "generated the %v docs in %s\n", formats, dir)
+			return nil
+		},
+	}
+	c.Flags().StringVarP(&dir, "dir", "d", "docs", "Directory the docs are generated in")
+	c.Flags().StringSliceVarP(&formats, "format", "f", []string{docsMarkdown, docsMan, docsYAML},
+		"Formats of the docs: markdown, man and yaml")
+	return c
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestFlagsHaveUsage(t *testing.T) {
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		check := func(flag *pflag.Flag) {
+			if flag.Usage == "" {
+				t.Errorf("flag --%s of %q has no usage text", flag.Name, c.CommandPath())
+			}
+		}
+		c.LocalFlags().VisitAll(check)
+		c.PersistentFlags().VisitAll(check)
+		for _, sub := range c.Commands() {
+			visit(sub)
+		}
+	}
+	visit(NewRootCommand())
+}
+
+func TestDocs(t *testing.T) {
+	dir := t.TempDir()
+	root := NewRootCommand()
+	root.SetArgs([]string{"docs", "--dir", dir})
+	root.SetOut(&testWriter{t})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"capa_sidecar.md", "capa-sidecar.1", "capa_sidecar.yaml", "capa_actor_reminder_register.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing doc: %v", err)
+		}
+	}
+}
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"group.rxcloud/capa/pkg/proxy"
+)
+
+func newInitCommand() *cobra.Command {
+	rc := proxy.DefaultRedirectConfig()
+	var clean, dryRun bool
+	c := &cobra.Command{
+		Use:   "init",
+		Short: "Redirect the traffic of the app to the Capa proxy with iptables.",
+		Long: "Init sets up the iptables nat chains redirecting the inbound connections of the app ports, " +
+			"and the outbound connections when enabled, to the proxy. It runs once in the network namespace " +
+			"of the app, as root or with NET_ADMIN. The redirect bypass mode of the proxy removes and " +
+			"restores the jumps to these chains.",
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if dryRun {
+				if err := rc.Validate(); err != nil {
+					return err
+				}
+				for _, rule := range rc.Rules() {
+					fmt.Fprintf(c.OutOrStdout(), "iptables %s\n", strings.Join(rule, " "))
+				}
+				return nil
+			}
+			if clean {
+				proxy.CleanRedirect()
+				return nil
+			}
+			return proxy.SetupRedirect(rc)
+		},
+	}
+	flags := c.Flags()
+	flags.IntVar(&rc.ProxyPort, "proxy-port", rc.ProxyPort, "Port the connections are redirected to")
+	flags.IntSliceVar(&rc.InboundPorts, "inbound-ports", nil, "Ports of the app whose inbound connections are redirected")
+	flags.BoolVar(&rc.Outbound, "outbound", false, "Redirect the outbound connections, except those of the proxy and to loopback")
+	flags.IntVar(&rc.ProxyUID, "proxy-uid", 0, "Uid the proxy runs as, required with --outbound")
+	flags.IntSliceVar(&rc.ExcludeOutboundPorts, "exclude-outbound-ports", nil, "Destination ports of the outbound connections not redirected")
+	flags.BoolVar(&clean, "clean", false, "Remove the redirect instead")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the iptables commands instead of running them")
+	return c
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+func newMetadataCommand() *cobra.Command {
+	o := &clientOptions{}
+	c := &cobra.Command{
+		Use:   "metadata",
+		Short: "Read and write the metadata of a running sidecar.",
+	}
+	o.addFlags(c.PersistentFlags())
+	c.AddCommand(newMetadataGetCommand(o), newMetadataSetCommand(o))
+	return c
+}
+
+func newMetadataGetCommand(o *clientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get",
+		Short: "Get the metadata of the sidecar.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.GetMetadata(ctx, &emptypb.Empty{})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					writeMetadata(w, res)
+				})
+			})
+		},
+	}
+}
+
+func writeMetadata(w io.Writer, res *runtimev1pb.GetMetadataResponse) {
+	fmt.Fprintf(w, "id: %s\n", res.Id)
+	fmt.Fprintf(w, "version: %s\n", res.RuntimeVersion)
+	if node := res.Node; node != nil {
+		fmt.Fprintf(w, "node:\n")
+		fmt.Fprintf(w, "  type: %s\n", node.Type)
+		fmt.Fprintf(w, "  pod: %s/%s\n", node.PodNamespace, node.PodName)
+		fmt.Fprintf(w, "  ips: %s\n", strings.Join(node.IpAddresses, ", "))
+		if l := node.Locality; l != nil {
+			fmt.Fprintf(w, "  locality: %s/%s/%s\n", l.Region, l.Zone, l.SubZone)
+		}
+		fmt.Fprintf(w, "  app: %s, env: %s, cloud: %s\n", node.AppId, node.Env, node.Cloud)
+		fmt.Fprintf(w, "  capabilities: %s\n", strings.Join(node.Capabilities, ", "))
+	}
+	for _, count := range res.ActiveActorsCount {
+		fmt.Fprintf(w, "actors %s: %d\n", count.Type, count.Count)
+	}
+	keys := make([]string, 0, len(res.ExtendedMetadata))
+	for key := range res.ExtendedMetadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		fmt.Fprintf(w, "extended metadata:\n")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s: %s\n", key, res.ExtendedMetadata[key])
+	}
+}
+
+func newMetadataSetCommand(o *clientOptions) *cobra.Command {
+	var key string
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "set",
+		Short: "Set a value of the extended metadata of the sidecar.",
+		Long:  "Set a value of the extended metadata of the sidecar. The value is the payload of the call.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			value, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.SetMetadata(ctx, &runtimev1pb.SetMetadataRequest{
+					Key: key,
+					// The value of a file or of stdin usually ends with a newline.
+					Value: strings.TrimSuffix(string(value), "\n"),
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, nil)
+			})
+		},
+	}
+	c.Flags().StringVar(&key, "key", "", "Key of the metadata")
+	c.MarkFlagRequired("key")
+	payload.addFlags(c.Flags())
+	return c
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+func newPubSubCommand() *cobra.Command {
+	o := &clientOptions{}
+	c := &cobra.Command{
+		Use:   "pubsub",
+		Short: "Publish to the topics of a running sidecar.",
+	}
+	o.addFlags(c.PersistentFlags())
+	c.AddCommand(newPubSubPublishCommand(o))
+	return c
+}
+
+func newPubSubPublishCommand(o *clientOptions) *cobra.Command {
+	var (
+		pubsubName  string
+		topic       string
+		contentType string
+		metadata    map[string]string
+	)
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "publish",
+		Short: "Publish a message to a topic.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.PublishEvent(ctx, &runtimev1pb.PublishEventRequest{
+					PubsubName:      pubsubName,
+					Topic:           topic,
+					Data:            data,
+					DataContentType: contentType,
+					Metadata:        metadata,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(io.Writer) {})
+			})
+		},
+	}
+	c.Flags().StringVar(&pubsubName, "pubsub", "", "Name of the pubsub")
+	c.Flags().StringVar(&topic, "topic", "", "Topic of the message")
+	c.Flags().StringVar(&contentType, "content-type", "", "Content type of the message, like application/json")
+	c.Flags().StringToStringVarP(&metadata, "metadata", "m", nil, "Metadata of the message, as key=value pairs")
+	c.MarkFlagRequired("pubsub")
+	c.MarkFlagRequired("topic")
+	payload.addFlags(c.Flags())
+	return c
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// grpcPortEnv tells the app the port of the sidecar gRPC API.
+const grpcPortEnv = "CAPA_GRPC_PORT"
+
+const (
+	sidecarLogPrefix = "== SIDECAR == "
+	appLogPrefix     = "== APP == "
+
+	// readyTimeout is how long the sidecar may take to accept connections.
+	readyTimeout = 10 * time.Second
+)
+
+func newRunCommand() *cobra.Command {
+	o := &sidecarOptions{}
+	c := &cobra.Command{
+		Use:   "run [flags] -- <app command> [args...]",
+		Short: "Run an app together with a local Capa Sidecar.",
+		Long: "Run starts a local sidecar, waits until it is ready, then starts the app with " +
+			grpcPortEnv + " set. The signals are forwarded to the app. The sidecar is shut down " +
+			"when the app exits, and capa exits with the exit code of the app.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			if c.ArgsLenAtDash() != 0 {
+				return fmt.Errorf("the app command must follow --")
+			}
+			code, err := o.run(c, args)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				os.Exit(code)
+			}
+			return nil
+		},
+	}
+	o.addFlags(c.Flags())
+	return c
+}
+
+// run runs the sidecar and the app, and returns the exit code of the app.
+func (o *sidecarOptions) run(c *cobra.Command, args []string) (int, error) {
+	out := &lineWriter{out: os.Stdout}
+	log.SetOutput(out.prefixed(sidecarLogPrefix))
+
+	// Catch the signals before starting anything: those received until the app starts
+	// stop the sidecar, the later ones are forwarded to the app, which the sidecar
+	// outlives.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	rt, cfg, err := o.startRuntime(c.Flags())
+	if err != nil {
+		return 0, err
+	}
+	sidecar := cfg.SidecarManagement
+	shutdown := func() {
+		rt.Shutdown(sidecar.GracefulShutdownDuration)
+	}
+
+	address := net.JoinHostPort(sidecar.APIListenAddresses[0], strconv.Itoa(sidecar.RuntimePort))
+	if err := waitUntilReady(address, readyTimeout, sigs); err != nil {
+		shutdown()
+		return 0, err
+	}
+
+	app := exec.Command(args[0], args[1:]...)
+	app.Env = append(os.Environ(), fmt.Sprintf("%s=%d", grpcPortEnv, sidecar.RuntimePort))
+	app.Stdin = os.Stdin
+	appOut := out.prefixed(appLogPrefix)
+	app.Stdout, app.Stderr = appOut, appOut
+
+	if err := app.Start(); err != nil {
+		shutdown()
+		return 0, fmt.Errorf("failed to start the app: %v", err)
+	}
+	log.Infof("started the app %q, pid %d", args[0], app.Process.Pid)
+	go func() {
+		for sig := range sigs {
+			app.Process.Signal(sig)
+		}
+	}()
+
+	err = app.Wait()
+	appOut.Flush()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+		// A process killed by a signal exits like a shell reports it.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+	} else if err != nil {
+		shutdown()
+		return 0, err
+	}
+	log.Infof("the app exited with code %d", code)
+	shutdown()
+	return code, nil
+}
+
+// waitUntilReady waits until the address accepts connections, unless a signal is received.
+func waitUntilReady(address string, timeout time.Duration, sigs <-chan os.Signal) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case sig := <-sigs:
+			return fmt.Errorf("received %s before the app started", sig)
+		default:
+		}
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the sidecar is not ready on %s after %s: %v", address, timeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// lineWriter writes whole lines to out, so that the lines of several streams interleave
+// without mixing.
+type lineWriter struct {
+	sync.Mutex
+	out io.Writer
+}
+
+func (w *lineWriter) prefixed(prefix string) *prefixWriter {
+	return &prefixWriter{lines: w, prefix: prefix}
+}
+
+// prefixWriter prefixes each line of a stream.
+type prefixWriter struct {
+	lines  *lineWriter
+	prefix string
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// Flush writes the last line even if it doesn't end with a newline.
+func (w *prefixWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.writeLine(append(w.partial, '\n'))
+		w.partial = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.lines.Lock()
+	defer w.lines.Unlock()
+	io.WriteString(w.lines.out, w.prefix)
+	w.lines.out.Write(line)
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"group.rxcloud/capa/pkg/cmd"
+	"group.rxcloud/capa/pkg/proxy"
+	"group.rxcloud/capa/pkg/runtime"
+)
+
+// sidecarOptions are the flags of the sidecar. They override the environment, which
+// overrides the config file.
+type sidecarOptions struct {
+	configFile string
+	logLevel   string
+
+	app     runtime.AppConfig
+	sidecar runtime.SidecarConfig
+}
+
+func newSidecarCommand() *cobra.Command {
+	o := &sidecarOptions{}
+	c := &cobra.Command{
+		Use:   "sidecar",
+		Short: "Start Capa Sidecar.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(c *cobra.Command, args []string) error {
+			flags := c.Flags()
+			cmd.PrintFlags(flags)
+
+			rt, cfg, err := o.startRuntime(flags)
+			if err != nil {
+				return err
+			}
+
+			// On SIGHUP, reload the proxy config from the config file.
+			go o.reloadOnHangup(rt)
+
+			// On SIGINT or SIGTERM, shut the runtime down gracefully.
+			go cmd.WaitSignalFunc(func() {
+				rt.Shutdown(cfg.SidecarManagement.GracefulShutdownDuration)
+			})
+			return rt.WaitUntilShutdown()
+		},
+	}
+	o.addFlags(c.Flags())
+	return c
+}
+
+func (o *sidecarOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.configFile, "config", "c", "", "Runtime config file in json, overridden by the flags")
+	flags.StringVar(&o.logLevel, "log-level", "info", "Log level: trace, debug, info, warn, error, fatal or panic")
+	flags.StringVar(&o.app.AppId, "app-id", "", "Id of the app")
+	flags.StringVar(&o.app.Env, "env", "", "Environment of the app")
+	flags.StringVar(&o.app.Cloud, "cloud", "", "Cloud the app runs on")
+	flags.IntVar(&o.sidecar.RuntimePort, "runtime-port", runtime.DefaultRuntimePort, "Port of the runtime gRPC API")
+	flags.IntVar(&o.sidecar.RuntimeCallbackPort, "runtime-callback-port", runtime.DefaultRuntimeCallbackPort,
+		"Port the app serves the runtime callbacks on")
+	flags.IntVar(&o.sidecar.HTTPPort, "http-port", 0, "Port of the runtime HTTP API, disabled when 0")
+	flags.StringSliceVar(&o.sidecar.APIListenAddresses, "api-listen-addresses", runtime.DefaultAPIListenAddresses,
+		"Addresses the runtime APIs listen on")
+	flags.DurationVar(&o.sidecar.GracefulShutdownDuration, "shutdown-grace", runtime.DefaultGracefulShutdownDuration,
+		"How long outstanding operations may take on shutdown")
+}
+
+// applyEnv sets the flags not given on the command line from their environment
+// variables, CAPA_ and the flag name in upper snake case, like CAPA_APP_ID.
+func (o *sidecarOptions) applyEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed {
+			return
+		}
+		if value, ok := os.LookupEnv(flagEnv(flag.Name)); ok {
+			if setErr := flags.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %v", flagEnv(flag.Name), setErr)
+			}
+		}
+	})
+	return err
+}
+
+func flagEnv(name string) string {
+	return "CAPA_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// runtimeConfig returns the validated effective config.
+func (o *sidecarOptions) runtimeConfig(flags *pflag.FlagSet) (*runtime.CapaRuntimeConfig, error) {
+	cfg, err := o.effectiveConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, err := range cfg.ValidateAll() {
+		if err.Warning {
+			log.Warnf("[Capa.runtime.args] config %v", err)
+		}
+	}
+	return cfg, nil
+}
+
+// effectiveConfig returns the config file overridden by the environment, then by the
+// flags. The defaults of the flags fill the values missing in the file.
+func (o *sidecarOptions) effectiveConfig(flags *pflag.FlagSet) (*runtime.CapaRuntimeConfig, error) {
+	if err := o.applyEnv(flags); err != nil {
+		return nil, err
+	}
+	cfg := &runtime.CapaRuntimeConfig{}
+	if o.configFile != "" {
+		var err error
+		if cfg, err = runtime.LoadRuntimeConfig(o.configFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.AppManagement == nil {
+		cfg.AppManagement = &runtime.AppConfig{}
+	}
+	if cfg.SidecarManagement == nil {
+		cfg.SidecarManagement = &runtime.SidecarConfig{}
+	}
+
+	useFlag := func(name string, unset bool) bool {
+		return flags.Changed(name) || unset
+	}
+	app, sidecar := cfg.AppManagement, cfg.SidecarManagement
+	if useFlag("app-id", app.AppId == "") {
+		app.AppId = o.app.AppId
+	}
+	if useFlag("env", app.Env == "") {
+		app.Env = o.app.Env
+	}
+	if useFlag("cloud", app.Cloud == "") {
+		app.Cloud = o.app.Cloud
+	}
+	if useFlag("runtime-port", sidecar.RuntimePort == 0) {
+		sidecar.RuntimePort = o.sidecar.RuntimePort
+	}
+	if useFlag("runtime-callback-port", sidecar.RuntimeCallbackPort == 0) {
+		sidecar.RuntimeCallbackPort = o.sidecar.RuntimeCallbackPort
+	}
+	if useFlag("http-port", sidecar.HTTPPort == 0) {
+		sidecar.HTTPPort = o.sidecar.HTTPPort
+	}
+	if useFlag("api-listen-addresses", len(sidecar.APIListenAddresses) == 0) {
+		sidecar.APIListenAddresses = o.sidecar.APIListenAddresses
+	}
+	if useFlag("shutdown-grace", sidecar.GracefulShutdownDuration == 0) {
+		sidecar.GracefulShutdownDuration = o.sidecar.GracefulShutdownDuration
+	}
+	return cfg, nil
+}
+
+// startRuntime sets the log level and runs the runtime of the effective config.
+func (o *sidecarOptions) startRuntime(flags *pflag.FlagSet) (*runtime.CapaRuntime, *runtime.CapaRuntimeConfig, error) {
+	cfg, err := o.runtimeConfig(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	level, err := log.ParseLevel(o.logLevel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q", o.logLevel)
+	}
+	log.SetLevel(level)
+	rt := runtime.NewCapaRuntime(cfg)
+	if err := rt.Run(); err != nil {
+		return nil, nil, err
+	}
+	return rt, cfg, nil
+}
+
+// reloadOnHangup applies the proxy config of the config file to the running proxy on
+// every SIGHUP. The other sections of the config need a restart.
+func (o *sidecarOptions) reloadOnHangup(rt *runtime.CapaRuntime) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	for range sigs {
+		if o.configFile == "" {
+			log.Warnf("[Capa.runtime] received SIGHUP without a config file to reload")
+			continue
+		}
+		cfg, err := runtime.LoadRuntimeConfig(o.configFile)
+		if err != nil {
+			log.Warnf("[Capa.runtime] failed to reload the config: %v", err)
+			continue
+		}
+		if err := rt.UpdateProxyConfig(cfg.Extends[proxy.ConfigKey]); err != nil {
+			log.Warnf("[Capa.runtime] failed to reload the proxy config: %v", err)
+			continue
+		}
+		log.Infof("[Capa.runtime] reloaded the proxy config from %s", o.configFile)
+	}
+}
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/state"
+)
+
+// stateOptions are the flags shared by the state commands.
+type stateOptions struct {
+	store    string
+	key      string
+	metadata map[string]string
+}
+
+func (o *stateOptions) addFlags(c *cobra.Command) {
+	flags := c.Flags()
+	flags.StringVar(&o.store, "store", "", "Name of the state store")
+	flags.StringVar(&o.key, "key", "", "Key of the state")
+	flags.StringToStringVarP(&o.metadata, "metadata", "m", nil, "Metadata of the request, as key=value pairs")
+	c.MarkFlagRequired("store")
+	c.MarkFlagRequired("key")
+}
+
+// writeOptions are the flags of the state writes.
+type writeOptions struct {
+	etag        string
+	concurrency string
+	consistency string
+}
+
+func (o *writeOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.etag, "etag", "", "ETag the state must have to be written")
+	flags.StringVar(&o.concurrency, "concurrency", "", "Concurrency of the write: first-write or last-write")
+	flags.StringVar(&o.consistency, "consistency", "", "Consistency of the write: strong or eventual")
+}
+
+// etagValue returns the ETag of the write, nil when not set.
+func (o *writeOptions) etagValue(flags *pflag.FlagSet) *runtimev1pb.Etag {
+	if !flags.Changed("etag") {
+		return nil
+	}
+	return &runtimev1pb.Etag{Value: o.etag}
+}
+
+func (o *writeOptions) options() (*runtimev1pb.StateOptions, error) {
+	options := &runtimev1pb.StateOptions{}
+	switch o.concurrency {
+	case "":
+	case state.FirstWrite:
+		options.Concurrency = runtimev1pb.StateOptions_CONCURRENCY_FIRST_WRITE
+	case state.LastWrite:
+		options.Concurrency = runtimev1pb.StateOptions_CONCURRENCY_LAST_WRITE
+	default:
+		return nil, fmt.Errorf("invalid concurrency %q", o.concurrency)
+	}
+	consistency, err := parseConsistency(o.consistency)
+	if err != nil {
+		return nil, err
+	}
+	options.Consistency = consistency
+	return options, nil
+}
+
+func parseConsistency(consistency string) (runtimev1pb.StateOptions_StateConsistency, error) {
+	switch consistency {
+	case "":
+		return runtimev1pb.StateOptions_CONSISTENCY_UNSPECIFIED, nil
+	case state.Eventual:
+		return runtimev1pb.StateOptions_CONSISTENCY_EVENTUAL, nil
+	case state.Strong:
+		return runtimev1pb.StateOptions_CONSISTENCY_STRONG, nil
+	}
+	return 0, fmt.Errorf("invalid consistency %q", consistency)
+}
+
+func newStateCommand() *cobra.Command {
+	o := &clientOptions{}
+	c := &cobra.Command{
+		Use:   "state",
+		Short: "Read and write the state stores of a running sidecar.",
+	}
+	o.addFlags(c.PersistentFlags())
+	c.AddCommand(newStateGetCommand(o), newStateSaveCommand(o), newStateDeleteCommand(o), newStateQueryCommand(o))
+	return c
+}
+
+func newStateGetCommand(o *clientOptions) *cobra.Command {
+	s := &stateOptions{}
+	var consistency string
+	c := &cobra.Command{
+		Use:   "get",
+		Short: "Get the state of a key.",
+		Long:  "Get the state of a key. The data is written as is, and its ETag and metadata to stderr.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			readConsistency, err := parseConsistency(consistency)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.GetState(ctx, &runtimev1pb.GetStateRequest{
+					StoreName:   s.store,
+					Key:         s.key,
+					Consistency: readConsistency,
+					Metadata:    s.metadata,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					if res.Etag != "" {
+						fmt.Fprintf(c.ErrOrStderr(), "etag: %s\n", res.Etag)
+					}
+					writeBindingMetadata(c.ErrOrStderr(), res.Metadata)
+					writeData(w, res.Data)
+				})
+			})
+		},
+	}
+	s.addFlags(c)
+	c.Flags().StringVar(&consistency, "consistency", "", "Consistency of the read: strong or eventual")
+	return c
+}
+
+func newStateSaveCommand(o *clientOptions) *cobra.Command {
+	s := &stateOptions{}
+	w := &writeOptions{}
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "save",
+		Short: "Save the state of a key.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			options, err := w.options()
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.SaveState(ctx, &runtimev1pb.SaveStateRequest{
+					StoreName: s.store,
+					States: []*runtimev1pb.StateItem{{
+						Key:      s.key,
+						Value:    data,
+						Etag:     w.etagValue(c.Flags()),
+						Metadata: s.metadata,
+						Options:  options,
+					}},
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(io.Writer) {})
+			})
+		},
+	}
+	s.addFlags(c)
+	w.addFlags(c.Flags())
+	payload.addFlags(c.Flags())
+	return c
+}
+
+func newStateDeleteCommand(o *clientOptions) *cobra.Command {
+	s := &stateOptions{}
+	w := &writeOptions{}
+	c := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the state of a key.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			options, err := w.options()
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.DeleteState(ctx, &runtimev1pb.DeleteStateRequest{
+					StoreName: s.store,
+					Key:       s.key,
+					Etag:      w.etagValue(c.Flags()),
+					Options:   options,
+					Metadata:  s.metadata,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(io.Writer) {})
+			})
+		},
+	}
+	s.addFlags(c)
+	w.addFlags(c.Flags())
+	return c
+}
+
+func newStateQueryCommand(o *clientOptions) *cobra.Command {
+	var (
+		store    string
+		metadata map[string]string
+	)
+	payload := &payloadOptions{}
+	c := &cobra.Command{
+		Use:   "query",
+		Short: "Query the states of a store.",
+		Long: "Query the states of a store with a json query, given as the payload, like " +
+			`{"filter": {"EQ": {"state": "CA"}}, "sort": [{"key": "id"}], "page": {"limit": 10}}. ` +
+			"Each result is written on a line as its key and its data, and the token of the next " +
+			"page to stderr.",
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			data, err := payload.payload(c)
+			if err != nil {
+				return err
+			}
+			return o.call(func(ctx context.Context, client runtimev1pb.RuntimeClient) error {
+				res, err := client.QueryState(ctx, &runtimev1pb.QueryStateRequest{
+					StoreName: store,
+					Query:     string(data),
+					Metadata:  metadata,
+				})
+				if err != nil {
+					return err
+				}
+				return o.print(c, res, func(w io.Writer) {
+					for _, item := range res.Results {
+						if item.Error != "" {
+							fmt.Fprintf(w, "%s\terror: %s\n", item.Key, item.Error)
+							continue
+						}
+						fmt.Fprintf(w, "%s\t%s\n", item.Key, item.Data)
+					}
+					if res.Token != "" {
+						fmt.Fprintf(c.ErrOrStderr(), "token: %s\n", res.Token)
+					}
+				})
+			})
+		},
+	}
+	c.Flags().StringVar(&store, "store", "", "Name of the state store")
+	c.Flags().StringToStringVarP(&metadata, "metadata", "m", nil, "Metadata of the request, as key=value pairs")
+	c.MarkFlagRequired("store")
+	payload.addFlags(c.Flags())
+	return c
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"group.rxcloud/capa/pkg/version"
+)
+
+func newVersionCommand() *cobra.Command {
+	var (
+		short  bool
+		output string
+	)
+	c := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of capa.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			w := c.OutOrStdout()
+			info := version.Get()
+			switch {
+			case output == outputJSON:
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			case output != outputText:
+				return fmt.Errorf("invalid output format %q", output)
+			case short:
+				fmt.Fprintln(w, info.Version)
+			default:
+				fmt.Fprintf(w, "Version:    %s\n", info.Version)
+				fmt.Fprintf(w, "Git commit: %s\n", info.GitCommit)
+				fmt.Fprintf(w, "Build date: %s\n", info.BuildDate)
+				fmt.Fprintf(w, "Go version: %s\n", info.GoVersion)
+				fmt.Fprintf(w, "Platform:   %s\n", info.Platform)
+			}
+			return nil
+		},
+	}
+	c.Flags().BoolVarP(&short, "short", "s", false, "Print the version number only")
+	c.Flags().StringVarP(&output, "output", "o", outputText, "Output format: text or json")
+	return c
+}
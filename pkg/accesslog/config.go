@@ -0,0 +1,108 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ConfigKey is the key of the access log config in CapaRuntimeConfig.Extends.
+const ConfigKey = "access_log"
+
+// The fields of an access log entry.
+const (
+	FieldTimestamp     = "timestamp"
+	FieldAppId         = "app_id"
+	FieldRoute         = "route"
+	FieldUpstream      = "upstream"
+	FieldMethod        = "method"
+	FieldPath          = "path"
+	FieldProtocol      = "protocol"
+	FieldStatus        = "status"
+	FieldBytesReceived = "bytes_received"
+	FieldBytesSent     = "bytes_sent"
+	FieldDuration      = "duration"
+	FieldTraceId       = "trace_id"
+	FieldRetries       = "retries"
+)
+
+// AllFields are the fields logged by default, in order.
+var AllFields = []string{
+	FieldTimestamp, FieldAppId, FieldRoute, FieldUpstream, FieldMethod, FieldPath, FieldProtocol,
+	FieldStatus, FieldBytesReceived, FieldBytesSent, FieldDuration, FieldTraceId, FieldRetries,
+}
+
+// The outputs of the access log.
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+)
+
+// Config is the access log config shared by the proxy and the API servers.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Fields are the logged fields, all of them by default.
+	Fields []string `json:"fields,omitempty"`
+	// SampleRate is the ratio of the requests logged, in (0, 1]. Defaults to 1.
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// Routes enables or disables the access log per route. The routes not listed are logged.
+	// The route of the gRPC API is its full method, and the one of the HTTP API is the
+	// path of its endpoint, like /v1.0/state/{storeName}/{key}.
+	Routes map[string]bool `json:"routes,omitempty"`
+	// Output is one of stdout or file. Defaults to stdout.
+	Output string      `json:"output,omitempty"`
+	File   *FileConfig `json:"file,omitempty"`
+}
+
+// FileConfig is the rotating file of the access log.
+type FileConfig struct {
+	Path string `json:"path"`
+	// MaxSizeMB rotates the file once it reaches that size, 100MB by default.
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// MaxBackups is the number of rotated files kept, 5 by default.
+	MaxBackups int `json:"max_backups,omitempty"`
+}
+
+// ParseConfig parses the access log config from its json form. Empty data yields a
+// disabled access log.
+func ParseConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if len(data) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid access log config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the fields, the sample rate and the output of the config.
+func (c *Config) Validate() error {
+	known := map[string]bool{}
+	for _, field := range AllFields {
+		known[field] = true
+	}
+	for _, field := range c.Fields {
+		if !known[field] {
+			return fmt.Errorf("access log: unknown field %q", field)
+		}
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("access log: invalid sample rate %v", c.SampleRate)
+	}
+	switch c.Output {
+	case "", OutputStdout:
+	case OutputFile:
+		if c.File == nil || c.File.Path == "" {
+			return fmt.Errorf("access log: file output requires a file path")
+		}
+		if c.File.MaxSizeMB < 0 || c.File.MaxBackups < 0 {
+			return fmt.Errorf("access log: max_size_mb and max_backups must not be negative")
+		}
+	default:
+		return fmt.Errorf("access log: unknown output %q", c.Output)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package accesslog
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/utils"
+)
+
+// Entry is an access log entry of a request.
+type Entry struct {
+	// Time is when the request started.
+	Time time.Time
+	// AppId overrides the app id of the logger when set.
+	AppId    string
+	Route    string
+	Upstream string
+	Method   string
+	Path     string
+	Protocol string
+	// Status is the HTTP status, or the gRPC status code for gRPC requests.
+	Status        int
+	BytesReceived int64
+	BytesSent     int64
+	Duration      time.Duration
+	TraceId       string
+	Retries       int
+}
+
+// Logger writes the access log entries as json, in the same format as utils/logger.go.
+type Logger struct {
+	appId      string
+	fields     []string
+	sampleRate float64
+	routes     map[string]bool
+
+	logger *log.Logger
+	out    io.WriteCloser
+}
+
+// New returns the access logger of the config, or nil when the access log is disabled.
+func New(appId string, cfg *Config) (*Logger, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{
+		appId:      appId,
+		fields:     cfg.Fields,
+		sampleRate: cfg.SampleRate,
+		routes:     cfg.Routes,
+	}
+	if len(l.fields) == 0 {
+		l.fields = AllFields
+	}
+	if l.sampleRate == 0 {
+		l.sampleRate = 1
+	}
+
+	if cfg.Output == OutputFile {
+		out, err := newRotatingFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		l.out = out
+	}
+
+	timestamp := false
+	for _, field := range l.fields {
+		if field == FieldTimestamp {
+			timestamp = true
+		}
+	}
+	l.logger = log.New()
+	l.logger.SetLevel(log.InfoLevel)
+	l.logger.SetOutput(os.Stdout)
+	if l.out != nil {
+		l.logger.SetOutput(l.out)
+	}
+	formatter := utils.NewJSONFormatter()
+	formatter.DisableTimestamp = !timestamp
+	l.logger.SetFormatter(formatter)
+	return l, nil
+}
+
+// Log writes the entry, unless its route is disabled or it is not sampled.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+	if enabled, ok := l.routes[e.Route]; ok && !enabled {
+		return
+	}
+	if l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return
+	}
+
+	fields := log.Fields{}
+	for _, field := range l.fields {
+		switch field {
+		case FieldAppId:
+			fields[field] = l.appId
+			if e.AppId != "" {
+				fields[field] = e.AppId
+			}
+		case FieldRoute:
+			fields[field] = e.Route
+		case FieldUpstream:
+			fields[field] = e.Upstream
+		case FieldMethod:
+			fields[field] = e.Method
+		case FieldPath:
+			fields[field] = e.Path
+		case FieldProtocol:
+			fields[field] = e.Protocol
+		case FieldStatus:
+			fields[field] = e.Status
+		case FieldBytesReceived:
+			fields[field] = e.BytesReceived
+		case FieldBytesSent:
+			fields[field] = e.BytesSent
+		case FieldDuration:
+			// The duration is logged in milliseconds.
+			fields[field] = float64(e.Duration) / float64(time.Millisecond)
+		case FieldTraceId:
+			fields[field] = e.TraceId
+		case FieldRetries:
+			fields[field] = e.Retries
+		}
+	}
+	l.logger.WithTime(e.Time).WithFields(fields).Info("access")
+}
+
+// Close closes the file of the access log, if any.
+func (l *Logger) Close() error {
+	if l == nil || l.out == nil {
+		return nil
+	}
+	return l.out.Close()
+}
+
+// TraceId returns the trace id of a request from its W3C traceparent, B3 or request id
+// headers, whichever is found first. get returns the value of a header.
+func TraceId(get func(name string) string) string {
+	// traceparent is "version-traceid-parentid-flags".
+	if parts := strings.Split(get("traceparent"), "-"); len(parts) == 4 {
+		return parts[1]
+	}
+	if id := get("x-b3-traceid"); id != "" {
+		return id
+	}
+	return get("x-request-id")
+}
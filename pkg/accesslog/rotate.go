@@ -0,0 +1,132 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 5
+
+	backupTimeFormat = "20060102T150405.000"
+)
+
+// rotatingFile is a file renamed with a timestamp suffix once it reaches its max size.
+// Only the last backups are kept.
+type rotatingFile struct {
+	sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(cfg *FileConfig) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxBackups: cfg.MaxBackups,
+	}
+	if f.maxSize == 0 {
+		f.maxSize = defaultMaxSizeMB * 1024 * 1024
+	}
+	if f.maxBackups == 0 {
+		f.maxBackups = defaultMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to a backup and opens a new one. The lock must be held.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup := fmt.Sprintf("%s.%s", f.path, time.Now().Format(backupTimeFormat))
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups keeps the last maxBackups backups. The timestamps of the backup
+// names sort in time order.
+func (f *rotatingFile) removeOldBackups() {
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	prefix := f.path + "."
+	var names []string
+	for _, backup := range backups {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(backup, prefix)); err == nil {
+			names = append(names, backup)
+		}
+	}
+	sort.Strings(names)
+	for len(names) > f.maxBackups {
+		os.Remove(names[0])
+		names = names[1:]
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,73 @@
+// Package bindings is the Binding API of the runtime: the output bindings send the data
+// of the app to external systems, like files, HTTP endpoints or local commands, and the
+// input bindings deliver the events of external systems to the app.
+package bindings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+// OperationKind is an operation of an output binding.
+type OperationKind string
+
+// The operations shared by the output bindings.
+const (
+	GetOperation    OperationKind = "get"
+	CreateOperation OperationKind = "create"
+	DeleteOperation OperationKind = "delete"
+	ListOperation   OperationKind = "list"
+)
+
+var (
+	// ErrNotFound is returned when invoking an output binding that doesn't exist.
+	ErrNotFound = errors.New("binding not found")
+	// ErrUnsupportedOperation is returned when invoking an operation a binding doesn't support.
+	ErrUnsupportedOperation = errors.New("unsupported operation")
+)
+
+// Metadata is the metadata of a binding component, from its config.
+type Metadata = components.Metadata
+
+// OutputBinding is a binding component sending data to an external system.
+type OutputBinding interface {
+	// Init applies the metadata of the component. It must not have side effects, so
+	// that the config can be checked by creating its components.
+	Init(metadata Metadata) error
+	// Operations are the operations supported by the binding.
+	Operations() []OperationKind
+	Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error)
+}
+
+// InvokeRequest is the request of an output binding.
+type InvokeRequest struct {
+	Data      []byte
+	Metadata  map[string]string
+	Operation OperationKind
+}
+
+// InvokeResponse is the response of an output binding.
+type InvokeResponse struct {
+	Data     []byte
+	Metadata map[string]string
+}
+
+// OutputBindings are the output bindings of the runtime, by name.
+type OutputBindings map[string]OutputBinding
+
+// Invoke invokes the operation of the output binding of the name.
+func (b OutputBindings) Invoke(ctx context.Context, name string, req *InvokeRequest) (*InvokeResponse, error) {
+	binding, ok := b[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	for _, operation := range binding.Operations() {
+		if operation == req.Operation {
+			return binding.Invoke(ctx, req)
+		}
+	}
+	return nil, fmt.Errorf("%w %q of binding %s", ErrUnsupportedOperation, req.Operation, name)
+}
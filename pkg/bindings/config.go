@@ -0,0 +1,89 @@
+package bindings
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+// ConfigKey is the key of the bindings config in CapaRuntimeConfig.Extends.
+const ConfigKey = "bindings"
+
+// Config declares the binding components of the runtime.
+type Config struct {
+	Components []*Component `json:"components,omitempty"`
+	// DeadLetterDir is the directory of the events of the input bindings that kept
+	// failing, in a directory per binding. They are dropped when not set.
+	DeadLetterDir string `json:"dead_letter_dir,omitempty"`
+}
+
+// Component is a binding component.
+type Component struct {
+	components.Component
+
+	// MaxConcurrency is the number of events of an input binding delivered at once,
+	// 1 by default.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Retry retries the events of an input binding, 3 times by default.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// ParseConfig parses the bindings config from its json form. Empty data yields no bindings.
+func ParseConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if err := components.ParseConfig(ConfigKey, data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the config by creating its components, which have no side effects.
+func (c *Config) Validate() error {
+	_, _, err := NewBindings(c)
+	return err
+}
+
+// NewBindings creates and inits the output and input bindings of the config.
+func NewBindings(c *Config) (OutputBindings, []*Input, error) {
+	cs := make([]*components.Component, len(c.Components))
+	for i, component := range c.Components {
+		cs[i] = &component.Component
+	}
+	if err := components.Validate(ConfigKey, cs); err != nil {
+		return nil, nil, err
+	}
+
+	outputs := OutputBindings{}
+	var inputs []*Input
+	for _, component := range c.Components {
+		if IsInputBinding(component.Type) {
+			input, err := newInputOf(component, c.DeadLetterDir)
+			if err != nil {
+				return nil, nil, fmt.Errorf("bindings: component %q: %v", component.Name, err)
+			}
+			inputs = append(inputs, input)
+			continue
+		}
+		binding, err := NewOutputBinding(component.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bindings: component %q: %v", component.Name, err)
+		}
+		if err := binding.Init(component.InitMetadata()); err != nil {
+			return nil, nil, fmt.Errorf("bindings: component %q: %v", component.Name, err)
+		}
+		outputs[component.Name] = binding
+	}
+	return outputs, inputs, nil
+}
+
+func newInputOf(component *Component, deadLetterDir string) (*Input, error) {
+	binding, err := NewInputBinding(component.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Init(component.InitMetadata()); err != nil {
+		return nil, err
+	}
+	return newInput(component, binding, deadLetterDir)
+}
@@ -0,0 +1,28 @@
+package bindings
+
+import (
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/utils"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"components": [{
+		"name": "orders",
+		"type": "cron",
+		"metadata": [{"name": "schedule", "value": "@every 1m"}],
+		"max_concurrency": 2,
+		"retry": {"attempts": 5, "backoff": "1s"}
+	}]}`))
+	if err != nil {
+		t.Fatalf("failed to parse the config: %v", err)
+	}
+	c := cfg.Components[0]
+	if c.Name != "orders" || c.Type != "cron" || len(c.Metadata) != 1 || c.MaxConcurrency != 2 {
+		t.Fatalf("unexpected component %+v", c)
+	}
+	if c.Retry == nil || c.Retry.Attempts != 5 || c.Retry.Backoff != utils.Duration(time.Second) {
+		t.Fatalf("unexpected retry policy %+v", c.Retry)
+	}
+}
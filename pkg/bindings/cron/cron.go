@@ -0,0 +1,80 @@
+// Package cron is an input binding triggering the app on a schedule.
+package cron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the cron components.
+const ComponentType = "bindings.cron"
+
+const (
+	// scheduleKey is the metadata of the schedule: a standard cron expression like
+	// "*/5 * * * *", a descriptor like "@daily", or an interval like "@every 15s".
+	scheduleKey = "schedule"
+	// timeKey is the event metadata of the scheduled time, in RFC 3339.
+	timeKey = "time"
+)
+
+// Cron sends an event without data at the times of its schedule, in local time.
+type Cron struct {
+	spec     string
+	schedule schedule
+}
+
+// NewCron returns a new cron input binding.
+func NewCron() bindings.InputBinding {
+	return &Cron{}
+}
+
+func (c *Cron) Init(metadata bindings.Metadata) error {
+	spec := metadata.Properties[scheduleKey]
+	if spec == "" {
+		return fmt.Errorf("%s is required", scheduleKey)
+	}
+	s, err := parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	if s.next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %q never runs", spec)
+	}
+	c.spec = spec
+	c.schedule = s
+	return nil
+}
+
+func (c *Cron) Read(ctx context.Context, handler bindings.Handler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	next := c.schedule.next(time.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+		event := &bindings.ReadResponse{
+			Metadata: map[string]string{scheduleKey: c.spec, timeKey: next.Format(time.RFC3339)},
+		}
+		// The delivery may outlast the next time, which waits for a free delivery slot.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(ctx, event)
+		}()
+		// The times missed, like while the host was suspended, are skipped.
+		next = c.schedule.next(next)
+		if now := time.Now(); !next.IsZero() && next.Before(now) {
+			next = c.schedule.next(now)
+		}
+	}
+	return fmt.Errorf("schedule %q has no next time", c.spec)
+}
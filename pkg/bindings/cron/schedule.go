@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule returns the next time of a schedule after a time.
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// every is a schedule firing at a fixed interval, like "@every 15s".
+type every time.Duration
+
+func (e every) next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// fields is a schedule of the 5 standard cron fields: minute, hour, day of month, month
+// and day of week. The fields are the bit sets of their matching values.
+type fields struct {
+	minute, hour, dom, month, dow uint64
+	// When both days are restricted, a time matching either of them matches.
+	domStar, dowStar bool
+}
+
+// field is the range of the values of a cron field.
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField = field{"minute", 0, 59}
+	hourField   = field{"hour", 0, 23}
+	domField    = field{"day of month", 1, 31}
+	monthField  = field{"month", 1, 12}
+	// Sunday is both 0 and 7.
+	dowField = field{"day of week", 0, 7}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule parses a standard cron expression, like "*/5 * * * *", a descriptor
+// like "@daily", or a fixed interval like "@every 1m30s".
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q", spec)
+		}
+		return every(d), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+	s := &fields{domStar: parts[2] == "*", dowStar: parts[4] == "*"}
+	var err error
+	for i, f := range []struct {
+		field
+		bits *uint64
+	}{
+		{minuteField, &s.minute},
+		{hourField, &s.hour},
+		{domField, &s.dom},
+		{monthField, &s.month},
+		{dowField, &s.dow},
+	} {
+		if *f.bits, err = f.parse(parts[i]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parse parses a comma separated list of values, ranges like "1-5" and steps like "*/15"
+// or "10-40/10".
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, part)
+			}
+			rangeExpr, step = part[:i], n
+		}
+		start, end := f.min, f.max
+		switch {
+		case rangeExpr == "*":
+		case strings.Contains(rangeExpr, "-"):
+			i := strings.Index(rangeExpr, "-")
+			var err error
+			if start, err = f.value(rangeExpr[:i]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(rangeExpr[i+1:]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range in %s %q", f.name, part)
+			}
+		default:
+			var err error
+			if start, err = f.value(rangeExpr); err != nil {
+				return 0, err
+			}
+			// A single value with a step, like "5/15", runs up to the max.
+			if step == 1 {
+				end = start
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// next returns the first matching minute after t. It skips the months, days and hours
+// not matching, and gives up after 5 years for schedules like "0 0 30 2 *".
+func (s *fields) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *fields) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
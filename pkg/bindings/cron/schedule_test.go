@@ -0,0 +1,94 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec     string
+		from     string
+		expected []string
+	}{
+		{"*/15 * * * *", "2024-03-01 10:07", []string{"2024-03-01 10:15", "2024-03-01 10:30", "2024-03-01 10:45", "2024-03-01 11:00"}},
+		{"10-40/10 8 * * *", "2024-03-01 08:35", []string{"2024-03-01 08:40", "2024-03-02 08:10", "2024-03-02 08:20"}},
+		{"5/20 * * * *", "2024-03-01 10:00", []string{"2024-03-01 10:05", "2024-03-01 10:25", "2024-03-01 10:45", "2024-03-01 11:05"}},
+		{"0 9-11 * * *", "2024-03-01 11:00", []string{"2024-03-02 09:00", "2024-03-02 10:00", "2024-03-02 11:00"}},
+		{"0,30 12 * * *", "2024-03-01 12:00", []string{"2024-03-01 12:30", "2024-03-02 12:00"}},
+		{"0 0 1 */3 *", "2024-02-10 00:00", []string{"2024-04-01 00:00", "2024-07-01 00:00", "2024-10-01 00:00"}},
+		// 2024-03-01 is a Friday. Both days restricted: the 15th or any Monday.
+		{"0 0 15 * 1", "2024-03-01 00:00", []string{"2024-03-04 00:00", "2024-03-11 00:00", "2024-03-15 00:00", "2024-03-18 00:00"}},
+		// Only one day restricted: the other star doesn't widen it.
+		{"0 0 15 * *", "2024-03-01 00:00", []string{"2024-03-15 00:00", "2024-04-15 00:00"}},
+		{"0 0 * * 1", "2024-03-01 00:00", []string{"2024-03-04 00:00", "2024-03-11 00:00"}},
+		// Sunday is both 0 and 7.
+		{"0 0 * * 7", "2024-03-01 00:00", []string{"2024-03-03 00:00", "2024-03-10 00:00"}},
+		{"0 0 * * 0", "2024-03-01 00:00", []string{"2024-03-03 00:00", "2024-03-10 00:00"}},
+		{"0 0 * * 5-7", "2024-03-01 00:00", []string{"2024-03-02 00:00", "2024-03-03 00:00", "2024-03-08 00:00"}},
+		{"@daily", "2024-12-31 23:59", []string{"2025-01-01 00:00", "2025-01-02 00:00"}},
+		{"@weekly", "2024-03-01 00:00", []string{"2024-03-03 00:00", "2024-03-10 00:00"}},
+		{"0 0 29 2 *", "2024-03-01 00:00", []string{"2028-02-29 00:00"}},
+		{"@every 90s", "2024-03-01 10:00", []string{"2024-03-01 10:01", "2024-03-01 10:03"}},
+	}
+	for _, test := range tests {
+		s, err := parseSchedule(test.spec)
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", test.spec, err)
+		}
+		next := at(test.from)
+		for _, expected := range test.expected {
+			next = s.next(next)
+			if !next.Truncate(time.Minute).Equal(at(expected)) {
+				t.Fatalf("%s: expected %s, got %s", test.spec, expected, next.Format("2006-01-02 15:04:05"))
+			}
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1s",
+		"@sometimes",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestScheduleNeverRuns(t *testing.T) {
+	s, err := parseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if next := s.next(at("2024-01-01 00:00")); !next.IsZero() {
+		t.Fatalf("expected no next time, got %s", next)
+	}
+	err = NewCron().Init(bindings.Metadata{Properties: map[string]string{scheduleKey: "0 0 31 4 *"}})
+	if err == nil {
+		t.Fatal("expected a schedule that never runs to be rejected")
+	}
+}
@@ -0,0 +1,143 @@
+// Package exec is an output binding running the local commands of an allowlist.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the exec components.
+const ComponentType = "bindings.exec"
+
+// ExecOperation runs a command.
+const ExecOperation = bindings.OperationKind("exec")
+
+const (
+	// allowlistKey is the metadata of the comma separated commands that may run.
+	allowlistKey = "allowlist"
+	// workDirKey is the metadata of the working directory of the commands.
+	workDirKey = "workDir"
+	// timeoutKey is the metadata of the timeout of the commands, like "5s".
+	timeoutKey = "timeout"
+	// argsPatternKey is the metadata of the regular expression every arg must match
+	// as a whole. The args are unrestricted without it.
+	argsPatternKey = "argsPattern"
+
+	// commandKey is the request metadata of the command, which must be in the allowlist.
+	commandKey = "command"
+	// argsKey is the request metadata of the args of the command, as a json list of strings.
+	argsKey = "args"
+	// stderrKey is the response metadata of the standard error of the command.
+	stderrKey = "stderr"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Exec runs the commands of its allowlist, with the data as standard input, and
+// returns their standard output. The commands run without a shell, so their args are
+// passed as is. A command exiting with a non zero code is an error.
+//
+// The allowlist only checks the command: any args are passed to it unless they are
+// restricted by the args pattern, so the allowlisted commands must be safe with any
+// args, e.g. not a shell or an interpreter.
+type Exec struct {
+	allowlist   map[string]bool
+	argsPattern *regexp.Regexp
+	workDir     string
+	timeout     time.Duration
+}
+
+// NewExec returns a new exec output binding.
+func NewExec() bindings.OutputBinding {
+	return &Exec{}
+}
+
+func (e *Exec) Init(metadata bindings.Metadata) error {
+	e.allowlist = map[string]bool{}
+	for _, command := range strings.Split(metadata.Properties[allowlistKey], ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			e.allowlist[command] = true
+		}
+	}
+	if len(e.allowlist) == 0 {
+		return fmt.Errorf("%s is required", allowlistKey)
+	}
+	if value := metadata.Properties[argsPatternKey]; value != "" {
+		pattern, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", argsPatternKey, value, err)
+		}
+		e.argsPattern = pattern
+	}
+	e.workDir = metadata.Properties[workDirKey]
+	e.timeout = defaultTimeout
+	if value, ok := metadata.Properties[timeoutKey]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid %s %q", timeoutKey, value)
+		}
+		e.timeout = timeout
+	}
+	return nil
+}
+
+func (e *Exec) Operations() []bindings.OperationKind {
+	return []bindings.OperationKind{ExecOperation}
+}
+
+func (e *Exec) Invoke(ctx context.Context, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	command := req.Metadata[commandKey]
+	if !e.allowlist[command] {
+		return nil, fmt.Errorf("command %q is not allowed", command)
+	}
+	var args []string
+	if value := req.Metadata[argsKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &args); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", argsKey, err)
+		}
+	}
+	if e.argsPattern != nil {
+		for _, arg := range args {
+			if !e.argsPattern.MatchString(arg) {
+				return nil, fmt.Errorf("arg %q of command %q is not allowed", arg, command)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = e.workDir
+	cmd.Stdin = bytes.NewReader(req.Data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// The command is killed once the timeout, or the deadline of the call, is reached.
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("command %q: %w", command, ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("command %q exited with code %d", command, exitErr.ExitCode())
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = fmt.Errorf("%v: %s", err, msg)
+			}
+		}
+		return nil, err
+	}
+
+	res := &bindings.InvokeResponse{Data: stdout.Bytes()}
+	if stderr.Len() > 0 {
+		res.Metadata = map[string]string{stderrKey: stderr.String()}
+	}
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+func newTestExec(t *testing.T, properties map[string]string) bindings.OutputBinding {
+	t.Helper()
+	e := NewExec()
+	if err := e.Init(bindings.Metadata{Name: "exec", Properties: properties}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	return e
+}
+
+func invoke(e bindings.OutputBinding, data string, command string, args string) (*bindings.InvokeResponse, error) {
+	return e.Invoke(context.Background(), &bindings.InvokeRequest{
+		Operation: ExecOperation,
+		Data:      []byte(data),
+		Metadata:  map[string]string{commandKey: command, argsKey: args},
+	})
+}
+
+func TestExec(t *testing.T) {
+	e := newTestExec(t, map[string]string{allowlistKey: "cat, sh"})
+	res, err := invoke(e, "hello capa", "cat", "")
+	if err != nil {
+		t.Fatalf("failed to run cat: %v", err)
+	}
+	if string(res.Data) != "hello capa" {
+		t.Fatalf("expected the stdin on stdout, got %q", res.Data)
+	}
+
+	res, err = invoke(e, "", "sh", `["-c", "echo out; echo warn >&2"]`)
+	if err != nil {
+		t.Fatalf("failed to run sh: %v", err)
+	}
+	if string(res.Data) != "out\n" || res.Metadata[stderrKey] != "warn\n" {
+		t.Fatalf("expected stdout and stderr, got %q and %v", res.Data, res.Metadata)
+	}
+}
+
+func TestExecDisallowedCommand(t *testing.T) {
+	e := newTestExec(t, map[string]string{allowlistKey: "cat"})
+	for _, command := range []string{"sh", "/bin/cat", "./cat", "cat ", ""} {
+		if _, err := invoke(e, "", command, ""); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected %q to be rejected, got %v", command, err)
+		}
+	}
+}
+
+func TestExecArgsPattern(t *testing.T) {
+	e := newTestExec(t, map[string]string{allowlistKey: "echo", argsPatternKey: `[\w.]+`})
+	if res, err := invoke(e, "", "echo", `["hello", "capa.v1"]`); err != nil || string(res.Data) != "hello capa.v1\n" {
+		t.Fatalf("expected the matching args to be allowed, got %v", err)
+	}
+	for _, args := range []string{`["-n", "hello"]`, `["hello world"]`, `["hello", ""]`} {
+		if _, err := invoke(e, "", "echo", args); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected the args %s to be rejected, got %v", args, err)
+		}
+	}
+
+	if err := NewExec().Init(bindings.Metadata{Properties: map[string]string{allowlistKey: "echo", argsPatternKey: "("}}); err == nil {
+		t.Fatalf("expected an invalid args pattern to be rejected")
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	e := newTestExec(t, map[string]string{allowlistKey: "sleep", timeoutKey: "100ms"})
+	start := time.Now()
+	_, err := invoke(e, "", "sleep", `["10"]`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the command to be killed at the timeout, took %s", elapsed)
+	}
+}
+
+func TestExecNonZeroExit(t *testing.T) {
+	e := newTestExec(t, map[string]string{allowlistKey: "sh"})
+	_, err := invoke(e, "", "sh", `["-c", "echo failed >&2; exit 3"]`)
+	if err == nil || err.Error() != `command "sh" exited with code 3: failed` {
+		t.Fatalf("expected the exit code and the stderr in the error, got %v", err)
+	}
+}
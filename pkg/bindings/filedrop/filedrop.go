@@ -0,0 +1,150 @@
+// Package filedrop is an input binding delivering the files dropped in a directory.
+package filedrop
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the filedrop components.
+const ComponentType = "bindings.filedrop"
+
+const (
+	// pathKey is the metadata of the watched directory.
+	pathKey = "path"
+	// patternKey is the metadata of the glob pattern of the delivered file names, all by default.
+	patternKey = "pattern"
+	// pollIntervalKey is the metadata of the interval of the scans of the directory, like "5s".
+	pollIntervalKey = "pollInterval"
+	// fileNameKey is the event metadata of the name of the file.
+	fileNameKey = "fileName"
+
+	defaultPollInterval = time.Second
+	// tempSuffix marks the files still being written. The writers should write the
+	// files with this suffix, or out of the directory, and rename them once written.
+	tempSuffix = ".tmp"
+)
+
+// FileDrop scans its directory and sends an event with the content of each file found.
+// A file is removed once delivered, or given up. It is kept for the next scan when the
+// binding is stopped before its delivery. The hidden and temp files are ignored.
+type FileDrop struct {
+	path         string
+	pattern      string
+	pollInterval time.Duration
+}
+
+// NewFileDrop returns a new filedrop input binding.
+func NewFileDrop() bindings.InputBinding {
+	return &FileDrop{}
+}
+
+func (f *FileDrop) Init(metadata bindings.Metadata) error {
+	f.path = metadata.Properties[pathKey]
+	if f.path == "" {
+		return fmt.Errorf("%s is required", pathKey)
+	}
+	f.pattern = metadata.Properties[patternKey]
+	if _, err := filepath.Match(f.pattern, ""); err != nil {
+		return fmt.Errorf("invalid %s %q: %v", patternKey, f.pattern, err)
+	}
+	f.pollInterval = defaultPollInterval
+	if value, ok := metadata.Properties[pollIntervalKey]; ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid %s %q", pollIntervalKey, value)
+		}
+		f.pollInterval = interval
+	}
+	return nil
+}
+
+func (f *FileDrop) Read(ctx context.Context, handler bindings.Handler) error {
+	if err := os.MkdirAll(f.path, 0755); err != nil {
+		return err
+	}
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		inFlight = map[string]bool{}
+	)
+	defer wg.Wait()
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+	for {
+		names, err := f.scan()
+		if err != nil {
+			log.Warnf("[Capa.bindings] failed to scan %s: %v", f.path, err)
+		}
+		for _, name := range names {
+			lock.Lock()
+			busy := inFlight[name]
+			inFlight[name] = true
+			lock.Unlock()
+			if busy {
+				continue
+			}
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				f.deliver(ctx, handler, name)
+				lock.Lock()
+				delete(inFlight, name)
+				lock.Unlock()
+			}(name)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// scan returns the names of the files to deliver, in name order.
+func (f *FileDrop) scan() ([]string, error) {
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, tempSuffix) {
+			continue
+		}
+		if f.pattern != "" {
+			if ok, _ := filepath.Match(f.pattern, name); !ok {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *FileDrop) deliver(ctx context.Context, handler bindings.Handler, name string) {
+	path := filepath.Join(f.path, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Warnf("[Capa.bindings] failed to read %s: %v", path, err)
+		return
+	}
+	// The file is kept to be delivered again when the handler needs it.
+	if err := handler(ctx, &bindings.ReadResponse{Data: data, Metadata: map[string]string{fileNameKey: name}}); err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Warnf("[Capa.bindings] failed to remove %s: %v", path, err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package http is an output binding calling an HTTP endpoint.
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the http components.
+const ComponentType = "bindings.http"
+
+const (
+	// urlKey is the metadata of the base URL of the endpoint.
+	urlKey = "url"
+	// timeoutKey is the metadata of the timeout of the calls, like "5s".
+	timeoutKey = "timeout"
+	// pathKey is the request metadata of the path appended to the URL.
+	pathKey = "path"
+	// statusCodeKey is the response metadata of the HTTP status.
+	statusCodeKey = "statusCode"
+
+	defaultTimeout = 30 * time.Second
+	// maxErrorBody is the size of the body quoted by the error of a failed call.
+	maxErrorBody = 512
+)
+
+// The operations of the http binding are the HTTP methods.
+const (
+	GetOperation     = bindings.GetOperation
+	HeadOperation    = bindings.OperationKind("head")
+	PostOperation    = bindings.OperationKind("post")
+	PutOperation     = bindings.OperationKind("put")
+	PatchOperation   = bindings.OperationKind("patch")
+	DeleteOperation  = bindings.DeleteOperation
+	OptionsOperation = bindings.OperationKind("options")
+)
+
+// HTTP calls its endpoint with the method of the operation and the data as body. The
+// request metadata are sent as headers, except the path, which is appended to the URL
+// and can't leave it. The response metadata are
+// the status and the headers of the response. A status of 400 or more is an error.
+type HTTP struct {
+	url    *url.URL
+	client *http.Client
+}
+
+// NewHTTP returns a new http output binding.
+func NewHTTP() bindings.OutputBinding {
+	return &HTTP{}
+}
+
+func (h *HTTP) Init(metadata bindings.Metadata) error {
+	u, err := url.Parse(metadata.Properties[urlKey])
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", urlKey, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", urlKey)
+	}
+	timeout := defaultTimeout
+	if value, ok := metadata.Properties[timeoutKey]; ok {
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid %s %q", timeoutKey, value)
+		}
+	}
+	h.url = u
+	h.client = &http.Client{Timeout: timeout}
+	return nil
+}
+
+func (h *HTTP) Operations() []bindings.OperationKind {
+	return []bindings.OperationKind{
+		GetOperation,
+		HeadOperation,
+		PostOperation,
+		PutOperation,
+		PatchOperation,
+		DeleteOperation,
+		OptionsOperation,
+	}
+}
+
+func (h *HTTP) Invoke(ctx context.Context, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	u := *h.url
+	if path := req.Metadata[pathKey]; path != "" {
+		if err := checkPath(path); err != nil {
+			return nil, err
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+	}
+	method := strings.ToUpper(string(req.Operation))
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range req.Metadata {
+		if key != pathKey {
+			httpReq.Header.Set(key, value)
+		}
+	}
+
+	res, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		if len(data) > maxErrorBody {
+			data = data[:maxErrorBody]
+		}
+		return nil, fmt.Errorf("%s %s: status %d: %s", method, u.Redacted(), res.StatusCode, data)
+	}
+
+	metadata := map[string]string{statusCodeKey: strconv.Itoa(res.StatusCode)}
+	for key := range res.Header {
+		metadata[key] = res.Header.Get(key)
+	}
+	return &bindings.InvokeResponse{Data: data, Metadata: metadata}, nil
+}
+
+// checkPath rejects the paths which would call another endpoint than the URL: the
+// absolute URLs, and the paths climbing above the URL with ".." segments.
+func checkPath(path string) error {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "//") {
+		return fmt.Errorf("invalid %s %q: absolute URLs are not allowed", pathKey, path)
+	}
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return fmt.Errorf("invalid %s %q: \"..\" segments are not allowed", pathKey, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+func newTestHTTP(t *testing.T, url string) bindings.OutputBinding {
+	t.Helper()
+	h := NewHTTP()
+	if err := h.Init(bindings.Metadata{Name: "http", Properties: map[string]string{urlKey: url}}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	return h
+}
+
+func TestHTTPPath(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.EscapedPath())
+	}))
+	defer server.Close()
+	h := newTestHTTP(t, server.URL+"/api/")
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "", expected: "/api/"},
+		{path: "items/1", expected: "/api/items/1"},
+		{path: "/items/1", expected: "/api/items/1"},
+		{path: "items/a..b", expected: "/api/items/a..b"},
+		{path: "items?admin=true", expected: "/api/items%3Fadmin=true"},
+		{path: "%2e%2e/admin", expected: "/api/%252e%252e/admin"},
+	}
+	for _, c := range cases {
+		paths = nil
+		_, err := h.Invoke(context.Background(), &bindings.InvokeRequest{
+			Operation: GetOperation,
+			Metadata:  map[string]string{pathKey: c.path},
+		})
+		if err != nil {
+			t.Fatalf("%q: call failed: %v", c.path, err)
+		}
+		if len(paths) != 1 || paths[0] != c.expected {
+			t.Fatalf("%q: expected %s, got %v", c.path, c.expected, paths)
+		}
+	}
+}
+
+func TestHTTPPathRejected(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	h := newTestHTTP(t, server.URL+"/api")
+
+	for _, path := range []string{
+		"http://evil.example.com/steal",
+		"https://evil.example.com",
+		"//evil.example.com/steal",
+		"..",
+		"../admin",
+		"items/../../admin",
+		"items/..",
+		`items\..\..\admin`,
+	} {
+		_, err := h.Invoke(context.Background(), &bindings.InvokeRequest{
+			Operation: GetOperation,
+			Metadata:  map[string]string{pathKey: path},
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid path") {
+			t.Errorf("expected %q to be rejected, got %v", path, err)
+		}
+	}
+	if called {
+		t.Fatalf("expected no call with a rejected path")
+	}
+}
+
+func TestHTTPInvoke(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Method", req.Method)
+		w.Header().Set("X-Trace", req.Header.Get("X-Trace"))
+		if req.URL.Path == "/missing" {
+			http.Error(w, "not here", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	defer server.Close()
+	h := newTestHTTP(t, server.URL)
+
+	res, err := h.Invoke(context.Background(), &bindings.InvokeRequest{
+		Operation: PostOperation,
+		Data:      []byte("item"),
+		Metadata:  map[string]string{"X-Trace": "abc", pathKey: "items"},
+	})
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if string(res.Data) != "created" || res.Metadata[statusCodeKey] != "201" || res.Metadata["X-Method"] != "POST" || res.Metadata["X-Trace"] != "abc" {
+		t.Fatalf("unexpected response %q %v", res.Data, res.Metadata)
+	}
+
+	_, err = h.Invoke(context.Background(), &bindings.InvokeRequest{
+		Operation: GetOperation,
+		Metadata:  map[string]string{pathKey: "missing"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "status 404: not here") {
+		t.Fatalf("expected the status in the error, got %v", err)
+	}
+}
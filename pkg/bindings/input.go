@@ -0,0 +1,170 @@
+package bindings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/utils"
+)
+
+const defaultMaxConcurrency = 1
+
+// ErrDropped is returned by a Sender when the app drops an event. The event is not retried.
+var ErrDropped = errors.New("event dropped by the app")
+
+// InputBinding is a binding component triggering the app on the events of an external system.
+type InputBinding interface {
+	// Init applies the metadata of the component. It must not have side effects, so
+	// that the config can be checked by creating its components.
+	Init(metadata Metadata) error
+	// Read calls the handler on the events of the binding until the context is done,
+	// and returns once the handlers it called returned.
+	Read(ctx context.Context, handler Handler) error
+}
+
+// ReadResponse is an event of an input binding.
+type ReadResponse struct {
+	Data     []byte
+	Metadata map[string]string
+}
+
+// Handler delivers an event of an input binding to the app. It returns nil once the event
+// needs no other delivery: the app acknowledged or dropped it, or it was given up after
+// the retries, to the dead-letter directory when configured. It returns an error when
+// the binding should keep the event to deliver it again: the binding was stopped before
+// the delivery, or the event couldn't be written to the dead-letter directory.
+type Handler func(ctx context.Context, event *ReadResponse) error
+
+// Sender sends an event of the input binding of the name to the app.
+type Sender func(ctx context.Context, name string, event *ReadResponse) error
+
+// RetryPolicy retries the delivery of the events of an input binding.
+type RetryPolicy = utils.RetryPolicy
+
+// Input is an input binding with the delivery options of its component.
+type Input struct {
+	Name    string
+	Binding InputBinding
+
+	concurrency   chan struct{}
+	retrier       *utils.Retrier
+	deadLetterDir string
+}
+
+func newInput(c *Component, binding InputBinding, deadLetterDir string) (*Input, error) {
+	in := &Input{
+		Name:          c.Name,
+		Binding:       binding,
+		deadLetterDir: deadLetterDir,
+	}
+	maxConcurrency := c.MaxConcurrency
+	if maxConcurrency < 0 {
+		return nil, fmt.Errorf("negative max_concurrency %d", maxConcurrency)
+	}
+	if maxConcurrency == 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	in.concurrency = make(chan struct{}, maxConcurrency)
+
+	retrier, err := utils.NewRetrier(c.Retry)
+	if err != nil {
+		return nil, err
+	}
+	in.retrier = retrier
+	return in, nil
+}
+
+// Read delivers the events of the input binding with the sender until the context is done.
+func (in *Input) Read(ctx context.Context, send Sender) error {
+	return in.Binding.Read(ctx, func(ctx context.Context, event *ReadResponse) error {
+		return in.deliver(ctx, send, event)
+	})
+}
+
+// deliver sends the event once a delivery slot of the binding is free, and retries it
+// until the app acknowledges it or the attempts are exhausted. It follows the contract
+// of Handler: the event given up is written to the dead-letter directory, or dropped
+// without one, and only the stopped deliveries and the failed dead letters are errors.
+func (in *Input) deliver(ctx context.Context, send Sender, event *ReadResponse) error {
+	select {
+	case in.concurrency <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-in.concurrency }()
+
+	attempts, err := in.retrier.Do(ctx,
+		func() error {
+			return send(ctx, in.Name, event)
+		},
+		func(err error) bool {
+			return !errors.Is(err, ErrDropped)
+		},
+		func(retry int, backoff time.Duration, err error) {
+			log.Warnf("[Capa.bindings] failed to deliver an event of %s, retry %d in %s: %v", in.Name, retry, backoff, err)
+		})
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	err = fmt.Errorf("failed to deliver an event of %s after %d attempts: %w", in.Name, attempts, err)
+	if in.deadLetterDir == "" {
+		log.Errorf("[Capa.bindings] %v, the event is dropped", err)
+		return nil
+	}
+	path, dlErr := in.writeDeadLetter(event, attempts, err)
+	if dlErr != nil {
+		log.Errorf("[Capa.bindings] %v, and failed to write it to the dead-letter directory: %v", err, dlErr)
+		return dlErr
+	}
+	log.Errorf("[Capa.bindings] %v, the event is written to %s", err, path)
+	return nil
+}
+
+// DeadLetter is an event given up, as written to the dead-letter directory.
+type DeadLetter struct {
+	Binding  string            `json:"binding"`
+	Time     time.Time         `json:"time"`
+	Attempts int               `json:"attempts"`
+	Error    string            `json:"error"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+}
+
+// writeDeadLetter writes the event to a json file of the directory of the binding in the
+// dead-letter directory, and returns its path.
+func (in *Input) writeDeadLetter(event *ReadResponse, attempts int, err error) (string, error) {
+	dl := &DeadLetter{
+		Binding:  in.Name,
+		Time:     time.Now(),
+		Attempts: attempts,
+		Error:    err.Error(),
+		Metadata: event.Metadata,
+		Data:     event.Data,
+	}
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(in.deadLetterDir, in.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// The timestamp orders the files, the temp suffix makes them unique.
+	f, err := os.CreateTemp(dir, dl.Time.UTC().Format("20060102T150405.000000000")+"-*.json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,179 @@
+package bindings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/pkg/components"
+	"group.rxcloud/capa/utils"
+)
+
+func newTestInput(t *testing.T, maxConcurrency int, attempts int, deadLetterDir string) *Input {
+	t.Helper()
+	in, err := newInput(&Component{
+		Component:      components.Component{Name: "orders"},
+		MaxConcurrency: maxConcurrency,
+		Retry:          &RetryPolicy{Attempts: attempts, Backoff: utils.Duration(time.Millisecond)},
+	}, nil, deadLetterDir)
+	if err != nil {
+		t.Fatalf("failed to create the input: %v", err)
+	}
+	return in
+}
+
+// sending returns a sender returning the error, and counting its calls.
+func sending(calls *int32, err error) Sender {
+	return func(ctx context.Context, name string, event *ReadResponse) error {
+		atomic.AddInt32(calls, 1)
+		return err
+	}
+}
+
+var testEvent = &ReadResponse{Data: []byte("data"), Metadata: map[string]string{"k": "v"}}
+
+func TestDeliverRetriesStopAfterAttempts(t *testing.T) {
+	in := newTestInput(t, 1, 2, "")
+	var calls int32
+	// The event given up without a dead-letter directory is dropped, and not delivered again.
+	err := in.deliver(context.Background(), sending(&calls, errors.New("app is failing")), testEvent)
+	if err != nil {
+		t.Fatalf("expected the event given up to need no other delivery, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected the first try and 2 retries, got %d calls", calls)
+	}
+
+	// A retried event is delivered once the app acknowledges it.
+	calls = 0
+	err = in.deliver(context.Background(), func(ctx context.Context, name string, event *ReadResponse) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("app is failing")
+		}
+		return nil
+	}, testEvent)
+	if err != nil || calls != 3 {
+		t.Fatalf("expected the event to be delivered at the last retry, got %d calls: %v", calls, err)
+	}
+}
+
+func TestDeliverDroppedIsNotRetried(t *testing.T) {
+	in := newTestInput(t, 1, 3, "")
+	var calls int32
+	err := in.deliver(context.Background(), sending(&calls, ErrDropped), testEvent)
+	if err != nil {
+		t.Fatalf("expected the dropped event to need no other delivery, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry of a dropped event, got %d calls", calls)
+	}
+}
+
+func TestDeliverStoppedDuringBackoff(t *testing.T) {
+	dir := t.TempDir()
+	in, err := newInput(&Component{
+		Component: components.Component{Name: "orders"},
+		Retry:     &RetryPolicy{Attempts: 3, Backoff: utils.Duration(time.Hour)},
+	}, nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var calls int32
+	if err := in.deliver(ctx, sending(&calls, errors.New("app is failing")), testEvent); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the error of the context, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "orders")); len(entries) != 0 {
+		t.Fatalf("expected no dead letter for a stopped delivery, got %d", len(entries))
+	}
+}
+
+func TestDeliverReleasesConcurrencySlot(t *testing.T) {
+	in := newTestInput(t, 1, 0, "")
+	var calls int32
+	in.deliver(context.Background(), sending(&calls, errors.New("app is failing")), testEvent)
+	if len(in.concurrency) != 0 {
+		t.Fatalf("expected the slot of a failed delivery to be released")
+	}
+
+	// A delivery waits for the slot of the one in flight.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- in.deliver(context.Background(), func(ctx context.Context, name string, event *ReadResponse) error {
+			close(started)
+			<-release
+			return nil
+		}, testEvent)
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls = 0
+	if err := in.deliver(ctx, sending(&calls, nil), testEvent); !errors.Is(err, context.DeadlineExceeded) || calls != 0 {
+		t.Fatalf("expected the delivery to wait for the slot, got %d calls: %v", calls, err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the first delivery to succeed: %v", err)
+	}
+	if err := in.deliver(context.Background(), sending(&calls, nil), testEvent); err != nil || calls != 1 {
+		t.Fatalf("expected the slot to be free again, got %d calls: %v", calls, err)
+	}
+}
+
+func TestDeliverWritesDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	in := newTestInput(t, 1, 1, dir)
+	var calls int32
+	before := time.Now()
+	if err := in.deliver(context.Background(), sending(&calls, errors.New("app is failing")), testEvent); err != nil {
+		t.Fatalf("expected the dead-lettered event to need no other delivery, got %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "orders"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a dead letter, got %d: %v", len(entries), err)
+	}
+	if !strings.HasSuffix(entries[0].Name(), ".json") {
+		t.Fatalf("expected a json dead letter, got %s", entries[0].Name())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "orders", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl := &DeadLetter{}
+	if err := json.Unmarshal(data, dl); err != nil {
+		t.Fatalf("invalid dead letter %s: %v", data, err)
+	}
+	if dl.Binding != "orders" || dl.Attempts != 2 || string(dl.Data) != "data" || dl.Metadata["k"] != "v" {
+		t.Fatalf("unexpected dead letter %+v", dl)
+	}
+	if !strings.Contains(dl.Error, "after 2 attempts: app is failing") {
+		t.Fatalf("expected the last error in the dead letter, got %q", dl.Error)
+	}
+	if dl.Time.Before(before) || dl.Time.After(time.Now()) {
+		t.Fatalf("expected the time of the dead letter, got %s", dl.Time)
+	}
+}
+
+func TestDeliverDeadLetterFailure(t *testing.T) {
+	// The dead-letter directory can't be created under a file.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := newTestInput(t, 1, 0, file)
+	var calls int32
+	if err := in.deliver(context.Background(), sending(&calls, errors.New("app is failing")), testEvent); err == nil {
+		t.Fatalf("expected the event to be kept when its dead letter fails")
+	}
+}
@@ -0,0 +1,188 @@
+// Package localfs is an output binding reading and writing the files of a local directory.
+package localfs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the localfs components.
+const ComponentType = "bindings.localfs"
+
+const (
+	// rootPathKey is the metadata of the directory of the files.
+	rootPathKey = "rootPath"
+	// fileNameKey is the request metadata of the file name, relative to the root path.
+	fileNameKey = "fileName"
+)
+
+// LocalFS reads and writes the files under its root path. The file names can't
+// escape the root path, neither with ".." nor through symlinks.
+//
+// create writes the data to the file, a random name is chosen when none is given.
+// get returns the data of the file, delete removes it, and list returns the json
+// list of the file names.
+type LocalFS struct {
+	rootPath string
+}
+
+// NewLocalFS returns a new localfs output binding.
+func NewLocalFS() bindings.OutputBinding {
+	return &LocalFS{}
+}
+
+func (l *LocalFS) Init(metadata bindings.Metadata) error {
+	rootPath := metadata.Properties[rootPathKey]
+	if rootPath == "" {
+		return fmt.Errorf("%s is required", rootPathKey)
+	}
+	rootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return err
+	}
+	l.rootPath = rootPath
+	return nil
+}
+
+func (l *LocalFS) Operations() []bindings.OperationKind {
+	return []bindings.OperationKind{
+		bindings.CreateOperation,
+		bindings.GetOperation,
+		bindings.DeleteOperation,
+		bindings.ListOperation,
+	}
+}
+
+func (l *LocalFS) Invoke(ctx context.Context, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	fileName := req.Metadata[fileNameKey]
+	switch req.Operation {
+	case bindings.CreateOperation:
+		if fileName == "" {
+			fileName = randomName()
+		}
+		return l.create(fileName, req.Data)
+	case bindings.ListOperation:
+		return l.list()
+	}
+
+	if fileName == "" {
+		return nil, fmt.Errorf("%s is required", fileNameKey)
+	}
+	path, err := l.path(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if req.Operation == bindings.DeleteOperation {
+		return &bindings.InvokeResponse{}, os.Remove(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &bindings.InvokeResponse{Data: data}, nil
+}
+
+func (l *LocalFS) create(fileName string, data []byte) (*bindings.InvokeResponse, error) {
+	path, err := l.path(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	name, _ := filepath.Rel(l.rootPath, path)
+	return &bindings.InvokeResponse{Metadata: map[string]string{fileNameKey: filepath.ToSlash(name)}}, nil
+}
+
+// list returns the names of the files under the root path, a missing root path has none.
+func (l *LocalFS) list() (*bindings.InvokeResponse, error) {
+	names := []string{}
+	err := filepath.WalkDir(l.rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == l.rootPath && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			name, _ := filepath.Rel(l.rootPath, path)
+			names = append(names, filepath.ToSlash(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	return &bindings.InvokeResponse{Data: data}, nil
+}
+
+// path returns the path of the file name, which is cleaned as an absolute path so that
+// it stays under the root path. The symlinks are resolved before the path is checked,
+// so that they can't lead out of the root path either.
+func (l *LocalFS) path(fileName string) (string, error) {
+	name := filepath.Clean("/" + filepath.FromSlash(fileName))
+	if name == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid file name %q", fileName)
+	}
+	path := filepath.Join(l.rootPath, strings.TrimPrefix(name, string(filepath.Separator)))
+
+	root, err := resolveSymlinks(l.rootPath)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolveSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("file name %q is out of the root path", fileName)
+	}
+	return path, nil
+}
+
+// resolveSymlinks resolves the symlinks of the existing part of the path. The missing
+// part, e.g. the file to create, is kept as is.
+func resolveSymlinks(path string) (string, error) {
+	existing, missing := path, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+	// A dangling symlink fails, as the file it points to would be created.
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, missing), nil
+}
+
+func randomName() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+package localfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+func newTestLocalFS(t *testing.T, rootPath string) bindings.OutputBinding {
+	t.Helper()
+	l := NewLocalFS()
+	if err := l.Init(bindings.Metadata{Name: "files", Properties: map[string]string{rootPathKey: rootPath}}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	return l
+}
+
+func invoke(l bindings.OutputBinding, operation bindings.OperationKind, fileName string, data string) (*bindings.InvokeResponse, error) {
+	return l.Invoke(context.Background(), &bindings.InvokeRequest{
+		Operation: operation,
+		Data:      []byte(data),
+		Metadata:  map[string]string{fileNameKey: fileName},
+	})
+}
+
+func TestLocalFS(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	l := newTestLocalFS(t, root)
+
+	if res, err := invoke(l, bindings.ListOperation, "", ""); err != nil || string(res.Data) != "[]" {
+		t.Fatalf("expected no files in a missing root path, got %v", err)
+	}
+	res, err := invoke(l, bindings.CreateOperation, "dir/file.txt", "hello capa")
+	if err != nil || res.Metadata[fileNameKey] != "dir/file.txt" {
+		t.Fatalf("failed to create the file: %v", err)
+	}
+	if res, err := invoke(l, bindings.GetOperation, "dir/file.txt", ""); err != nil || string(res.Data) != "hello capa" {
+		t.Fatalf("failed to get the file: %v", err)
+	}
+	if res, err := invoke(l, bindings.ListOperation, "", ""); err != nil || string(res.Data) != `["dir/file.txt"]` {
+		t.Fatalf("failed to list the files: %v", err)
+	}
+	if _, err := invoke(l, bindings.DeleteOperation, "dir/file.txt", ""); err != nil {
+		t.Fatalf("failed to delete the file: %v", err)
+	}
+	if _, err := invoke(l, bindings.GetOperation, "dir/file.txt", ""); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be deleted, got %v", err)
+	}
+}
+
+func TestLocalFSDotDotStaysUnderRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	l := newTestLocalFS(t, root)
+
+	for _, name := range []string{"../outside", "../../outside", "a/../../outside", "/outside"} {
+		res, err := invoke(l, bindings.CreateOperation, name, "data")
+		if err != nil {
+			t.Fatalf("%q: failed to create: %v", name, err)
+		}
+		if res.Metadata[fileNameKey] != "outside" {
+			t.Fatalf("%q: expected the file under the root path, got %q", name, res.Metadata[fileNameKey])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file out of the root path, got %v", err)
+	}
+	if _, err := invoke(l, bindings.GetOperation, "..", ""); err == nil {
+		t.Fatalf("expected the root path itself to be rejected")
+	}
+}
+
+func TestLocalFSSymlinkEscapes(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"dir":      outside,
+		"file":     filepath.Join(outside, "secret"),
+		"dangling": filepath.Join(outside, "missing"),
+		"relative": "../outside",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := newTestLocalFS(t, root)
+
+	calls := []struct {
+		operation bindings.OperationKind
+		fileName  string
+	}{
+		{bindings.GetOperation, "dir/secret"},
+		{bindings.GetOperation, "file"},
+		{bindings.GetOperation, "relative/secret"},
+		{bindings.CreateOperation, "dir/new"},
+		{bindings.CreateOperation, "dir/sub/new"},
+		{bindings.CreateOperation, "dangling"},
+		{bindings.CreateOperation, "file"},
+		{bindings.DeleteOperation, "dir/secret"},
+	}
+	for _, call := range calls {
+		if _, err := invoke(l, call.operation, call.fileName, "written"); err == nil {
+			t.Errorf("expected %s %q to be rejected", call.operation, call.fileName)
+		}
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 1 {
+		t.Fatalf("expected nothing written out of the root path, got %d files", len(entries))
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret")); string(data) != "secret" {
+		t.Fatalf("expected the file out of the root path untouched, got %q", data)
+	}
+}
+
+func TestLocalFSSymlinkedRoot(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	if err := os.MkdirAll(filepath.Join(target, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	// Symlinks within the root path are followed.
+	if err := os.Symlink("sub", filepath.Join(target, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	l := newTestLocalFS(t, link)
+
+	res, err := invoke(l, bindings.CreateOperation, "alias/file", "data")
+	if err != nil || res.Metadata[fileNameKey] != "alias/file" {
+		t.Fatalf("failed to create through the symlinks within the root path: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "sub", "file")); err != nil || string(data) != "data" {
+		t.Fatalf("expected the file in the target directory, got %v", err)
+	}
+}
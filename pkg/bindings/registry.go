@@ -0,0 +1,46 @@
+package bindings
+
+import "fmt"
+
+var (
+	// outputBindings are the factories of the output bindings, by component type.
+	outputBindings = map[string]func() OutputBinding{}
+	// inputBindings are the factories of the input bindings, by component type.
+	inputBindings = map[string]func() InputBinding{}
+)
+
+// RegisterOutputBinding registers the factory of the output bindings of the component type,
+// like "bindings.http".
+func RegisterOutputBinding(componentType string, factory func() OutputBinding) {
+	outputBindings[componentType] = factory
+}
+
+// NewOutputBinding returns a new output binding of the component type.
+func NewOutputBinding(componentType string) (OutputBinding, error) {
+	factory, ok := outputBindings[componentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown binding type %q", componentType)
+	}
+	return factory(), nil
+}
+
+// RegisterInputBinding registers the factory of the input bindings of the component type,
+// like "bindings.cron".
+func RegisterInputBinding(componentType string, factory func() InputBinding) {
+	inputBindings[componentType] = factory
+}
+
+// IsInputBinding tells whether the component type is an input binding.
+func IsInputBinding(componentType string) bool {
+	_, ok := inputBindings[componentType]
+	return ok
+}
+
+// NewInputBinding returns a new input binding of the component type.
+func NewInputBinding(componentType string) (InputBinding, error) {
+	factory, ok := inputBindings[componentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown input binding type %q", componentType)
+	}
+	return factory(), nil
+}
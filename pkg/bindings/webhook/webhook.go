@@ -0,0 +1,118 @@
+// Package webhook is an input binding delivering the requests of an HTTP endpoint.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/bindings"
+)
+
+// ComponentType is the type of the webhook components.
+const ComponentType = "bindings.webhook"
+
+const (
+	// addressKey is the metadata of the listen address of the endpoint, like "127.0.0.1:9000".
+	addressKey = "address"
+	// pathKey is the metadata of the path of the endpoint, "/" by default.
+	pathKey = "path"
+
+	// maxBodySize is the max size of the body of a request.
+	maxBodySize = 4 << 20
+	// shutdownTimeout is how long the outstanding requests may take once the binding is stopped.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Webhook serves an endpoint, and sends an event with the body of each POST request. The
+// metadata of the event are the headers of the request. It answers 200 once the event
+// needs no other delivery, 503 when the binding is stopped before the delivery, and 500
+// when the event given up couldn't be written to the dead-letter directory, so that the
+// sender sends it again.
+type Webhook struct {
+	address string
+	path    string
+}
+
+// NewWebhook returns a new webhook input binding.
+func NewWebhook() bindings.InputBinding {
+	return &Webhook{}
+}
+
+func (h *Webhook) Init(metadata bindings.Metadata) error {
+	h.address = metadata.Properties[addressKey]
+	if _, _, err := net.SplitHostPort(h.address); err != nil {
+		return fmt.Errorf("invalid %s %q: %v", addressKey, h.address, err)
+	}
+	h.path = metadata.Properties[pathKey]
+	if h.path == "" {
+		h.path = "/"
+	}
+	if h.path[0] != '/' {
+		return fmt.Errorf("%s %q must start with /", pathKey, h.path)
+	}
+	return nil
+}
+
+func (h *Webhook) Read(ctx context.Context, handler bindings.Handler) error {
+	l, err := net.Listen("tcp", h.address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(h.path, func(w http.ResponseWriter, req *http.Request) {
+		h.serve(ctx, handler, w, req)
+	})
+	server := &http.Server{Handler: mux}
+
+	// Shutdown waits for the outstanding requests, whose deliveries stop with the context.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	log.Infof("[Capa.bindings] webhook listening on %s%s", l.Addr(), h.path)
+	if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-stopped
+	return nil
+}
+
+func (h *Webhook) serve(ctx context.Context, handler bindings.Handler, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata := map[string]string{}
+	for key := range req.Header {
+		metadata[key] = req.Header.Get(key)
+	}
+
+	// The delivery is bound to the binding, not to the request, so that it is not cut
+	// when the sender gives up waiting.
+	err = handler(ctx, &bindings.ReadResponse{Data: data, Metadata: metadata})
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case ctx.Err() != nil:
+		http.Error(w, "the binding is stopped", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -16,6 +16,10 @@ package cmd
 
 import (
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -32,3 +36,13 @@ func PrintFlags(flags *pflag.FlagSet) {
 		log.Infof("FLAG: --%s=%q", flag.Name, flag.Value)
 	})
 }
+
+// WaitSignalFunc awaits for SIGINT or SIGTERM and calls the cancel function
+func WaitSignalFunc(cancel func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	log.Infof("received signal %v, shutting down", sig)
+	cancel()
+	signal.Stop(sigs)
+}
@@ -0,0 +1,70 @@
+// Package components is the config shared by the kinds of components of the runtime:
+// the bindings, the state stores and the pubsubs.
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Component is a component of the runtime. Its metadata is a list of name and value pairs,
+// like the components of Dapr.
+type Component struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Metadata []*MetadataItem `json:"metadata,omitempty"`
+}
+
+// MetadataItem is a property of the metadata of a component.
+type MetadataItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Metadata is the metadata a component is initialized with, from its config.
+type Metadata struct {
+	Name       string
+	Properties map[string]string
+}
+
+// InitMetadata returns the metadata the component is initialized with.
+func (c *Component) InitMetadata() Metadata {
+	properties := make(map[string]string, len(c.Metadata))
+	for _, item := range c.Metadata {
+		properties[item.Name] = item.Value
+	}
+	return Metadata{Name: c.Name, Properties: properties}
+}
+
+// ParseConfig parses the config of a kind of components from its json form into cfg,
+// which empty data leaves as is.
+func ParseConfig(kind string, data json.RawMessage, cfg interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("invalid %s config: %w", kind, err)
+	}
+	return nil
+}
+
+// Validate checks that the components of a kind have unique names, and that their
+// metadata items are named. The errors are prefixed with the kind.
+func Validate(kind string, components []*Component) error {
+	names := map[string]bool{}
+	for i, component := range components {
+		if component.Name == "" {
+			return fmt.Errorf("%s: component %d: name is required", kind, i)
+		}
+		if names[component.Name] {
+			return fmt.Errorf("%s: duplicate component %q", kind, component.Name)
+		}
+		names[component.Name] = true
+		for _, item := range component.Metadata {
+			if item.Name == "" {
+				return fmt.Errorf("%s: component %q: metadata name is required", kind, component.Name)
+			}
+		}
+	}
+	return nil
+}
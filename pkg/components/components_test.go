@@ -0,0 +1,47 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg := &struct {
+		Components []*Component `json:"components"`
+	}{}
+	if err := ParseConfig("state", nil, cfg); err != nil || cfg.Components != nil {
+		t.Fatalf("expected empty data to leave the config as is, got %+v: %v", cfg, err)
+	}
+	data := []byte(`{"components": [{"name": "store", "type": "memory", "metadata": [{"name": "k", "value": "v"}]}]}`)
+	if err := ParseConfig("state", data, cfg); err != nil {
+		t.Fatalf("failed to parse the config: %v", err)
+	}
+	metadata := cfg.Components[0].InitMetadata()
+	if metadata.Name != "store" || len(metadata.Properties) != 1 || metadata.Properties["k"] != "v" {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+	if err := ParseConfig("state", []byte(`{"components": {}}`), cfg); err == nil || !strings.HasPrefix(err.Error(), "invalid state config") {
+		t.Fatalf("expected an invalid state config, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		components []*Component
+		err        string
+	}{
+		{name: "valid", components: []*Component{{Name: "a"}, {Name: "b", Metadata: []*MetadataItem{{Name: "k"}}}}},
+		{name: "no name", components: []*Component{{Name: "a"}, {Type: "memory"}}, err: "pubsub: component 1: name is required"},
+		{name: "duplicate", components: []*Component{{Name: "a"}, {Name: "a"}}, err: `pubsub: duplicate component "a"`},
+		{name: "no metadata name", components: []*Component{{Name: "a", Metadata: []*MetadataItem{{Value: "v"}}}}, err: `pubsub: component "a": metadata name is required`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Validate("pubsub", c.components)
+			if (c.err == "" && err != nil) || (c.err != "" && (err == nil || err.Error() != c.err)) {
+				t.Fatalf("expected %q, got %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	grpc_go "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"group.rxcloud/capa/pkg/accesslog"
+)
+
+// unaryAccessLog logs the unary calls to the access log. The route of a call is its
+// full method name and its status is the gRPC status code. The API server has no upstream.
+func (s *server) unaryAccessLog(ctx context.Context, req interface{}, info *grpc_go.UnaryServerInfo, handler grpc_go.UnaryHandler) (interface{}, error) {
+	entry := newAccessEntry(ctx, info.FullMethod)
+	res, err := handler(ctx, req)
+	if m, ok := req.(proto.Message); ok {
+		entry.BytesReceived = int64(proto.Size(m))
+	}
+	if m, ok := res.(proto.Message); ok && err == nil {
+		entry.BytesSent = int64(proto.Size(m))
+	}
+	s.logAccess(entry, err)
+	return res, err
+}
+
+// streamAccessLog logs the streaming calls to the access log once they end.
+func (s *server) streamAccessLog(srv interface{}, ss grpc_go.ServerStream, info *grpc_go.StreamServerInfo, handler grpc_go.StreamHandler) error {
+	entry := newAccessEntry(ss.Context(), info.FullMethod)
+	counted := &countingStream{ServerStream: ss}
+	err := handler(srv, counted)
+	entry.BytesReceived, entry.BytesSent = counted.received, counted.sent
+	s.logAccess(entry, err)
+	return err
+}
+
+func newAccessEntry(ctx context.Context, fullMethod string) *accesslog.Entry {
+	entry := &accesslog.Entry{
+		Time:     time.Now(),
+		Route:    fullMethod,
+		Method:   "POST",
+		Path:     fullMethod,
+		Protocol: "grpc",
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	entry.TraceId = accesslog.TraceId(func(name string) string {
+		return strings.Join(md.Get(name), ",")
+	})
+	return entry
+}
+
+func (s *server) logAccess(entry *accesslog.Entry, err error) {
+	entry.Duration = time.Since(entry.Time)
+	entry.Status = int(status.Code(err))
+	s.config.AccessLogger.Log(entry)
+}
+
+// countingStream counts the bytes of the messages of a stream.
+type countingStream struct {
+	grpc_go.ServerStream
+	received int64
+	sent     int64
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		s.sent += int64(proto.Size(msg))
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		s.received += int64(proto.Size(msg))
+	}
+	return err
+}
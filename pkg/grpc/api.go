@@ -2,15 +2,25 @@ package grpc
 
 import (
 	"context"
-	log "github.com/sirupsen/logrus"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"group.rxcloud/capa/pkg/actors"
+	"group.rxcloud/capa/pkg/bindings"
 	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/proxy"
+	"group.rxcloud/capa/pkg/pubsub"
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/version"
 	"sync"
 )
 
 // API is the gRPC interface for the Dapr gRPC API. It implements both the internal and external proto definitions.
 type API interface {
+	runtimev1pb.RuntimeServer
+
 	// Dapr Service methods
 	SetActorRuntime(actor actors.Actors)
 	RegisterActorTimer(ctx context.Context, in *runtimev1pb.RegisterActorTimerRequest) (*emptypb.Empty, error)
@@ -21,6 +31,26 @@ type API interface {
 	GetActorState(ctx context.Context, in *runtimev1pb.GetActorStateRequest) (*runtimev1pb.GetActorStateResponse, error)
 	ExecuteActorStateTransaction(ctx context.Context, in *runtimev1pb.ExecuteActorStateTransactionRequest) (*emptypb.Empty, error)
 	InvokeActor(ctx context.Context, in *runtimev1pb.InvokeActorRequest) (*runtimev1pb.InvokeActorResponse, error)
+	// Invokes binding data to specific output bindings
+	InvokeBinding(ctx context.Context, in *runtimev1pb.InvokeBindingRequest) (*runtimev1pb.InvokeBindingResponse, error)
+	// Gets the state for a specific key
+	GetState(ctx context.Context, in *runtimev1pb.GetStateRequest) (*runtimev1pb.GetStateResponse, error)
+	// Gets a bulk of state items for a list of keys
+	GetBulkState(ctx context.Context, in *runtimev1pb.GetBulkStateRequest) (*runtimev1pb.GetBulkStateResponse, error)
+	// Saves the state for a specific key
+	SaveState(ctx context.Context, in *runtimev1pb.SaveStateRequest) (*emptypb.Empty, error)
+	// Deletes the state for a specific key
+	DeleteState(ctx context.Context, in *runtimev1pb.DeleteStateRequest) (*emptypb.Empty, error)
+	// Deletes a bulk of state items for a list of keys
+	DeleteBulkState(ctx context.Context, in *runtimev1pb.DeleteBulkStateRequest) (*emptypb.Empty, error)
+	// Executes transactions for a specified store
+	ExecuteStateTransaction(ctx context.Context, in *runtimev1pb.ExecuteStateTransactionRequest) (*emptypb.Empty, error)
+	// Queries the states of a specified store
+	QueryState(ctx context.Context, in *runtimev1pb.QueryStateRequest) (*runtimev1pb.QueryStateResponse, error)
+	// Publishes events to the specific topic
+	PublishEvent(ctx context.Context, in *runtimev1pb.PublishEventRequest) (*emptypb.Empty, error)
+	// Publishes a bulk of events to the specific topic
+	BulkPublishEvent(ctx context.Context, in *runtimev1pb.BulkPublishRequest) (*runtimev1pb.BulkPublishResponse, error)
 	// Gets metadata of the sidecar
 	GetMetadata(ctx context.Context, in *emptypb.Empty) (*runtimev1pb.GetMetadataResponse, error)
 	// Sets value in extended metadata of the sidecar
@@ -30,66 +60,107 @@ type API interface {
 }
 
 type api struct {
+	runtimev1pb.UnimplementedRuntimeServer
+
 	id               string
+	node             func() *proxy.Node
 	appProtocol      string
 	extendedMetadata sync.Map
 
 	actor actors.Actors
 
+	sendToOutputBindingFn func(ctx context.Context, name string, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error)
+
+	stateStores map[string]state.Store
+	pubSubs     map[string]pubsub.PubSub
+
 	shutdown func()
 }
 
 // NewAPI returns a new gRPC API.
 func NewAPI(
 	appID string,
+	node func() *proxy.Node,
 	actor actors.Actors,
+	sendToOutputBindingFn func(ctx context.Context, name string, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error),
+	stateStores map[string]state.Store,
+	pubSubs map[string]pubsub.PubSub,
 	shutdown func(),
 ) API {
 	return &api{
-		actor:    actor,
-		id:       appID,
-		shutdown: shutdown,
+		actor:                 actor,
+		id:                    appID,
+		node:                  node,
+		sendToOutputBindingFn: sendToOutputBindingFn,
+		stateStores:           stateStores,
+		pubSubs:               pubSubs,
+		shutdown:              shutdown,
 	}
 }
 
+// SayHello answers the hello of a client, echoing its data. The SDKs read the version
+// of the sidecar from it.
+func (a *api) SayHello(ctx context.Context, in *runtimev1pb.SayHelloRequest) (*runtimev1pb.SayHelloResponse, error) {
+	return &runtimev1pb.SayHelloResponse{
+		Hello:          fmt.Sprintf("Hello %s, from %s", in.Name, a.id),
+		Data:           in.Data,
+		RuntimeVersion: version.Version(),
+	}, nil
+}
+
+// errActorsUnimplemented answers the actor calls, as the sidecar has no actor runtime yet.
+var errActorsUnimplemented = status.Error(codes.Unimplemented, "actors are not supported by this sidecar yet")
+
 func (a *api) RegisterActorTimer(ctx context.Context, in *runtimev1pb.RegisterActorTimerRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) UnregisterActorTimer(ctx context.Context, in *runtimev1pb.UnregisterActorTimerRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) RegisterActorReminder(ctx context.Context, in *runtimev1pb.RegisterActorReminderRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) UnregisterActorReminder(ctx context.Context, in *runtimev1pb.UnregisterActorReminderRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) RenameActorReminder(ctx context.Context, in *runtimev1pb.RenameActorReminderRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) GetActorState(ctx context.Context, in *runtimev1pb.GetActorStateRequest) (*runtimev1pb.GetActorStateResponse, error) {
-	log.Infof("Actor")
-	return &runtimev1pb.GetActorStateResponse{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) ExecuteActorStateTransaction(ctx context.Context, in *runtimev1pb.ExecuteActorStateTransactionRequest) (*emptypb.Empty, error) {
-	log.Infof("Actor")
-	return &emptypb.Empty{}, nil
+	return nil, errActorsUnimplemented
 }
 
 func (a *api) InvokeActor(ctx context.Context, in *runtimev1pb.InvokeActorRequest) (*runtimev1pb.InvokeActorResponse, error) {
-	log.Infof("Actor")
-	return &runtimev1pb.InvokeActorResponse{}, nil
+	return nil, errActorsUnimplemented
+}
+
+// InvokeBinding invokes the operation of an output binding. An unknown binding is not
+// found, and an operation it doesn't support is an invalid argument.
+func (a *api) InvokeBinding(ctx context.Context, in *runtimev1pb.InvokeBindingRequest) (*runtimev1pb.InvokeBindingResponse, error) {
+	req := &bindings.InvokeRequest{
+		Data:      in.Data,
+		Metadata:  in.Metadata,
+		Operation: bindings.OperationKind(in.Operation),
+	}
+	res, err := a.sendToOutputBindingFn(ctx, in.Name, req)
+	switch {
+	case errors.Is(err, bindings.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, bindings.ErrUnsupportedOperation):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "error invoking output binding %s: %v", in.Name, err)
+	}
+	return &runtimev1pb.InvokeBindingResponse{Data: res.Data, Metadata: res.Metadata}, nil
 }
 
 func (a *api) SetActorRuntime(actor actors.Actors) {
@@ -105,11 +176,38 @@ func (a *api) GetMetadata(ctx context.Context, in *emptypb.Empty) (*runtimev1pb.
 		return true
 	})
 	response := &runtimev1pb.GetMetadataResponse{
+		Id:               a.id,
 		ExtendedMetadata: temp,
+		Node:             nodeToProto(a.node()),
+		RuntimeVersion:   version.Version(),
 	}
 	return response, nil
 }
 
+func nodeToProto(node *proxy.Node) *runtimev1pb.Node {
+	if node == nil {
+		return nil
+	}
+	pb := &runtimev1pb.Node{
+		Type:         string(node.Type),
+		PodName:      node.PodName,
+		PodNamespace: node.PodNamespace,
+		IpAddresses:  node.IPAddresses,
+		AppId:        node.AppId,
+		Cloud:        node.Cloud,
+		Env:          node.Env,
+		Capabilities: node.Capabilities,
+	}
+	if node.Locality != nil {
+		pb.Locality = &runtimev1pb.Locality{
+			Region:  node.Locality.Region,
+			Zone:    node.Locality.Zone,
+			SubZone: node.Locality.SubZone,
+		}
+	}
+	return pb
+}
+
 // SetMetadata Sets value in extended metadata of the sidecar.
 func (a *api) SetMetadata(ctx context.Context, in *runtimev1pb.SetMetadataRequest) (*emptypb.Empty, error) {
 	a.extendedMetadata.Store(in.Key, in.Value)
@@ -13,11 +13,15 @@ limitations under the License.
 
 package grpc
 
+import "group.rxcloud/capa/pkg/accesslog"
+
 // ServerConfig is the config object for a grpc server.
 type ServerConfig struct {
 	AppID              string
 	APIListenAddresses []string
 	Port               int
+	// AccessLogger logs the calls of the server, disabled when nil.
+	AccessLogger *accesslog.Logger
 }
 
 // NewServerConfig returns a new grpc server config.
@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/pubsub"
+)
+
+// getPubSub returns the pubsub of the name. The pubsubs must be configured, and an
+// unknown pubsub is an invalid argument.
+func (a *api) getPubSub(name, topic string) (pubsub.PubSub, error) {
+	if len(a.pubSubs) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "pubsub is not configured")
+	}
+	ps, ok := a.pubSubs[name]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "pubsub %s is not found", name)
+	}
+	if topic == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "topic is empty in pubsub %s", name)
+	}
+	return ps, nil
+}
+
+func (a *api) PublishEvent(ctx context.Context, in *runtimev1pb.PublishEventRequest) (*emptypb.Empty, error) {
+	ps, err := a.getPubSub(in.PubsubName, in.Topic)
+	if err != nil {
+		return nil, err
+	}
+	err = ps.Publish(ctx, &pubsub.PublishRequest{
+		Topic:       in.Topic,
+		Data:        in.Data,
+		ContentType: in.DataContentType,
+		Metadata:    in.Metadata,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error when publish to topic %s in pubsub %s: %v", in.Topic, in.PubsubName, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// BulkPublishEvent publishes the entries one by one. The entries failing to be published
+// are returned, so that the app publishes them again.
+func (a *api) BulkPublishEvent(ctx context.Context, in *runtimev1pb.BulkPublishRequest) (*runtimev1pb.BulkPublishResponse, error) {
+	ps, err := a.getPubSub(in.PubsubName, in.Topic)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(in.Entries))
+	for _, entry := range in.Entries {
+		if entry.EntryId == "" {
+			return nil, status.Error(codes.InvalidArgument, "entry id is required")
+		}
+		if ids[entry.EntryId] {
+			return nil, status.Errorf(codes.InvalidArgument, "duplicate entry id %s", entry.EntryId)
+		}
+		ids[entry.EntryId] = true
+	}
+
+	res := &runtimev1pb.BulkPublishResponse{}
+	for _, entry := range in.Entries {
+		err := ps.Publish(ctx, &pubsub.PublishRequest{
+			Topic:       in.Topic,
+			Data:        entry.Event,
+			ContentType: entry.ContentType,
+			Metadata:    mergeMetadata(in.Metadata, entry.Metadata),
+		})
+		if err != nil {
+			res.FailedEntries = append(res.FailedEntries, &runtimev1pb.BulkPublishResponseFailedEntry{
+				EntryId: entry.EntryId,
+				Error:   fmt.Sprintf("error when publish to topic %s in pubsub %s: %v", in.Topic, in.PubsubName, err),
+			})
+		}
+	}
+	return res, nil
+}
+
+// mergeMetadata returns the metadata of the entry over the one of the request.
+func mergeMetadata(request, entry map[string]string) map[string]string {
+	if len(request) == 0 {
+		return entry
+	}
+	metadata := make(map[string]string, len(request)+len(entry))
+	for k, v := range request {
+		metadata[k] = v
+	}
+	for k, v := range entry {
+		metadata[k] = v
+	}
+	return metadata
+}
@@ -6,6 +6,8 @@ import (
 	grpc_go "google.golang.org/grpc"
 	"io"
 	"net"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
 )
 
 // Server is an interface for the dapr gRPC server.
@@ -63,7 +65,15 @@ func (s *server) StartNonBlocking() error {
 }
 
 func (s *server) getGRPCServer() (*grpc_go.Server, error) {
-	return grpc_go.NewServer(), nil
+	var opts []grpc_go.ServerOption
+	if s.config.AccessLogger != nil {
+		opts = append(opts,
+			grpc_go.ChainUnaryInterceptor(s.unaryAccessLog),
+			grpc_go.ChainStreamInterceptor(s.streamAccessLog))
+	}
+	server := grpc_go.NewServer(opts...)
+	runtimev1pb.RegisterRuntimeServer(server, s.api)
+	return server, nil
 }
 
 func (s *server) Close() error {
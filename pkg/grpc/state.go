@@ -0,0 +1,280 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/state/query"
+)
+
+// getStateStore returns the state store of the name. The stores must be configured, and
+// an unknown store is an invalid argument.
+func (a *api) getStateStore(name string) (state.Store, error) {
+	if len(a.stateStores) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "state store is not configured")
+	}
+	store, ok := a.stateStores[name]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "state store %s is not found", name)
+	}
+	return store, nil
+}
+
+// stateError returns the status of an error of a state store. A mismatched ETag aborts the
+// operation, so that the app reads the state again.
+func stateError(err error, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	switch {
+	case errors.Is(err, state.ErrETagMismatch):
+		return status.Errorf(codes.Aborted, "%s: %v", msg, err)
+	case errors.Is(err, state.ErrETagInvalid):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
+func (a *api) GetState(ctx context.Context, in *runtimev1pb.GetStateRequest) (*runtimev1pb.GetStateResponse, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	if in.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "the key is required")
+	}
+	res, err := store.Get(ctx, &state.GetRequest{
+		Key:      in.Key,
+		Metadata: in.Metadata,
+		Options:  state.GetStateOption{Consistency: stateConsistency(in.Consistency)},
+	})
+	if err != nil {
+		return nil, stateError(err, "fail to get %s from state store %s", in.Key, in.StoreName)
+	}
+	return &runtimev1pb.GetStateResponse{Data: res.Data, Etag: stringValue(res.ETag), Metadata: res.Metadata}, nil
+}
+
+// GetBulkState gets the keys with at most parallelism concurrent reads, all at once when
+// not set. The error of a key is returned in its item.
+func (a *api) GetBulkState(ctx context.Context, in *runtimev1pb.GetBulkStateRequest) (*runtimev1pb.GetBulkStateResponse, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	parallelism := int(in.Parallelism)
+	if parallelism <= 0 {
+		parallelism = len(in.Keys)
+	}
+	items := make([]*runtimev1pb.BulkStateItem, len(in.Keys))
+	limiter := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, key := range in.Keys {
+		wg.Add(1)
+		limiter <- struct{}{}
+		go func(i int, key string) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			item := &runtimev1pb.BulkStateItem{Key: key}
+			res, err := store.Get(ctx, &state.GetRequest{Key: key, Metadata: in.Metadata})
+			if err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Data = res.Data
+				item.Etag = stringValue(res.ETag)
+				item.Metadata = res.Metadata
+			}
+			items[i] = item
+		}(i, key)
+	}
+	wg.Wait()
+	return &runtimev1pb.GetBulkStateResponse{Items: items}, nil
+}
+
+// SaveState saves the states one by one, and stops at the first error.
+func (a *api) SaveState(ctx context.Context, in *runtimev1pb.SaveStateRequest) (*emptypb.Empty, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range in.States {
+		if item.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "the key is required")
+		}
+		if err := store.Set(ctx, setRequest(item)); err != nil {
+			return nil, stateError(err, "failed saving state %s in state store %s", item.Key, in.StoreName)
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (a *api) DeleteState(ctx context.Context, in *runtimev1pb.DeleteStateRequest) (*emptypb.Empty, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	if in.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "the key is required")
+	}
+	req := deleteRequest(&runtimev1pb.StateItem{Key: in.Key, Etag: in.Etag, Options: in.Options, Metadata: in.Metadata})
+	if err := store.Delete(ctx, req); err != nil {
+		return nil, stateError(err, "failed deleting state with key %s", in.Key)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteBulkState deletes the states one by one, and stops at the first error.
+func (a *api) DeleteBulkState(ctx context.Context, in *runtimev1pb.DeleteBulkStateRequest) (*emptypb.Empty, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range in.States {
+		if item.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "the key is required")
+		}
+		if err := store.Delete(ctx, deleteRequest(item)); err != nil {
+			return nil, stateError(err, "failed deleting state with key %s", item.Key)
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ExecuteStateTransaction executes the upserts and the deletes atomically, in a store
+// supporting transactions.
+func (a *api) ExecuteStateTransaction(ctx context.Context, in *runtimev1pb.ExecuteStateTransactionRequest) (*emptypb.Empty, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	transactional, ok := store.(state.TransactionalStore)
+	if !ok || !state.FeatureTransactional.IsPresent(store.Features()) {
+		return nil, status.Errorf(codes.Unimplemented, "state store %s doesn't support transactions", in.StoreName)
+	}
+	operations := make([]state.TransactionalStateOperation, 0, len(in.Operations))
+	for _, op := range in.Operations {
+		item := op.Request
+		if item == nil || item.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "the key is required")
+		}
+		var req interface{}
+		switch state.OperationType(op.OperationType) {
+		case state.Upsert:
+			req = setRequest(item)
+		case state.Delete:
+			req = deleteRequest(item)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "operation type %s not supported", op.OperationType)
+		}
+		operations = append(operations, state.TransactionalStateOperation{
+			Operation: state.OperationType(op.OperationType),
+			Request:   req,
+		})
+	}
+	err = transactional.Multi(ctx, &state.TransactionalStateRequest{Operations: operations, Metadata: in.Metadata})
+	if err != nil {
+		return nil, stateError(err, "error while executing state transaction")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// QueryState queries the states of the store. The stores without native query support
+// have the query evaluated in memory over all their states.
+func (a *api) QueryState(ctx context.Context, in *runtimev1pb.QueryStateRequest) (*runtimev1pb.QueryStateResponse, error) {
+	store, err := a.getStateStore(in.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	q, err := query.Parse([]byte(in.Query))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res, err := state.Query(ctx, store, &state.QueryRequest{Query: q, Metadata: in.Metadata})
+	switch {
+	case errors.Is(err, state.ErrQueryNotSupported):
+		return nil, status.Errorf(codes.Unimplemented, "state store %s doesn't support queries", in.StoreName)
+	case errors.Is(err, query.ErrInvalidQuery):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed query in state store %s: %v", in.StoreName, err)
+	}
+	results := make([]*runtimev1pb.QueryStateItem, 0, len(res.Results))
+	for _, item := range res.Results {
+		results = append(results, &runtimev1pb.QueryStateItem{
+			Key:   item.Key,
+			Data:  item.Data,
+			Etag:  stringValue(item.ETag),
+			Error: item.Error,
+		})
+	}
+	return &runtimev1pb.QueryStateResponse{Results: results, Token: res.Token, Metadata: res.Metadata}, nil
+}
+
+func setRequest(item *runtimev1pb.StateItem) *state.SetRequest {
+	req := &state.SetRequest{
+		Key:      item.Key,
+		Value:    item.Value,
+		ETag:     etag(item.Etag),
+		Metadata: item.Metadata,
+	}
+	if item.Options != nil {
+		req.Options.Concurrency = stateConcurrency(item.Options.Concurrency)
+		req.Options.Consistency = stateConsistency(item.Options.Consistency)
+	}
+	return req
+}
+
+func deleteRequest(item *runtimev1pb.StateItem) *state.DeleteRequest {
+	req := &state.DeleteRequest{
+		Key:      item.Key,
+		ETag:     etag(item.Etag),
+		Metadata: item.Metadata,
+	}
+	if item.Options != nil {
+		req.Options.Concurrency = stateConcurrency(item.Options.Concurrency)
+		req.Options.Consistency = stateConsistency(item.Options.Consistency)
+	}
+	return req
+}
+
+// etag returns the value of the ETag, nil when not set.
+func etag(e *runtimev1pb.Etag) *string {
+	if e == nil {
+		return nil
+	}
+	return &e.Value
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func stateConcurrency(c runtimev1pb.StateOptions_StateConcurrency) string {
+	switch c {
+	case runtimev1pb.StateOptions_CONCURRENCY_FIRST_WRITE:
+		return state.FirstWrite
+	case runtimev1pb.StateOptions_CONCURRENCY_LAST_WRITE:
+		return state.LastWrite
+	}
+	return ""
+}
+
+func stateConsistency(c runtimev1pb.StateOptions_StateConsistency) string {
+	switch c {
+	case runtimev1pb.StateOptions_CONSISTENCY_EVENTUAL:
+		return state.Eventual
+	case runtimev1pb.StateOptions_CONSISTENCY_STRONG:
+		return state.Strong
+	}
+	return ""
+}
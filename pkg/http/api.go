@@ -14,7 +14,6 @@ limitations under the License.
 package http
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/dapr/dapr/pkg/actors"
@@ -23,6 +22,8 @@ import (
 	invokev1 "github.com/dapr/dapr/pkg/messaging/v1"
 	"github.com/valyala/fasthttp"
 	"google.golang.org/grpc/codes"
+	"group.rxcloud/capa/pkg/pubsub"
+	"group.rxcloud/capa/pkg/state"
 	"strconv"
 	"strings"
 	"sync"
@@ -47,7 +48,9 @@ type api struct {
 	endpoints       []Endpoint
 	publicEndpoints []Endpoint
 
-	actor actors.Actors
+	actor       actors.Actors
+	stateStores map[string]state.Store
+	pubSubs     map[string]pubsub.PubSub
 }
 
 type metadata struct {
@@ -57,24 +60,37 @@ type metadata struct {
 }
 
 const (
-	apiVersionV1   = "v1.0"
-	methodParam    = "method"
-	actorTypeParam = "actorType"
-	actorIDParam   = "actorId"
-	nameParam      = "name"
-	daprAppID      = "dapr-app-id"
+	apiVersionV1       = "v1.0"
+	apiVersionV1alpha1 = "v1.0-alpha1"
+	methodParam        = "method"
+	actorTypeParam     = "actorType"
+	actorIDParam       = "actorId"
+	nameParam          = "name"
+	stateKeyParam      = "key"
+	storeNameParam     = "storeName"
+	consistencyParam   = "consistency"
+	concurrencyParam   = "concurrency"
+	pubsubnameParam    = "pubsubname"
+	topicParam         = "topic"
+	etagHeader         = "ETag"
+	ifMatchHeader      = "If-Match"
+	daprAppID          = "dapr-app-id"
 )
 
 // NewAPI returns a new API.
 func NewAPI(
 	appID string,
 	actor actors.Actors,
+	stateStores map[string]state.Store,
+	pubSubs map[string]pubsub.PubSub,
 	shutdown func(),
 ) API {
 	api := &api{
-		actor:    actor,
-		id:       appID,
-		shutdown: shutdown,
+		actor:       actor,
+		id:          appID,
+		stateStores: stateStores,
+		pubSubs:     pubSubs,
+		shutdown:    shutdown,
 	}
 
 	metadataEndpoints := api.constructMetadataEndpoints()
@@ -82,8 +98,12 @@ func NewAPI(
 	api.publicEndpoints = append(api.publicEndpoints, metadataEndpoints...)
 	api.publicEndpoints = append(api.publicEndpoints, healthEndpoints...)
 
+	stateEndpoints := api.constructStateEndpoints()
+	pubsubEndpoints := api.constructPubSubEndpoints()
 	actorEndpoints := api.constructActorEndpoints()
 	shutdownEndpoints := api.constructShutdownEndpoints()
+	api.endpoints = append(api.endpoints, stateEndpoints...)
+	api.endpoints = append(api.endpoints, pubsubEndpoints...)
 	api.endpoints = append(api.endpoints, actorEndpoints...)
 	api.endpoints = append(api.endpoints, metadataEndpoints...)
 	api.endpoints = append(api.endpoints, shutdownEndpoints...)
@@ -472,19 +492,7 @@ func (a *api) onDirectActorMessage(reqCtx *fasthttp.RequestCtx) {
 	})
 	req.WithMetadata(metadata)
 
-	// Unlike other actor calls, resiliency is handled here for invocation.
-	// This is due to actor invocation involving a lookup for the host.
-	// Having the retry here allows us to capture that and be resilient to host failure.
-	// Additionally, we don't perform timeouts at this level. This is because an actor
-	// should technically wait forever on the locking mechanism. If we timeout while
-	// waiting for the lock, we can also create a queue of calls that will try and continue
-	// after the timeout.
-	policy := a.resiliency.ActorPreLockPolicy(reqCtx, actorType, actorID)
-	var resp *invokev1.InvokeMethodResponse
-	err := policy(func(ctx context.Context) (rErr error) {
-		resp, rErr = a.actor.Call(ctx, req)
-		return rErr
-	})
+	resp, err := a.actor.Call(reqCtx, req)
 	if err != nil {
 		msg := NewErrorResponse("ERR_ACTOR_INVOKE_METHOD", fmt.Sprintf(messages.ErrActorInvoke, err))
 		respond(reqCtx, responseWithError(fasthttp.StatusInternalServerError, msg))
@@ -553,7 +561,7 @@ func (a *api) onGetMetadata(reqCtx *fasthttp.RequestCtx) {
 
 	// Copy synchronously so it can be serialized to JSON.
 	a.extendedMetadata.Range(func(key, value interface{}) bool {
-		temp[key.(string)] = key.(string)
+		temp[key.(string)] = value.(string)
 
 		return true
 	})
@@ -563,23 +571,10 @@ func (a *api) onGetMetadata(reqCtx *fasthttp.RequestCtx) {
 		activeActorsCount = a.actor.GetActiveActorsCount(reqCtx)
 	}
 
-	components := a.getComponentsFn()
-	registeredComponents := make([]registeredComponent, 0, len(components))
-
-	for _, comp := range components {
-		registeredComp := registeredComponent{
-			Name:    comp.Name,
-			Version: comp.Spec.Version,
-			Type:    comp.Spec.Type,
-		}
-		registeredComponents = append(registeredComponents, registeredComp)
-	}
-
 	mtd := metadata{
-		ID:                   a.id,
-		ActiveActorsCount:    activeActorsCount,
-		Extended:             temp,
-		RegisteredComponents: registeredComponents,
+		ID:                a.id,
+		ActiveActorsCount: activeActorsCount,
+		Extended:          temp,
 	}
 
 	mtdBytes, err := json.Marshal(mtd)
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dapr/dapr/pkg/config"
+	"github.com/valyala/fasthttp"
+)
+
+// call serves the request with the routes of the API, and returns the response.
+func call(t *testing.T, a API, method string, uri string, body string) *fasthttp.Response {
+	t.Helper()
+	s := &server{api: a, apiSpec: config.APISpec{}}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.SetBodyString(body)
+	s.useRouter()(ctx)
+	return &ctx.Response
+}
+
+func TestMetadata(t *testing.T) {
+	a := NewAPI("app", nil, nil, nil, func() {})
+	if res := call(t, a, fasthttp.MethodPut, "/v1.0/metadata/color", "blue"); res.StatusCode() != fasthttp.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", res.StatusCode())
+	}
+	res := call(t, a, fasthttp.MethodGet, "/v1.0/metadata", "")
+	mtd := &metadata{}
+	if err := json.Unmarshal(res.Body(), mtd); err != nil {
+		t.Fatalf("invalid metadata %s: %v", res.Body(), err)
+	}
+	if mtd.ID != "app" || mtd.Extended["color"] != "blue" {
+		t.Fatalf("unexpected metadata %s", res.Body())
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	a := NewAPI("app", nil, nil, nil, func() {})
+	if res := call(t, a, fasthttp.MethodGet, "/v1.0/healthz", ""); res.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Fatalf("expected the runtime not to be ready, got %d", res.StatusCode())
+	}
+	a.MarkStatusAsReady()
+	if res := call(t, a, fasthttp.MethodGet, "/v1.0/healthz", ""); res.StatusCode() != fasthttp.StatusNoContent {
+		t.Fatalf("expected the runtime to be ready, got %d", res.StatusCode())
+	}
+}
+
+func TestActorsNotConfigured(t *testing.T) {
+	a := NewAPI("app", nil, nil, nil, func() {})
+	res := call(t, a, fasthttp.MethodPost, "/v1.0/actors/counter/1/method/hello", "")
+	if res.StatusCode() != fasthttp.StatusInternalServerError || !strings.Contains(string(res.Body()), "ERR_ACTOR_RUNTIME_NOT_FOUND") {
+		t.Fatalf("expected the actor runtime not to be found, got %d %s", res.StatusCode(), res.Body())
+	}
+}
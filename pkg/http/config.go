@@ -13,6 +13,15 @@ limitations under the License.
 
 package http
 
+import "group.rxcloud/capa/pkg/accesslog"
+
+const (
+	// DefaultMaxRequestBodySize is the max size of the request bodies, in MB.
+	DefaultMaxRequestBodySize = 4
+	// DefaultReadBufferSize is the size of the buffer of the request headers, in KB.
+	DefaultReadBufferSize = 4
+)
+
 // ServerConfig holds config values for an HTTP server.
 type ServerConfig struct {
 	AllowedOrigins     string
@@ -28,6 +37,8 @@ type ServerConfig struct {
 	ReadBufferSize     int
 	StreamRequestBody  bool
 	EnableAPILogging   bool
+	// AccessLogger logs the API calls, it takes precedence over EnableAPILogging.
+	AccessLogger *accesslog.Logger
 }
 
 // NewServerConfig returns a new HTTP server config.
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dapr/dapr/pkg/messages"
+	"github.com/valyala/fasthttp"
+
+	"group.rxcloud/capa/pkg/pubsub"
+)
+
+// bulkPublishEntry is an event of a bulk publish. Its event is any json value.
+type bulkPublishEntry struct {
+	EntryID     string            `json:"entryId"`
+	Event       json.RawMessage   `json:"event"`
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type bulkPublishFailedEntry struct {
+	EntryID string `json:"entryId"`
+	Error   string `json:"error"`
+}
+
+type bulkPublishResponse struct {
+	FailedEntries []bulkPublishFailedEntry `json:"failedEntries"`
+}
+
+func (a *api) constructPubSubEndpoints() []Endpoint {
+	return []Endpoint{
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "publish/{pubsubname}/{topic:*}",
+			Version: apiVersionV1,
+			Handler: a.onPublish,
+		},
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "publish/bulk/{pubsubname}/{topic:*}",
+			Version: apiVersionV1alpha1,
+			Handler: a.onBulkPublish,
+		},
+	}
+}
+
+// getPubSub returns the pubsub and the topic of the request, and responds with the error
+// when the pubsubs are not configured, or the pubsub is unknown, or the topic is empty.
+func (a *api) getPubSub(reqCtx *fasthttp.RequestCtx) (pubsub.PubSub, string, string, bool) {
+	if len(a.pubSubs) == 0 {
+		msg := NewErrorResponse("ERR_PUBSUB_NOT_CONFIGURED", messages.ErrPubsubNotConfigured)
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return nil, "", "", false
+	}
+	pubsubName := reqCtx.UserValue(pubsubnameParam).(string)
+	ps, ok := a.pubSubs[pubsubName]
+	if !ok {
+		msg := NewErrorResponse("ERR_PUBSUB_NOT_FOUND", fmt.Sprintf(messages.ErrPubsubNotFound, pubsubName))
+		respond(reqCtx, responseWithError(fasthttp.StatusNotFound, msg))
+		log.Debug(msg)
+		return nil, "", "", false
+	}
+	topic := reqCtx.UserValue(topicParam).(string)
+	if topic == "" {
+		msg := NewErrorResponse("ERR_TOPIC_EMPTY", fmt.Sprintf(messages.ErrTopicEmpty, pubsubName))
+		respond(reqCtx, responseWithError(fasthttp.StatusNotFound, msg))
+		log.Debug(msg)
+		return nil, "", "", false
+	}
+	return ps, pubsubName, topic, true
+}
+
+func (a *api) onPublish(reqCtx *fasthttp.RequestCtx) {
+	ps, pubsubName, topic, ok := a.getPubSub(reqCtx)
+	if !ok {
+		return
+	}
+	err := ps.Publish(reqCtx, &pubsub.PublishRequest{
+		Topic:       topic,
+		Data:        reqCtx.PostBody(),
+		ContentType: string(reqCtx.Request.Header.ContentType()),
+		Metadata:    getMetadataFromRequest(reqCtx),
+	})
+	if err != nil {
+		msg := NewErrorResponse("ERR_PUBSUB_PUBLISH_MESSAGE", fmt.Sprintf(messages.ErrPubsubPublishMessage, topic, pubsubName, err.Error()))
+		respond(reqCtx, responseWithError(fasthttp.StatusInternalServerError, msg))
+		log.Debug(msg)
+		return
+	}
+	respond(reqCtx, responseWithEmpty())
+}
+
+func (a *api) onBulkPublish(reqCtx *fasthttp.RequestCtx) {
+	ps, pubsubName, topic, ok := a.getPubSub(reqCtx)
+	if !ok {
+		return
+	}
+	var entries []bulkPublishEntry
+	if err := json.Unmarshal(reqCtx.PostBody(), &entries); err != nil {
+		msg := NewErrorResponse("ERR_MALFORMED_REQUEST", fmt.Sprintf(messages.ErrMalformedRequest, err))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return
+	}
+	ids := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		message := ""
+		if entry.EntryID == "" {
+			message = "entry id is required"
+		} else if ids[entry.EntryID] {
+			message = fmt.Sprintf("duplicate entry id %s", entry.EntryID)
+		}
+		if message != "" {
+			msg := NewErrorResponse("ERR_MALFORMED_REQUEST", message)
+			respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+			log.Debug(msg)
+			return
+		}
+		ids[entry.EntryID] = true
+	}
+
+	metadata := getMetadataFromRequest(reqCtx)
+	resp := bulkPublishResponse{FailedEntries: []bulkPublishFailedEntry{}}
+	for _, entry := range entries {
+		entryMetadata := make(map[string]string, len(metadata)+len(entry.Metadata))
+		for k, v := range metadata {
+			entryMetadata[k] = v
+		}
+		for k, v := range entry.Metadata {
+			entryMetadata[k] = v
+		}
+		contentType := entry.ContentType
+		if contentType == "" {
+			contentType = jsonContentTypeHeader
+		}
+		err := ps.Publish(reqCtx, &pubsub.PublishRequest{
+			Topic:       topic,
+			Data:        entry.Event,
+			ContentType: contentType,
+			Metadata:    entryMetadata,
+		})
+		if err != nil {
+			resp.FailedEntries = append(resp.FailedEntries, bulkPublishFailedEntry{
+				EntryID: entry.EntryID,
+				Error:   fmt.Sprintf(messages.ErrPubsubPublishMessage, topic, pubsubName, err.Error()),
+			})
+		}
+	}
+	b, _ := json.Marshal(resp)
+	code := fasthttp.StatusOK
+	if len(resp.FailedEntries) > 0 {
+		code = fasthttp.StatusInternalServerError
+	}
+	respond(reqCtx, responseWithJSON(code, b))
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"group.rxcloud/capa/pkg/pubsub"
+)
+
+// publisher is a pubsub recording the published messages, and failing the ones whose
+// data is "fail".
+type publisher struct {
+	published []*pubsub.PublishRequest
+}
+
+func (p *publisher) Init(metadata pubsub.Metadata) error { return nil }
+func (p *publisher) Open() error                         { return nil }
+func (p *publisher) Close() error                        { return nil }
+
+func (p *publisher) Publish(ctx context.Context, req *pubsub.PublishRequest) error {
+	if string(req.Data) == `"fail"` {
+		return errors.New("broker is failing")
+	}
+	p.published = append(p.published, req)
+	return nil
+}
+
+func (p *publisher) Subscribe(ctx context.Context, req *pubsub.SubscribeRequest, handler pubsub.Handler) error {
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	ps := &publisher{}
+	a := NewAPI("app", nil, nil, map[string]pubsub.PubSub{"pubsub": ps}, func() {})
+
+	s := &server{api: a}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/v1.0/publish/pubsub/orders/created?metadata.ttl=60")
+	ctx.Request.Header.SetContentType("text/plain")
+	ctx.Request.SetBodyString("hello capa")
+	s.useRouter()(ctx)
+	expectStatus(t, &ctx.Response, fasthttp.StatusNoContent)
+
+	if len(ps.published) != 1 {
+		t.Fatalf("expected a published message, got %d", len(ps.published))
+	}
+	req := ps.published[0]
+	if req.Topic != "orders/created" || string(req.Data) != "hello capa" || req.ContentType != "text/plain" || req.Metadata["ttl"] != "60" {
+		t.Fatalf("unexpected published message %+v", req)
+	}
+}
+
+func TestBulkPublish(t *testing.T) {
+	ps := &publisher{}
+	a := NewAPI("app", nil, nil, map[string]pubsub.PubSub{"pubsub": ps}, func() {})
+
+	res := call(t, a, fasthttp.MethodPost, "/v1.0-alpha1/publish/bulk/pubsub/orders?metadata.k=request", `[
+		{"entryId": "1", "event": {"n": 1}},
+		{"entryId": "2", "event": "fail"},
+		{"entryId": "3", "event": "text", "contentType": "text/plain", "metadata": {"k": "entry"}}
+	]`)
+	expectStatus(t, res, fasthttp.StatusInternalServerError)
+	bres := &bulkPublishResponse{}
+	if err := json.Unmarshal(res.Body(), bres); err != nil {
+		t.Fatalf("invalid bulk publish response %s: %v", res.Body(), err)
+	}
+	if len(bres.FailedEntries) != 1 || bres.FailedEntries[0].EntryID != "2" {
+		t.Fatalf("expected the failed entry to be returned, got %s", res.Body())
+	}
+	if len(ps.published) != 2 {
+		t.Fatalf("expected the other entries to be published, got %d", len(ps.published))
+	}
+	first, last := ps.published[0], ps.published[1]
+	if first.ContentType != jsonContentTypeHeader || first.Metadata["k"] != "request" {
+		t.Fatalf("expected the json entry with the request metadata, got %+v", first)
+	}
+	if last.ContentType != "text/plain" || last.Metadata["k"] != "entry" {
+		t.Fatalf("expected the metadata of the entry over the one of the request, got %+v", last)
+	}
+
+	ps.published = nil
+	res = call(t, a, fasthttp.MethodPost, "/v1.0-alpha1/publish/bulk/pubsub/orders", `[{"entryId": "1", "event": 1}]`)
+	expectStatus(t, res, fasthttp.StatusOK)
+}
+
+func TestPublishErrors(t *testing.T) {
+	a := NewAPI("app", nil, nil, map[string]pubsub.PubSub{"pubsub": &publisher{}}, func() {})
+	cases := []struct {
+		name   string
+		api    API
+		uri    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "not configured", api: NewAPI("app", nil, nil, nil, func() {}), uri: "/v1.0/publish/pubsub/orders", status: fasthttp.StatusBadRequest, code: "ERR_PUBSUB_NOT_CONFIGURED"},
+		{name: "unknown pubsub", api: a, uri: "/v1.0/publish/other/orders", status: fasthttp.StatusNotFound, code: "ERR_PUBSUB_NOT_FOUND"},
+		{name: "failed", api: a, uri: "/v1.0/publish/pubsub/orders", body: `"fail"`, status: fasthttp.StatusInternalServerError, code: "ERR_PUBSUB_PUBLISH_MESSAGE"},
+		{name: "malformed bulk", api: a, uri: "/v1.0-alpha1/publish/bulk/pubsub/orders", body: `{}`, status: fasthttp.StatusBadRequest, code: "ERR_MALFORMED_REQUEST"},
+		{name: "no entry id", api: a, uri: "/v1.0-alpha1/publish/bulk/pubsub/orders", body: `[{"event": 1}]`, status: fasthttp.StatusBadRequest, code: "entry id is required"},
+		{name: "duplicate entry id", api: a, uri: "/v1.0-alpha1/publish/bulk/pubsub/orders", body: `[{"entryId": "1"}, {"entryId": "1"}]`, status: fasthttp.StatusBadRequest, code: "duplicate entry id 1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := call(t, c.api, fasthttp.MethodPost, c.uri, c.body)
+			expectStatus(t, res, c.status)
+			if !strings.Contains(string(res.Body()), c.code) {
+				t.Fatalf("expected %s, got %s", c.code, res.Body())
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
 	cors "github.com/AdhityaRamadhanus/fasthttpcors"
 	routing "github.com/fasthttp/router"
@@ -32,9 +33,9 @@ import (
 
 	"github.com/dapr/dapr/pkg/config"
 	cors_dapr "github.com/dapr/dapr/pkg/cors"
-	diag "github.com/dapr/dapr/pkg/diagnostics"
-	diag_utils "github.com/dapr/dapr/pkg/diagnostics/utils"
-	http_middleware "github.com/dapr/dapr/pkg/middleware/http"
+	auth "github.com/dapr/dapr/pkg/runtime/security"
+
+	"group.rxcloud/capa/pkg/accesslog"
 )
 
 var (
@@ -52,9 +53,6 @@ type Server interface {
 
 type server struct {
 	config             ServerConfig
-	tracingSpec        config.TracingSpec
-	metricSpec         config.MetricSpec
-	pipeline           http_middleware.Pipeline
 	api                API
 	apiSpec            config.APISpec
 	servers            []*fasthttp.Server
@@ -65,12 +63,9 @@ type server struct {
 func NewServer(api API, config ServerConfig, apiSpec config.APISpec) Server {
 	infoLog.SetOutputLevel(logger.LogLevel("info"))
 	return &server{
-		api:         api,
-		config:      config,
-		tracingSpec: tracingSpec,
-		metricSpec:  metricSpec,
-		pipeline:    pipeline,
-		apiSpec:     apiSpec,
+		api:     api,
+		config:  config,
+		apiSpec: apiSpec,
 	}
 }
 
@@ -78,14 +73,11 @@ func NewServer(api API, config ServerConfig, apiSpec config.APISpec) Server {
 func (s *server) StartNonBlocking() error {
 	handler := useAPIAuthentication(
 		s.useCors(
-			s.useComponents(
-				s.useRouter())))
-
-	handler = s.useMetrics(handler)
-	handler = s.useTracing(handler)
+			s.useRouter()))
 
-	enableAPILogging := s.config.EnableAPILogging
-	if enableAPILogging {
+	if s.config.AccessLogger != nil {
+		handler = s.useAccessLog(handler)
+	} else if s.config.EnableAPILogging {
 		handler = s.apiLoggingInfo(handler)
 	}
 
@@ -123,8 +115,6 @@ func (s *server) StartNonBlocking() error {
 
 	if s.config.PublicPort != nil {
 		publicHandler := s.usePublicRouter()
-		publicHandler = s.useMetrics(publicHandler)
-		publicHandler = s.useTracing(publicHandler)
 
 		healthServer := &fasthttp.Server{
 			Handler:            publicHandler,
@@ -188,28 +178,36 @@ func (s *server) Close() error {
 	return merr
 }
 
-func (s *server) useTracing(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	if diag_utils.IsTracingEnabled(s.tracingSpec.SamplingRate) {
-		log.Infof("enabled tracing http middleware")
-		return diag.HTTPTraceMiddleware(next, s.config.AppID, s.tracingSpec)
-	}
-	return next
-}
-
-func (s *server) useMetrics(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	if s.metricSpec.Enabled {
-		log.Infof("enabled metrics http middleware")
-
-		return diag.DefaultHTTPMonitoring.FastHTTPMiddleware(next)
+func (s *server) apiLoggingInfo(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		infoLog.Infof("HTTP API Called: %s %s", ctx.Method(), ctx.Path())
+		next(ctx)
 	}
-
-	return next
 }
 
-func (s *server) apiLoggingInfo(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+// useAccessLog logs the API calls to the access log. The route of a call is the path of
+// its endpoint, like /v1.0/state/{storeName}/{key}, or its path when none matches.
+func (s *server) useAccessLog(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
-		infoLog.Infof("HTTP API Called: %s %s", ctx.Method(), ctx.Path())
+		entry := &accesslog.Entry{
+			Time:     time.Now(),
+			Method:   string(ctx.Method()),
+			Path:     string(ctx.Path()),
+			Protocol: string(ctx.Request.Header.Protocol()),
+			TraceId: accesslog.TraceId(func(name string) string {
+				return string(ctx.Request.Header.Peek(name))
+			}),
+			BytesReceived: int64(len(ctx.Request.Body())),
+		}
 		next(ctx)
+		entry.Route, _ = ctx.UserValue(routing.MatchedRoutePathParam).(string)
+		if entry.Route == "" {
+			entry.Route = entry.Path
+		}
+		entry.Duration = time.Since(entry.Time)
+		entry.Status = ctx.Response.StatusCode()
+		entry.BytesSent = int64(len(ctx.Response.Body()))
+		s.config.AccessLogger.Log(entry)
 	}
 }
 
@@ -227,10 +225,6 @@ func (s *server) usePublicRouter() fasthttp.RequestHandler {
 	return router.Handler
 }
 
-func (s *server) useComponents(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	return s.pipeline.Apply(next)
-}
-
 func (s *server) useCors(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	if s.config.AllowedOrigins == cors_dapr.DefaultAllowedOrigins {
 		return next
@@ -297,6 +291,7 @@ func (s *server) unescapeRequestParametersHandler(next fasthttp.RequestHandler)
 
 func (s *server) getRouter(endpoints []Endpoint) *routing.Router {
 	router := routing.New()
+	router.SaveMatchedRoutePath = true
 	parameterFinder, _ := regexp.Compile("/{.*}")
 	for _, e := range endpoints {
 		if !s.endpointAllowed(e) {
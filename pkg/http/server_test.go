@@ -0,0 +1,66 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"group.rxcloud/capa/pkg/accesslog"
+)
+
+func TestAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := accesslog.New("app", &accesslog.Config{
+		Enabled: true,
+		Routes:  map[string]bool{"/v1.0/healthz": false},
+		Output:  accesslog.OutputFile,
+		File:    &accesslog.FileConfig{Path: path},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the access log: %v", err)
+	}
+	s := &server{api: NewAPI("app", nil, nil, nil, func() {}), config: ServerConfig{AccessLogger: logger}}
+	handler := s.useAccessLog(s.useRouter())
+	serve := func(method string, uri string, body string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.SetBodyString(body)
+		handler(ctx)
+	}
+	serve(fasthttp.MethodPut, "/v1.0/metadata/color", "blue")
+	serve(fasthttp.MethodGet, "/v1.0/healthz", "")
+	serve(fasthttp.MethodGet, "/v1.0/unknown", "")
+	logger.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid access log line %s: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected the disabled route not to be logged, got %d entries", len(entries))
+	}
+	put := entries[0]
+	if put[accesslog.FieldRoute] != "/v1.0/metadata/{key}" || put[accesslog.FieldPath] != "/v1.0/metadata/color" ||
+		put[accesslog.FieldMethod] != "PUT" || put[accesslog.FieldStatus] != float64(fasthttp.StatusNoContent) ||
+		put[accesslog.FieldBytesReceived] != float64(4) || put[accesslog.FieldAppId] != "app" {
+		t.Fatalf("unexpected entry %v", put)
+	}
+	if unknown := entries[1]; unknown[accesslog.FieldRoute] != "/v1.0/unknown" || unknown[accesslog.FieldStatus] != float64(fasthttp.StatusNotFound) {
+		t.Fatalf("expected the path as the route of an unknown endpoint, got %v", unknown)
+	}
+}
@@ -0,0 +1,363 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dapr/dapr/pkg/messages"
+	"github.com/valyala/fasthttp"
+
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/state/query"
+)
+
+// stateItem is a state saved by the app. Its value is any json value.
+type stateItem struct {
+	Key      string            `json:"key"`
+	Value    json.RawMessage   `json:"value,omitempty"`
+	ETag     *string           `json:"etag,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Options  *stateOptions     `json:"options,omitempty"`
+}
+
+type stateOptions struct {
+	Concurrency string `json:"concurrency,omitempty"`
+	Consistency string `json:"consistency,omitempty"`
+}
+
+type bulkGetRequest struct {
+	Keys        []string `json:"keys"`
+	Parallelism int      `json:"parallelism"`
+}
+
+type bulkGetItem struct {
+	Key      string            `json:"key"`
+	Data     json.RawMessage   `json:"data,omitempty"`
+	ETag     *string           `json:"etag,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type queryResponse struct {
+	Results  []queryItem       `json:"results"`
+	Token    string            `json:"token,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type queryItem struct {
+	Key   string          `json:"key"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	ETag  *string         `json:"etag,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+type transactionRequest struct {
+	Operations []transactionOperation `json:"operations"`
+	Metadata   map[string]string      `json:"metadata,omitempty"`
+}
+
+type transactionOperation struct {
+	Operation state.OperationType `json:"operation"`
+	Request   stateItem           `json:"request"`
+}
+
+func (a *api) constructStateEndpoints() []Endpoint {
+	return []Endpoint{
+		{
+			Methods: []string{fasthttp.MethodGet},
+			Route:   "state/{storeName}/{key}",
+			Version: apiVersionV1,
+			Handler: a.onGetState,
+		},
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "state/{storeName}",
+			Version: apiVersionV1,
+			Handler: a.onPostState,
+		},
+		{
+			Methods: []string{fasthttp.MethodDelete},
+			Route:   "state/{storeName}/{key}",
+			Version: apiVersionV1,
+			Handler: a.onDeleteState,
+		},
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "state/{storeName}/bulk",
+			Version: apiVersionV1,
+			Handler: a.onBulkGetState,
+		},
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "state/{storeName}/transaction",
+			Version: apiVersionV1,
+			Handler: a.onPostStateTransaction,
+		},
+		{
+			Methods: []string{fasthttp.MethodPost, fasthttp.MethodPut},
+			Route:   "state/{storeName}/query",
+			Version: apiVersionV1,
+			Handler: a.onQueryState,
+		},
+	}
+}
+
+// getStateStore returns the store of the request, and responds with the error when
+// the stores are not configured or the store is unknown.
+func (a *api) getStateStore(reqCtx *fasthttp.RequestCtx) (state.Store, string, bool) {
+	if len(a.stateStores) == 0 {
+		msg := NewErrorResponse("ERR_STATE_STORE_NOT_CONFIGURED", messages.ErrStateStoresNotConfigured)
+		respond(reqCtx, responseWithError(fasthttp.StatusInternalServerError, msg))
+		log.Debug(msg)
+		return nil, "", false
+	}
+	storeName := reqCtx.UserValue(storeNameParam).(string)
+	store, ok := a.stateStores[storeName]
+	if !ok {
+		msg := NewErrorResponse("ERR_STATE_STORE_NOT_FOUND", fmt.Sprintf(messages.ErrStateStoreNotFound, storeName))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return nil, "", false
+	}
+	return store, storeName, true
+}
+
+// stateErrorStatus returns the status of an error of a state store. A mismatched ETag
+// is a conflict.
+func stateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, state.ErrETagMismatch):
+		return fasthttp.StatusConflict
+	case errors.Is(err, state.ErrETagInvalid):
+		return fasthttp.StatusBadRequest
+	}
+	return fasthttp.StatusInternalServerError
+}
+
+func (a *api) onGetState(reqCtx *fasthttp.RequestCtx) {
+	store, storeName, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	key := reqCtx.UserValue(stateKeyParam).(string)
+	req := &state.GetRequest{
+		Key:      key,
+		Metadata: getMetadataFromRequest(reqCtx),
+		Options: state.GetStateOption{
+			Consistency: string(reqCtx.QueryArgs().Peek(consistencyParam)),
+		},
+	}
+	resp, err := store.Get(reqCtx, req)
+	if err != nil {
+		msg := NewErrorResponse("ERR_STATE_GET", fmt.Sprintf(messages.ErrStateGet, key, storeName, err.Error()))
+		respond(reqCtx, responseWithError(stateErrorStatus(err), msg))
+		log.Debug(msg)
+		return
+	}
+	if resp == nil || resp.Data == nil {
+		respond(reqCtx, responseWithEmpty())
+		return
+	}
+	if resp.ETag != nil {
+		reqCtx.Response.Header.Set(etagHeader, *resp.ETag)
+	}
+	respond(reqCtx, responseWithJSON(fasthttp.StatusOK, resp.Data), setRespMetadata(resp.Metadata))
+}
+
+func (a *api) onBulkGetState(reqCtx *fasthttp.RequestCtx) {
+	store, _, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	var req bulkGetRequest
+	if err := json.Unmarshal(reqCtx.PostBody(), &req); err != nil {
+		msg := NewErrorResponse("ERR_MALFORMED_REQUEST", fmt.Sprintf(messages.ErrMalformedRequest, err))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return
+	}
+	metadata := getMetadataFromRequest(reqCtx)
+	parallelism := req.Parallelism
+	if parallelism <= 0 {
+		parallelism = len(req.Keys)
+	}
+	items := make([]bulkGetItem, len(req.Keys))
+	limiter := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, key := range req.Keys {
+		wg.Add(1)
+		limiter <- struct{}{}
+		go func(i int, key string) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			item := bulkGetItem{Key: key}
+			resp, err := store.Get(reqCtx, &state.GetRequest{Key: key, Metadata: metadata})
+			if err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Data = resp.Data
+				item.ETag = resp.ETag
+				item.Metadata = resp.Metadata
+			}
+			items[i] = item
+		}(i, key)
+	}
+	wg.Wait()
+	b, _ := json.Marshal(items)
+	respond(reqCtx, responseWithJSON(fasthttp.StatusOK, b))
+}
+
+func (a *api) onPostState(reqCtx *fasthttp.RequestCtx) {
+	store, storeName, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	var items []stateItem
+	if err := json.Unmarshal(reqCtx.PostBody(), &items); err != nil {
+		msg := NewErrorResponse("ERR_MALFORMED_REQUEST", fmt.Sprintf(messages.ErrMalformedRequest, err))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return
+	}
+	for _, item := range items {
+		if item.Key == "" {
+			msg := NewErrorResponse("ERR_MALFORMED_REQUEST", "the key is required")
+			respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+			log.Debug(msg)
+			return
+		}
+		if err := store.Set(reqCtx, item.setRequest()); err != nil {
+			msg := NewErrorResponse("ERR_STATE_SAVE", fmt.Sprintf(messages.ErrStateSave, storeName, err.Error()))
+			respond(reqCtx, responseWithError(stateErrorStatus(err), msg))
+			log.Debug(msg)
+			return
+		}
+	}
+	respond(reqCtx, responseWithEmpty())
+}
+
+func (a *api) onDeleteState(reqCtx *fasthttp.RequestCtx) {
+	store, _, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	key := reqCtx.UserValue(stateKeyParam).(string)
+	req := &state.DeleteRequest{
+		Key:      key,
+		Metadata: getMetadataFromRequest(reqCtx),
+		Options: state.DeleteStateOption{
+			Concurrency: string(reqCtx.QueryArgs().Peek(concurrencyParam)),
+			Consistency: string(reqCtx.QueryArgs().Peek(consistencyParam)),
+		},
+	}
+	if etag := reqCtx.Request.Header.Peek(ifMatchHeader); len(etag) > 0 {
+		value := string(etag)
+		req.ETag = &value
+	}
+	if err := store.Delete(reqCtx, req); err != nil {
+		msg := NewErrorResponse("ERR_STATE_DELETE", fmt.Sprintf(messages.ErrStateDelete, key, err.Error()))
+		respond(reqCtx, responseWithError(stateErrorStatus(err), msg))
+		log.Debug(msg)
+		return
+	}
+	respond(reqCtx, responseWithEmpty())
+}
+
+func (a *api) onPostStateTransaction(reqCtx *fasthttp.RequestCtx) {
+	store, storeName, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	transactional, ok := store.(state.TransactionalStore)
+	if !ok || !state.FeatureTransactional.IsPresent(store.Features()) {
+		msg := NewErrorResponse("ERR_STATE_STORE_NOT_SUPPORTED", fmt.Sprintf(messages.ErrStateStoreNotSupported, storeName))
+		respond(reqCtx, responseWithError(fasthttp.StatusInternalServerError, msg))
+		log.Debug(msg)
+		return
+	}
+	var req transactionRequest
+	if err := json.Unmarshal(reqCtx.PostBody(), &req); err != nil {
+		msg := NewErrorResponse("ERR_MALFORMED_REQUEST", fmt.Sprintf(messages.ErrMalformedRequest, err))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return
+	}
+	operations := make([]state.TransactionalStateOperation, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		var request interface{}
+		switch op.Operation {
+		case state.Upsert:
+			request = op.Request.setRequest()
+		case state.Delete:
+			request = op.Request.deleteRequest()
+		default:
+			msg := NewErrorResponse("ERR_NOT_SUPPORTED_STATE_OPERATION", fmt.Sprintf(messages.ErrNotSupportedStateOperation, op.Operation))
+			respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+			log.Debug(msg)
+			return
+		}
+		operations = append(operations, state.TransactionalStateOperation{Operation: op.Operation, Request: request})
+	}
+	err := transactional.Multi(reqCtx, &state.TransactionalStateRequest{Operations: operations, Metadata: req.Metadata})
+	if err != nil {
+		msg := NewErrorResponse("ERR_STATE_TRANSACTION", fmt.Sprintf(messages.ErrStateTransaction, err.Error()))
+		respond(reqCtx, responseWithError(stateErrorStatus(err), msg))
+		log.Debug(msg)
+		return
+	}
+	respond(reqCtx, responseWithEmpty())
+}
+
+func (a *api) onQueryState(reqCtx *fasthttp.RequestCtx) {
+	store, storeName, ok := a.getStateStore(reqCtx)
+	if !ok {
+		return
+	}
+	q, err := query.Parse(reqCtx.PostBody())
+	if err != nil {
+		msg := NewErrorResponse("ERR_MALFORMED_REQUEST", fmt.Sprintf(messages.ErrMalformedRequest, err))
+		respond(reqCtx, responseWithError(fasthttp.StatusBadRequest, msg))
+		log.Debug(msg)
+		return
+	}
+	resp, err := state.Query(reqCtx, store, &state.QueryRequest{Query: q, Metadata: getMetadataFromRequest(reqCtx)})
+	if err != nil {
+		code := fasthttp.StatusInternalServerError
+		switch {
+		case errors.Is(err, state.ErrQueryNotSupported):
+			code = fasthttp.StatusNotImplemented
+		case errors.Is(err, query.ErrInvalidQuery):
+			code = fasthttp.StatusBadRequest
+		}
+		msg := NewErrorResponse("ERR_STATE_QUERY", fmt.Sprintf(messages.ErrStateQuery, storeName, err.Error()))
+		respond(reqCtx, responseWithError(code, msg))
+		log.Debug(msg)
+		return
+	}
+	qresp := queryResponse{Results: make([]queryItem, 0, len(resp.Results)), Token: resp.Token, Metadata: resp.Metadata}
+	for _, item := range resp.Results {
+		qresp.Results = append(qresp.Results, queryItem{Key: item.Key, Data: item.Data, ETag: item.ETag, Error: item.Error})
+	}
+	b, _ := json.Marshal(qresp)
+	respond(reqCtx, responseWithJSON(fasthttp.StatusOK, b))
+}
+
+func (i *stateItem) setRequest() *state.SetRequest {
+	req := &state.SetRequest{Key: i.Key, Value: i.Value, ETag: i.ETag, Metadata: i.Metadata}
+	if i.Options != nil {
+		req.Options = state.SetStateOption{Concurrency: i.Options.Concurrency, Consistency: i.Options.Consistency}
+	}
+	return req
+}
+
+func (i *stateItem) deleteRequest() *state.DeleteRequest {
+	req := &state.DeleteRequest{Key: i.Key, ETag: i.ETag, Metadata: i.Metadata}
+	if i.Options != nil {
+		req.Options = state.DeleteStateOption{Concurrency: i.Options.Concurrency, Consistency: i.Options.Consistency}
+	}
+	return req
+}
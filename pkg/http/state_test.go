@@ -0,0 +1,154 @@
+package http
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/state/memory"
+)
+
+func newStateAPI(t *testing.T) API {
+	t.Helper()
+	store := memory.NewInMemoryStore()
+	if err := store.Init(state.Metadata{Name: "store"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewAPI("app", nil, map[string]state.Store{"store": store}, nil, func() {})
+}
+
+func expectStatus(t *testing.T, res *fasthttp.Response, status int) {
+	t.Helper()
+	if res.StatusCode() != status {
+		t.Fatalf("expected status %d, got %d: %s", status, res.StatusCode(), res.Body())
+	}
+}
+
+func TestStateSaveGetDelete(t *testing.T) {
+	a := newStateAPI(t)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "k", "value": {"n": 1}}]`), fasthttp.StatusNoContent)
+
+	res := call(t, a, fasthttp.MethodGet, "/v1.0/state/store/k", "")
+	expectStatus(t, res, fasthttp.StatusOK)
+	if string(res.Body()) != `{"n": 1}` {
+		t.Fatalf("expected the saved value, got %s", res.Body())
+	}
+	etag := string(res.Header.Peek(etagHeader))
+	if etag == "" {
+		t.Fatalf("expected the etag of the state")
+	}
+
+	// A write with a stale ETag conflicts.
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "k", "value": 2, "etag": "`+etag+`"}]`), fasthttp.StatusNoContent)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "k", "value": 3, "etag": "`+etag+`"}]`), fasthttp.StatusConflict)
+
+	expectStatus(t, call(t, a, fasthttp.MethodDelete, "/v1.0/state/store/k", ""), fasthttp.StatusNoContent)
+	expectStatus(t, call(t, a, fasthttp.MethodGet, "/v1.0/state/store/k", ""), fasthttp.StatusNoContent)
+}
+
+func TestStateDeleteIfMatch(t *testing.T) {
+	a := newStateAPI(t)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "k", "value": 1}]`), fasthttp.StatusNoContent)
+	etag := string(call(t, a, fasthttp.MethodGet, "/v1.0/state/store/k", "").Header.Peek(etagHeader))
+
+	s := &server{api: a}
+	del := func(ifMatch string) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodDelete)
+		ctx.Request.SetRequestURI("/v1.0/state/store/k")
+		ctx.Request.Header.Set(ifMatchHeader, ifMatch)
+		s.useRouter()(ctx)
+		return ctx.Response.StatusCode()
+	}
+	if status := del(etag + "0"); status != fasthttp.StatusConflict {
+		t.Fatalf("expected a mismatched etag to conflict, got %d", status)
+	}
+	if status := del(etag); status != fasthttp.StatusNoContent {
+		t.Fatalf("expected the delete with the etag to succeed, got %d", status)
+	}
+}
+
+func TestStateBulkGet(t *testing.T) {
+	a := newStateAPI(t)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "a", "value": "1"}, {"key": "b", "value": "2"}]`), fasthttp.StatusNoContent)
+
+	res := call(t, a, fasthttp.MethodPost, "/v1.0/state/store/bulk", `{"keys": ["a", "b", "c"], "parallelism": 2}`)
+	expectStatus(t, res, fasthttp.StatusOK)
+	var items []bulkGetItem
+	if err := json.Unmarshal(res.Body(), &items); err != nil {
+		t.Fatalf("invalid bulk response %s: %v", res.Body(), err)
+	}
+	if len(items) != 3 || string(items[0].Data) != `"1"` || string(items[1].Data) != `"2"` || items[2].Key != "c" || items[2].Data != nil {
+		t.Fatalf("unexpected items %s", res.Body())
+	}
+}
+
+func TestStateTransaction(t *testing.T) {
+	a := newStateAPI(t)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[{"key": "a", "value": 1}]`), fasthttp.StatusNoContent)
+
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store/transaction", `{"operations": [
+		{"operation": "upsert", "request": {"key": "b", "value": 2}},
+		{"operation": "delete", "request": {"key": "a"}}
+	]}`), fasthttp.StatusNoContent)
+	expectStatus(t, call(t, a, fasthttp.MethodGet, "/v1.0/state/store/a", ""), fasthttp.StatusNoContent)
+	expectStatus(t, call(t, a, fasthttp.MethodGet, "/v1.0/state/store/b", ""), fasthttp.StatusOK)
+
+	res := call(t, a, fasthttp.MethodPost, "/v1.0/state/store/transaction", `{"operations": [{"operation": "merge", "request": {"key": "a"}}]}`)
+	expectStatus(t, res, fasthttp.StatusBadRequest)
+}
+
+func TestStateQuery(t *testing.T) {
+	a := newStateAPI(t)
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store", `[
+		{"key": "a", "value": {"city": "Shanghai"}},
+		{"key": "b", "value": {"city": "Beijing"}}
+	]`), fasthttp.StatusNoContent)
+
+	res := call(t, a, fasthttp.MethodPost, "/v1.0/state/store/query", `{"filter": {"EQ": {"city": "Beijing"}}}`)
+	expectStatus(t, res, fasthttp.StatusOK)
+	qres := &queryResponse{}
+	if err := json.Unmarshal(res.Body(), qres); err != nil {
+		t.Fatalf("invalid query response %s: %v", res.Body(), err)
+	}
+	if len(qres.Results) != 1 || qres.Results[0].Key != "b" {
+		t.Fatalf("unexpected results %s", res.Body())
+	}
+
+	expectStatus(t, call(t, a, fasthttp.MethodPost, "/v1.0/state/store/query", `{"filter": `), fasthttp.StatusBadRequest)
+}
+
+func TestStateErrors(t *testing.T) {
+	a := newStateAPI(t)
+	cases := []struct {
+		name   string
+		api    API
+		method string
+		uri    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "not configured", api: NewAPI("app", nil, nil, nil, func() {}), method: fasthttp.MethodGet, uri: "/v1.0/state/store/k", status: fasthttp.StatusInternalServerError, code: "ERR_STATE_STORE_NOT_CONFIGURED"},
+		{name: "unknown store", api: a, method: fasthttp.MethodGet, uri: "/v1.0/state/other/k", status: fasthttp.StatusBadRequest, code: "ERR_STATE_STORE_NOT_FOUND"},
+		{name: "malformed", api: a, method: fasthttp.MethodPost, uri: "/v1.0/state/store", body: `{}`, status: fasthttp.StatusBadRequest, code: "ERR_MALFORMED_REQUEST"},
+		{name: "no key", api: a, method: fasthttp.MethodPost, uri: "/v1.0/state/store", body: `[{"value": 1}]`, status: fasthttp.StatusBadRequest, code: "ERR_MALFORMED_REQUEST"},
+		{name: "invalid etag", api: a, method: fasthttp.MethodPost, uri: "/v1.0/state/store", body: `[{"key": "k", "value": 1, "etag": "x"}]`, status: fasthttp.StatusBadRequest, code: "ERR_STATE_SAVE"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := call(t, c.api, c.method, c.uri, c.body)
+			expectStatus(t, res, c.status)
+			if !strings.Contains(string(res.Body()), c.code) {
+				t.Fatalf("expected %s, got %s", c.code, res.Body())
+			}
+		})
+	}
+}
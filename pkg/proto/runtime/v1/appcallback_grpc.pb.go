@@ -0,0 +1,186 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AppCallbackClient is the client API for AppCallback service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AppCallbackClient interface {
+	// Listens events from the input bindings
+	OnBindingEvent(ctx context.Context, in *BindingEventRequest, opts ...grpc.CallOption) (*BindingEventResponse, error)
+	// Lists all topics subscribed by this app. A topic of a pubsub has a single
+	// subscriber: its messages are delivered once to the app, not fanned out to
+	// several subscriptions. A topic listed again, or already subscribed by the
+	// runtime config, is ignored.
+	ListTopicSubscriptions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTopicSubscriptionsResponse, error)
+	// Subscribes events from Pubsub
+	OnTopicEvent(ctx context.Context, in *TopicEventRequest, opts ...grpc.CallOption) (*TopicEventResponse, error)
+}
+
+type appCallbackClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAppCallbackClient(cc grpc.ClientConnInterface) AppCallbackClient {
+	return &appCallbackClient{cc}
+}
+
+func (c *appCallbackClient) OnBindingEvent(ctx context.Context, in *BindingEventRequest, opts ...grpc.CallOption) (*BindingEventResponse, error) {
+	out := new(BindingEventResponse)
+	err := c.cc.Invoke(ctx, "/spec.proto.runtime.v1.AppCallback/OnBindingEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appCallbackClient) ListTopicSubscriptions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTopicSubscriptionsResponse, error) {
+	out := new(ListTopicSubscriptionsResponse)
+	err := c.cc.Invoke(ctx, "/spec.proto.runtime.v1.AppCallback/ListTopicSubscriptions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *appCallbackClient) OnTopicEvent(ctx context.Context, in *TopicEventRequest, opts ...grpc.CallOption) (*TopicEventResponse, error) {
+	out := new(TopicEventResponse)
+	err := c.cc.Invoke(ctx, "/spec.proto.runtime.v1.AppCallback/OnTopicEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AppCallbackServer is the server API for AppCallback service.
+// All implementations must embed UnimplementedAppCallbackServer
+// for forward compatibility
+type AppCallbackServer interface {
+	// Listens events from the input bindings
+	OnBindingEvent(context.Context, *BindingEventRequest) (*BindingEventResponse, error)
+	// Lists all topics subscribed by this app. A topic of a pubsub has a single
+	// subscriber: its messages are delivered once to the app, not fanned out to
+	// several subscriptions. A topic listed again, or already subscribed by the
+	// runtime config, is ignored.
+	ListTopicSubscriptions(context.Context, *emptypb.Empty) (*ListTopicSubscriptionsResponse, error)
+	// Subscribes events from Pubsub
+	OnTopicEvent(context.Context, *TopicEventRequest) (*TopicEventResponse, error)
+	mustEmbedUnimplementedAppCallbackServer()
+}
+
+// UnimplementedAppCallbackServer must be embedded to have forward compatible implementations.
+type UnimplementedAppCallbackServer struct {
+}
+
+func (UnimplementedAppCallbackServer) OnBindingEvent(context.Context, *BindingEventRequest) (*BindingEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnBindingEvent not implemented")
+}
+func (UnimplementedAppCallbackServer) ListTopicSubscriptions(context.Context, *emptypb.Empty) (*ListTopicSubscriptionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTopicSubscriptions not implemented")
+}
+func (UnimplementedAppCallbackServer) OnTopicEvent(context.Context, *TopicEventRequest) (*TopicEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OnTopicEvent not implemented")
+}
+func (UnimplementedAppCallbackServer) mustEmbedUnimplementedAppCallbackServer() {}
+
+// UnsafeAppCallbackServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AppCallbackServer will
+// result in compilation errors.
+type UnsafeAppCallbackServer interface {
+	mustEmbedUnimplementedAppCallbackServer()
+}
+
+func RegisterAppCallbackServer(s grpc.ServiceRegistrar, srv AppCallbackServer) {
+	s.RegisterService(&AppCallback_ServiceDesc, srv)
+}
+
+func _AppCallback_OnBindingEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BindingEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppCallbackServer).OnBindingEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spec.proto.runtime.v1.AppCallback/OnBindingEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppCallbackServer).OnBindingEvent(ctx, req.(*BindingEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppCallback_ListTopicSubscriptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppCallbackServer).ListTopicSubscriptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spec.proto.runtime.v1.AppCallback/ListTopicSubscriptions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppCallbackServer).ListTopicSubscriptions(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AppCallback_OnTopicEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppCallbackServer).OnTopicEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spec.proto.runtime.v1.AppCallback/OnTopicEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppCallbackServer).OnTopicEvent(ctx, req.(*TopicEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AppCallback_ServiceDesc is the grpc.ServiceDesc for AppCallback service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AppCallback_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spec.proto.runtime.v1.AppCallback",
+	HandlerType: (*AppCallbackServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "OnBindingEvent",
+			Handler:    _AppCallback_OnBindingEvent_Handler,
+		},
+		{
+			MethodName: "ListTopicSubscriptions",
+			Handler:    _AppCallback_ListTopicSubscriptions_Handler,
+		},
+		{
+			MethodName: "OnTopicEvent",
+			Handler:    _AppCallback_OnTopicEvent_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "appcallback.proto",
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"group.rxcloud/capa/pkg/accesslog"
+)
+
+// SetNode sets the node of the proxy. Its app id is logged in the access log.
+func (p *Proxy) SetNode(node *Node) {
+	p.node.Store(node)
+}
+
+// Node returns the node of the proxy, nil when not set.
+func (p *Proxy) Node() *Node {
+	node, _ := p.node.Load().(*Node)
+	return node
+}
+
+func (p *Proxy) accessLogger() *accesslog.Logger {
+	l, _ := p.accessLog.Load().(*accesslog.Logger)
+	return l
+}
+
+func newAccessEntry(req *http.Request) *accesslog.Entry {
+	return &accesslog.Entry{
+		Time:     time.Now(),
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Protocol: req.Proto,
+		TraceId:  accesslog.TraceId(req.Header.Get),
+	}
+}
+
+// logAccess completes the entry with the response of the request and logs it.
+func (p *Proxy) logAccess(entry *accesslog.Entry, w *accessWriter, body *countingBody) {
+	l := p.accessLogger()
+	if l == nil {
+		return
+	}
+	if node := p.Node(); node != nil {
+		entry.AppId = node.AppId
+	}
+	entry.Duration = time.Since(entry.Time)
+	// gRPC requests already carry their gRPC status.
+	if entry.Status == 0 {
+		entry.Status = w.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+	}
+	entry.BytesSent = atomic.LoadInt64(&w.written)
+	entry.BytesReceived = atomic.LoadInt64(&w.read)
+	if body != nil {
+		entry.BytesReceived += atomic.LoadInt64(&body.read)
+	}
+	l.Log(entry)
+}
+
+// nameOrEmpty returns the name of the route, empty for a nil route.
+func (r *route) nameOrEmpty() string {
+	if r == nil {
+		return ""
+	}
+	return r.name
+}
+
+// accessWriter records the status and the size of a response. Hijacked connections
+// count the bytes exchanged with the client after the switch of protocol.
+type accessWriter struct {
+	http.ResponseWriter
+
+	status  int
+	written int64
+	read    int64
+}
+
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	atomic.AddInt64(&w.written, int64(n))
+	return n, err
+}
+
+func (w *accessWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer doesn't support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.status = http.StatusSwitchingProtocols
+	counted := &countingConn{Conn: conn, written: &w.written, read: &w.read}
+	rw.Writer.Reset(counted)
+	return counted, rw, nil
+}
+
+// countingConn counts the bytes read from and written to a hijacked connection.
+type countingConn struct {
+	net.Conn
+	written *int64
+	read    *int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+// CloseWrite half-closes the connection when supported, and closes it otherwise.
+func (c *countingConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.read, int64(n))
+	return n, err
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// adminHealthPath is the health check of the proxy on the admin API. It is served on its
+// own listener, so that the app paths served by the proxy are left alone.
+const adminHealthPath = "/healthz"
+
+// AdminHandler returns the handler of the proxy admin API.
+// It should only be served on a loopback address.
+func (p *Proxy) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminHealthPath, p.onHealthz)
+	mux.HandleFunc("/clusters", p.onGetClusters)
+	mux.HandleFunc("/circuit_breakers", p.onGetCircuitBreakers)
+	mux.HandleFunc("/faults", p.onFaults)
+	mux.HandleFunc("/faults/", p.onDeleteFault)
+	mux.HandleFunc("/bypass", p.onGetBypass)
+	mux.HandleFunc("/tcp", p.onGetTCP)
+	mux.HandleFunc("/capture", p.onGetCapture)
+	mux.HandleFunc("/node", p.onGetNode)
+	return mux
+}
+
+// onHealthz answers OK while the proxy server accepts connections.
+func (p *Proxy) onHealthz(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&p.serving) == 0 {
+		http.Error(w, "the proxy is not serving", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *Proxy) onGetClusters(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.ClusterStates())
+}
+
+func (p *Proxy) onGetNode(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.Node())
+}
+
+func (p *Proxy) onGetCircuitBreakers(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.CircuitStates())
+}
+
+// onFaults lists the active faults on GET, and enables a fault on POST.
+func (p *Proxy) onFaults(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, p.ActiveFaults())
+	case http.MethodPost:
+		fc := &FaultConfig{}
+		if err := json.NewDecoder(req.Body).Decode(fc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		active, err := p.EnableFault(fc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, http.StatusOK, active)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// onDeleteFault disables the fault named by the path, e.g. DELETE /faults/{name}.
+func (p *Proxy) onDeleteFault(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/faults/")
+	if !p.DisableFault(name) {
+		http.Error(w, "fault not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Proxy) onGetBypass(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.BypassState())
+}
+
+func (p *Proxy) onGetTCP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.TCPStats())
+}
+
+func (p *Proxy) onGetCapture(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, http.StatusOK, p.CaptureState())
+}
+
+// respondJSON writes the object as a json response.
+func respondJSON(w http.ResponseWriter, code int, obj interface{}) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
@@ -0,0 +1,341 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// BypassPassthrough forwards every request as is to the local service, skipping
+	// routing, faults, mirroring and retries.
+	BypassPassthrough = "passthrough"
+	// BypassRedirect removes the jump rules to the iptables chains set up by capa init,
+	// so the traffic doesn't reach the proxy at all. Requests still reaching the proxy
+	// are passed through.
+	BypassRedirect = "redirect"
+
+	defaultBypassCheckInterval      = 5 * time.Second
+	defaultBypassCoolDown           = 30 * time.Second
+	defaultBypassErrorRate          = 0.5
+	defaultBypassMinRequests        = 20
+	defaultBypassUnhealthyChecks    = 3
+	defaultBypassHealthyChecks      = 3
+	defaultBypassHealthCheckTimeout = time.Second
+)
+
+var (
+	bypassSwitchCount = stats.Int64("capa/proxy/bypass_switch_count", "The number of switches of the proxy bypass mode.", stats.UnitDimensionless)
+	bypassActiveState = stats.Int64("capa/proxy/bypass_active", "Whether the proxy is in bypass mode.", stats.UnitDimensionless)
+
+	bypassReasonKey = tag.MustNewKey("reason")
+	bypassActiveKey = tag.MustNewKey("active")
+)
+
+// The reasons of the bypass switches, tagged on the switch metric.
+const (
+	bypassReasonHealthCheck = "health_check"
+	bypassReasonErrorRate   = "error_rate"
+	bypassReasonRecovered   = "recovered"
+	bypassReasonDisabled    = "disabled"
+)
+
+func init() {
+	err := view.Register(
+		&view.View{
+			Name:        bypassSwitchCount.Name(),
+			Description: bypassSwitchCount.Description(),
+			Measure:     bypassSwitchCount,
+			TagKeys:     []tag.Key{bypassActiveKey, bypassReasonKey},
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        bypassActiveState.Name(),
+			Description: bypassActiveState.Description(),
+			Measure:     bypassActiveState,
+			Aggregation: view.LastValue(),
+		},
+	)
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to register bypass metrics: %v", err)
+	}
+}
+
+// BypassConfig makes the proxy fail open: when its own health checks fail or its error
+// rate is above a threshold, it bypasses the L7 interception until it recovers.
+type BypassConfig struct {
+	// Mode is one of passthrough or redirect. Defaults to passthrough.
+	Mode string `json:"mode,omitempty"`
+	// HealthCheckURL is checked on every interval, defaults to the health check of the
+	// admin API, which fails once the proxy stops serving.
+	HealthCheckURL string   `json:"health_check_url,omitempty"`
+	CheckInterval  Duration `json:"check_interval,omitempty"`
+	// UnhealthyChecks is the number of consecutive failed health checks to bypass after.
+	UnhealthyChecks int `json:"unhealthy_checks,omitempty"`
+	// HealthyChecks is the number of consecutive healthy checks to recover after.
+	HealthyChecks int `json:"healthy_checks,omitempty"`
+	// ErrorRate is the rate of requests failed by the proxy to bypass after, in (0, 1].
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// MinRequests is the number of requests in an interval below which the error rate is ignored.
+	MinRequests int64 `json:"min_requests,omitempty"`
+	// CoolDown is the shortest time spent in bypass mode.
+	CoolDown Duration `json:"cool_down,omitempty"`
+}
+
+// BypassState is the bypass state of the proxy, as shown on the admin API.
+type BypassState struct {
+	Enabled bool      `json:"enabled"`
+	Active  bool      `json:"active"`
+	Mode    string    `json:"mode,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// bypassSwitch holds the bypass state. The zero value is disabled.
+type bypassSwitch struct {
+	sync.Mutex
+
+	config *BypassConfig
+	// active is read on every request, so it is accessed atomically.
+	active int32
+	reason string
+	since  time.Time
+
+	// requests and errors count the requests of the current interval.
+	requests int64
+	errors   int64
+
+	unhealthyChecks int
+	healthyChecks   int
+}
+
+func validateBypass(bc *BypassConfig) error {
+	if bc == nil {
+		return nil
+	}
+	switch bc.Mode {
+	case "", BypassPassthrough, BypassRedirect:
+	default:
+		return fmt.Errorf("bypass: unknown mode %q", bc.Mode)
+	}
+	if bc.ErrorRate < 0 || bc.ErrorRate > 1 {
+		return fmt.Errorf("bypass: invalid error rate %v", bc.ErrorRate)
+	}
+	if bc.CheckInterval < 0 || bc.CoolDown < 0 || bc.UnhealthyChecks < 0 || bc.HealthyChecks < 0 || bc.MinRequests < 0 {
+		return fmt.Errorf("bypass: intervals and thresholds must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the config with the unset fields defaulted.
+func (bc BypassConfig) withDefaults() *BypassConfig {
+	if bc.Mode == "" {
+		bc.Mode = BypassPassthrough
+	}
+	if bc.HealthCheckURL == "" {
+		bc.HealthCheckURL = fmt.Sprintf("http://127.0.0.1:%d%s", adminPort, adminHealthPath)
+	}
+	if bc.CheckInterval == 0 {
+		bc.CheckInterval = Duration(defaultBypassCheckInterval)
+	}
+	if bc.UnhealthyChecks == 0 {
+		bc.UnhealthyChecks = defaultBypassUnhealthyChecks
+	}
+	if bc.HealthyChecks == 0 {
+		bc.HealthyChecks = defaultBypassHealthyChecks
+	}
+	if bc.ErrorRate == 0 {
+		bc.ErrorRate = defaultBypassErrorRate
+	}
+	if bc.MinRequests == 0 {
+		bc.MinRequests = defaultBypassMinRequests
+	}
+	if bc.CoolDown == 0 {
+		bc.CoolDown = Duration(defaultBypassCoolDown)
+	}
+	return &bc
+}
+
+func (b *bypassSwitch) setConfig(bc *BypassConfig) {
+	b.Lock()
+	defer b.Unlock()
+	if bc == nil {
+		b.config = nil
+		return
+	}
+	b.config = bc.withDefaults()
+}
+
+// isActive reports whether the requests bypass the L7 interception.
+func (b *bypassSwitch) isActive() bool {
+	return atomic.LoadInt32(&b.active) == 1
+}
+
+// record counts a request handled by the proxy, and whether the proxy failed it.
+func (b *bypassSwitch) record(failed bool) {
+	atomic.AddInt64(&b.requests, 1)
+	if failed {
+		atomic.AddInt64(&b.errors, 1)
+	}
+}
+
+// BypassState returns the bypass state of the proxy.
+func (p *Proxy) BypassState() BypassState {
+	p.bypass.Lock()
+	defer p.bypass.Unlock()
+
+	state := BypassState{
+		Enabled: p.bypass.config != nil,
+		Active:  p.bypass.isActive(),
+		Reason:  p.bypass.reason,
+		Since:   p.bypass.since,
+	}
+	if p.bypass.config != nil {
+		state.Mode = p.bypass.config.Mode
+	}
+	return state
+}
+
+// MonitorBypass checks the proxy health on every interval and switches the bypass mode
+// accordingly, until the context is done. It does nothing while bypass isn't configured.
+func (p *Proxy) MonitorBypass(ctx context.Context) {
+	for {
+		interval := defaultBypassCheckInterval
+		p.bypass.Lock()
+		if p.bypass.config != nil {
+			interval = time.Duration(p.bypass.config.CheckInterval)
+		}
+		p.bypass.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			p.checkBypass(ctx)
+		}
+	}
+}
+
+func (p *Proxy) checkBypass(ctx context.Context) {
+	p.bypass.Lock()
+	bc := p.bypass.config
+	p.bypass.Unlock()
+
+	requests := atomic.SwapInt64(&p.bypass.requests, 0)
+	errors := atomic.SwapInt64(&p.bypass.errors, 0)
+	if bc == nil {
+		if p.bypass.isActive() {
+			p.switchBypass(false, bypassReasonDisabled, "bypass is no longer configured", bc)
+		}
+		return
+	}
+	healthErr := checkHealth(ctx, bc.HealthCheckURL)
+
+	p.bypass.Lock()
+	if healthErr != nil {
+		p.bypass.unhealthyChecks++
+		p.bypass.healthyChecks = 0
+	} else {
+		p.bypass.unhealthyChecks = 0
+		p.bypass.healthyChecks++
+	}
+	unhealthyChecks, healthyChecks, since := p.bypass.unhealthyChecks, p.bypass.healthyChecks, p.bypass.since
+	p.bypass.Unlock()
+
+	if !p.bypass.isActive() {
+		switch {
+		case unhealthyChecks >= bc.UnhealthyChecks:
+			p.switchBypass(true, bypassReasonHealthCheck, fmt.Sprintf("health check failed: %v", healthErr), bc)
+		case requests >= bc.MinRequests && float64(errors)/float64(requests) > bc.ErrorRate:
+			p.switchBypass(true, bypassReasonErrorRate, fmt.Sprintf("error rate %d/%d is above %v", errors, requests, bc.ErrorRate), bc)
+		}
+		return
+	}
+	if healthyChecks >= bc.HealthyChecks && time.Since(since) >= time.Duration(bc.CoolDown) {
+		p.switchBypass(false, bypassReasonRecovered, "health checks passed", bc)
+	}
+}
+
+// switchBypass enters or leaves the bypass mode, logging and exporting the switch.
+func (p *Proxy) switchBypass(active bool, reason string, detail string, bc *BypassConfig) {
+	if active {
+		log.Warnf("[Capa.proxy] proxy enters bypass mode, reason: %s, %s", reason, detail)
+	} else {
+		log.Infof("[Capa.proxy] proxy leaves bypass mode, reason: %s, %s", reason, detail)
+	}
+
+	if bc != nil && bc.Mode == BypassRedirect {
+		update := RestoreRedirect
+		if active {
+			update = RemoveRedirect
+		}
+		if err := update(); err != nil {
+			log.Errorf("[Capa.proxy] failed to update the redirect rules: %v", err)
+		}
+	}
+
+	var value int32
+	if active {
+		value = 1
+	}
+	p.bypass.Lock()
+	atomic.StoreInt32(&p.bypass.active, value)
+	p.bypass.reason = detail
+	p.bypass.since = time.Now()
+	p.bypass.healthyChecks = 0
+	p.bypass.unhealthyChecks = 0
+	p.bypass.Unlock()
+
+	ctx, err := tag.New(context.Background(),
+		tag.Upsert(bypassActiveKey, fmt.Sprint(active)),
+		tag.Upsert(bypassReasonKey, reason))
+	if err != nil {
+		ctx = context.Background()
+	}
+	stats.Record(ctx, bypassSwitchCount.M(1), bypassActiveState.M(int64(value)))
+}
+
+func checkHealth(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultBypassHealthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// passthrough forwards the request as is to the local service. It is still recorded
+// by the capture, but never replayed.
+func (p *Proxy) passthrough(w http.ResponseWriter, req *http.Request) {
+	var x *exchange
+	if c := p.capturing(req); c != nil && c.config.Mode == CaptureRecord {
+		x = c.start(req)
+	}
+	local, _ := p.routeTable().cluster(defaultClusterName)
+	res, err := p.send(req.Context(), req, local)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if x != nil {
+		x.finish(res)
+	}
+	p.writeResponse(w, res)
+}
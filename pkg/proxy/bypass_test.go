@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAdminHealthz(t *testing.T) {
+	p := &Proxy{}
+	admin := p.AdminHandler()
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, adminHealthPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a proxy not serving to be unhealthy, got %d", w.Code)
+	}
+
+	atomic.StoreInt32(&p.serving, 1)
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, adminHealthPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected a serving proxy to be healthy, got %d", w.Code)
+	}
+}
+
+func TestBypassDefaultHealthCheckUsesAdmin(t *testing.T) {
+	bc := BypassConfig{}.withDefaults()
+	if bc.HealthCheckURL != "http://127.0.0.1:8001/healthz" {
+		t.Fatalf("expected the admin health check, got %s", bc.HealthCheckURL)
+	}
+}
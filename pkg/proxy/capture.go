@@ -0,0 +1,643 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// CaptureRecord records the matching requests and their responses to the capture file.
+	CaptureRecord = "record"
+	// CaptureReplay answers the matching requests from the capture file, without
+	// calling the upstream.
+	CaptureReplay = "replay"
+
+	// CaptureJSONLines appends a HAR entry per line while recording. CaptureHAR keeps
+	// the entries in memory and writes them as a HAR 1.2 document when the capture
+	// stops, replacing the file.
+	CaptureJSONLines = "jsonl"
+	CaptureHAR       = "har"
+
+	// replayHeader marks the responses answered from the capture file.
+	replayHeader  = "X-Capa-Replay"
+	redactedValue = "[REDACTED]"
+
+	defaultCaptureMaxBodyBytes = 64 * 1024
+	defaultCaptureMaxEntries   = 10000
+	// defaultHARMaxEntries is lower, as the HAR entries are kept in memory.
+	defaultHARMaxEntries = 1000
+)
+
+// defaultRedactedHeaders are always redacted from the recordings.
+// The redacted names apply to the query parameters too.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// CaptureConfig records request/response pairs to a file, or replays them from it.
+type CaptureConfig struct {
+	// Mode is one of record or replay.
+	Mode string `json:"mode"`
+	// Format is one of jsonl or har. Defaults to jsonl.
+	Format string `json:"format,omitempty"`
+	File   string `json:"file"`
+	// Match selects the captured requests. Empty criteria capture every request.
+	Match *RouteMatch `json:"match,omitempty"`
+	// RedactHeaders are redacted from the recordings, on top of the Authorization,
+	// Proxy-Authorization, Cookie and Set-Cookie headers. The query parameters of
+	// the same names, e.g. token or api_key, are redacted too.
+	RedactHeaders []string `json:"redact_headers,omitempty"`
+	// MaxBodyBytes truncates the recorded bodies, 64KiB by default.
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+	// MaxEntries stops the recording after that many entries, 10000 by default,
+	// or 1000 for the HAR format.
+	MaxEntries int `json:"max_entries,omitempty"`
+}
+
+// CaptureState is the state of the capture, as shown on the admin API.
+type CaptureState struct {
+	Mode    string `json:"mode"`
+	Format  string `json:"format"`
+	File    string `json:"file"`
+	Entries int    `json:"entries"`
+}
+
+// harLog is the HAR 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/.
+type harLog struct {
+	Log struct {
+		Version string      `json:"version"`
+		Creator harCreator  `json:"creator"`
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text"`
+	Encoding  string `json:"_encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+type harContent struct {
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text"`
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// capturer records or replays the requests matching its config.
+type capturer struct {
+	*requestMatcher
+
+	// source is the config the capturer was created from, config the one with the defaults.
+	source CaptureConfig
+	config *CaptureConfig
+	redact map[string]bool
+
+	sync.Mutex
+	// count is the number of recorded entries. The entries are only kept in memory
+	// for the HAR format and for replays.
+	count   int
+	entries []*harEntry
+	// file is the jsonl file being recorded. The HAR document is written as a whole
+	// by stop when dirty, as it can't be appended to.
+	file  *os.File
+	dirty bool
+
+	// replays holds the recorded entries by replayKey, served in order.
+	replays map[string][]*harEntry
+	served  map[string]int
+}
+
+func validateCapture(cc *CaptureConfig) error {
+	if cc == nil {
+		return nil
+	}
+	if cc.Mode != CaptureRecord && cc.Mode != CaptureReplay {
+		return fmt.Errorf("capture: unknown mode %q", cc.Mode)
+	}
+	if cc.Format != "" && cc.Format != CaptureJSONLines && cc.Format != CaptureHAR {
+		return fmt.Errorf("capture: unknown format %q", cc.Format)
+	}
+	if cc.File == "" {
+		return fmt.Errorf("capture: file is required")
+	}
+	if cc.MaxBodyBytes < 0 || cc.MaxEntries < 0 {
+		return fmt.Errorf("capture: max_body_bytes and max_entries must not be negative")
+	}
+	return nil
+}
+
+// newCapturer opens the capture file, to record to it or to load the entries to replay.
+func newCapturer(cc *CaptureConfig) (*capturer, error) {
+	if cc == nil {
+		return nil, nil
+	}
+	if err := validateCapture(cc); err != nil {
+		return nil, err
+	}
+	matcher, err := compileMatch(cc.Match)
+	if err != nil {
+		return nil, fmt.Errorf("capture: %v", err)
+	}
+	config := *cc
+	if config.Format == "" {
+		config.Format = CaptureJSONLines
+	}
+	if config.MaxBodyBytes == 0 {
+		config.MaxBodyBytes = defaultCaptureMaxBodyBytes
+	}
+	if config.MaxEntries == 0 {
+		config.MaxEntries = defaultCaptureMaxEntries
+		if config.Format == CaptureHAR {
+			config.MaxEntries = defaultHARMaxEntries
+		}
+	}
+
+	c := &capturer{
+		requestMatcher: matcher,
+		source:         *cc,
+		config:         &config,
+		redact:         map[string]bool{},
+	}
+	for _, name := range append(defaultRedactedHeaders, cc.RedactHeaders...) {
+		c.redact[http.CanonicalHeaderKey(name)] = true
+	}
+
+	if config.Mode == CaptureReplay {
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("capture: failed to load %s: %v", config.File, err)
+		}
+		return c, nil
+	}
+
+	if config.Format == CaptureJSONLines {
+		c.file, err = os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("capture: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// reusable reports whether the capturer can be kept for the new config, to carry on
+// its recording or its replays across config updates.
+func (c *capturer) reusable(cc *CaptureConfig) bool {
+	return c != nil && cc != nil && reflect.DeepEqual(c.source, *cc)
+}
+
+// load reads the entries to replay from the capture file.
+func (c *capturer) load() error {
+	data, err := os.ReadFile(c.config.File)
+	if err != nil {
+		return err
+	}
+	var entries []*harEntry
+	if c.config.Format == CaptureHAR {
+		doc := &harLog{}
+		if err := json.Unmarshal(data, doc); err != nil {
+			return err
+		}
+		entries = doc.Log.Entries
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			entry := &harEntry{}
+			if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	c.count = len(entries)
+	c.entries = entries
+	c.replays = map[string][]*harEntry{}
+	c.served = map[string]int{}
+	for _, entry := range entries {
+		key, err := c.replayKeyOfURL(entry.Request.Method, entry.Request.URL)
+		if err != nil {
+			return err
+		}
+		c.replays[key] = append(c.replays[key], entry)
+	}
+	return nil
+}
+
+// stop closes the jsonl file, or writes the HAR document.
+func (c *capturer) stop() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	if c.dirty {
+		c.writeHAR()
+	}
+}
+
+// writeHAR replaces the HAR document atomically. The lock must be held.
+func (c *capturer) writeHAR() {
+	c.dirty = false
+	doc := &harLog{}
+	doc.Log.Version = "1.2"
+	doc.Log.Creator = harCreator{Name: "capa-proxy", Version: "1.0"}
+	doc.Log.Entries = c.entries
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to encode the capture: %v", err)
+		return
+	}
+	tmp := filepath.Join(filepath.Dir(c.config.File), "."+filepath.Base(c.config.File)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Warnf("[Capa.proxy] failed to write the capture file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, c.config.File); err != nil {
+		log.Warnf("[Capa.proxy] failed to write the capture file: %v", err)
+	}
+}
+
+// record appends an entry to the capture file.
+func (c *capturer) record(entry *harEntry) {
+	c.Lock()
+	defer c.Unlock()
+	if c.count >= c.config.MaxEntries {
+		if c.count == c.config.MaxEntries {
+			log.Warnf("[Capa.proxy] capture reached %d entries, stop recording", c.config.MaxEntries)
+			c.count++
+		}
+		return
+	}
+	c.count++
+
+	if c.config.Format == CaptureHAR {
+		c.entries = append(c.entries, entry)
+		c.dirty = true
+		return
+	}
+	if c.file == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to encode the capture: %v", err)
+		return
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		log.Warnf("[Capa.proxy] failed to write the capture file: %v", err)
+	}
+}
+
+func (c *capturer) state() *CaptureState {
+	c.Lock()
+	defer c.Unlock()
+	entries := c.count
+	if entries > c.config.MaxEntries {
+		entries = c.config.MaxEntries
+	}
+	return &CaptureState{Mode: c.config.Mode, Format: c.config.Format, File: c.config.File, Entries: entries}
+}
+
+// replay answers the request from the recorded entries with the same method, path and
+// query, the redacted query parameters matching any value. The entries are served in
+// order, the last one being repeated.
+func (c *capturer) replay(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	c.redactQuery(query)
+	key := replayKey(req.Method, req.URL.Path, query.Encode())
+	c.Lock()
+	entries := c.replays[key]
+	if len(entries) == 0 {
+		c.Unlock()
+		http.Error(w, fmt.Sprintf("no recorded response for %s %s", req.Method, req.URL.RequestURI()), http.StatusBadGateway)
+		return
+	}
+	entry := entries[c.served[key]]
+	if c.served[key] < len(entries)-1 {
+		c.served[key]++
+	}
+	c.Unlock()
+
+	// A truncated body would be answered as if complete.
+	if entry.Response.Content.Truncated {
+		log.Errorf("[Capa.proxy] can't replay %s %s: its recorded response body was truncated at %d bytes, raise max_body_bytes and record again",
+			req.Method, req.URL.RequestURI(), len(entry.Response.Content.Text))
+		http.Error(w, fmt.Sprintf("recorded response for %s %s is truncated", req.Method, req.URL.RequestURI()), http.StatusBadGateway)
+		return
+	}
+	body, err := decodeBody(entry.Response.Content.Text, entry.Response.Content.Encoding)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid recorded response: %v", err), http.StatusBadGateway)
+		return
+	}
+	for _, h := range entry.Response.Headers {
+		if h.Value == redactedValue {
+			continue
+		}
+		w.Header().Add(h.Name, h.Value)
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Server", "capa-proxy")
+	w.Header().Set(replayHeader, "true")
+	w.WriteHeader(entry.Response.Status)
+	w.Write(body)
+}
+
+// capturing returns the capturer of the request, if it matches the capture config.
+func (p *Proxy) capturing(req *http.Request) *capturer {
+	c, _ := p.capture.Load().(*capturer)
+	if c == nil || !c.matches(req) {
+		return nil
+	}
+	return c
+}
+
+// CaptureState returns the state of the capture, or nil when it is disabled.
+func (p *Proxy) CaptureState() *CaptureState {
+	c, _ := p.capture.Load().(*capturer)
+	if c == nil {
+		return nil
+	}
+	return c.state()
+}
+
+// exchange is a request/response pair being recorded.
+type exchange struct {
+	capturer *capturer
+	entry    *harEntry
+	start    time.Time
+	reqBody  *limitedBuffer
+}
+
+// start begins the recording of the request. Its body is recorded while it is sent.
+func (c *capturer) start(req *http.Request) *exchange {
+	x := &exchange{
+		capturer: c,
+		start:    time.Now(),
+		reqBody:  &limitedBuffer{limit: c.config.MaxBodyBytes},
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	query := req.URL.Query()
+	uri := req.URL.RequestURI()
+	if c.redactQuery(query) {
+		redacted := *req.URL
+		redacted.RawQuery = query.Encode()
+		uri = redacted.RequestURI()
+	}
+	x.entry = &harEntry{
+		StartedDateTime: x.start,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         fmt.Sprintf("%s://%s%s", scheme, req.Host, uri),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     c.harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+		},
+	}
+	for name, values := range query {
+		for _, value := range values {
+			x.entry.Request.QueryString = append(x.entry.Request.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = readCloser{io.TeeReader(req.Body, x.reqBody), req.Body}
+	}
+	return x
+}
+
+// finish records the response. Its body is recorded while it is written to the client,
+// and the entry is recorded when the body is closed.
+func (x *exchange) finish(res *http.Response) {
+	wait := time.Since(x.start)
+	resBody := &limitedBuffer{limit: x.capturer.config.MaxBodyBytes}
+	res.Body = &captureBody{
+		Reader: io.TeeReader(res.Body, resBody),
+		closer: res.Body,
+		done: func() {
+			entry := x.entry
+			if x.reqBody.size > 0 {
+				text, encoding := encodeBody(x.reqBody.Bytes())
+				entry.Request.PostData = &harPostData{
+					MimeType:  entry.requestHeader("Content-Type"),
+					Text:      text,
+					Encoding:  encoding,
+					Truncated: x.reqBody.truncated(),
+				}
+			}
+			entry.Request.BodySize = x.reqBody.size
+
+			text, encoding := encodeBody(resBody.Bytes())
+			entry.Response = harResponse{
+				Status:      res.StatusCode,
+				StatusText:  http.StatusText(res.StatusCode),
+				HTTPVersion: res.Proto,
+				Cookies:     []harNameValue{},
+				Headers:     x.capturer.harHeaders(res.Header),
+				Content: harContent{
+					Size:      resBody.size,
+					MimeType:  res.Header.Get("Content-Type"),
+					Text:      text,
+					Encoding:  encoding,
+					Truncated: resBody.truncated(),
+				},
+				HeadersSize: -1,
+				BodySize:    resBody.size,
+			}
+			total := time.Since(x.start)
+			entry.Time = milliseconds(total)
+			entry.Timings = harTimings{Wait: milliseconds(wait), Receive: milliseconds(total - wait)}
+			x.capturer.record(entry)
+		},
+	}
+}
+
+// harHeaders returns the headers in HAR form, sorted and redacted.
+func (c *capturer) harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			if c.redact[name] {
+				value = redactedValue
+			}
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
+
+// redactQuery redacts the values of the query parameters named like the redacted
+// headers, and reports whether any was.
+func (c *capturer) redactQuery(query url.Values) bool {
+	redacted := false
+	for name, values := range query {
+		if c.redact[http.CanonicalHeaderKey(name)] {
+			for i := range values {
+				values[i] = redactedValue
+			}
+			redacted = true
+		}
+	}
+	return redacted
+}
+
+func (e *harEntry) requestHeader(name string) string {
+	for _, h := range e.Request.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// captureBody calls done once the body is closed.
+type captureBody struct {
+	io.Reader
+
+	closer io.Closer
+	once   sync.Once
+	done   func()
+}
+
+func (b *captureBody) Close() error {
+	err := b.closer.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// limitedBuffer keeps the first limit bytes written to it, and counts all of them.
+type limitedBuffer struct {
+	bytes.Buffer
+
+	limit int64
+	size  int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.Len()); room > 0 {
+		if int64(len(p)) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+func (b *limitedBuffer) truncated() bool {
+	return b.size > int64(b.Len())
+}
+
+// encodeBody returns the body as text, or in base64 when it is not valid UTF-8.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(text string, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+func replayKey(method string, path string, query string) string {
+	return method + " " + path + "?" + query
+}
+
+func (c *capturer) replayKeyOfURL(method string, rawURL string) (string, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid recorded url %q: %v", rawURL, err)
+	}
+	query := req.URL.Query()
+	c.redactQuery(query)
+	return replayKey(method, req.URL.Path, query.Encode()), nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureKeptAcrossReloads(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	file := filepath.Join(t.TempDir(), "capture.har")
+	config := func(routes ...string) *Config {
+		cfg := &Config{
+			Clusters: []*ClusterConfig{{Name: "upstream", Address: strings.TrimPrefix(upstream.URL, "http://")}},
+			Capture:  &CaptureConfig{Mode: CaptureRecord, Format: CaptureHAR, File: file},
+		}
+		for _, name := range routes {
+			cfg.Routes = append(cfg.Routes, &RouteConfig{Name: name, Match: &RouteMatch{PathPrefix: "/"}, Cluster: "upstream"})
+		}
+		return cfg
+	}
+	p, err := NewProxy(config("first"))
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	get := func(path string) {
+		res, err := http.Get(front.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		res.Body.Close()
+	}
+	get("/a")
+	if err := p.ApplyConfig(config("second")); err != nil {
+		t.Fatalf("failed to apply the config: %v", err)
+	}
+	get("/b")
+	p.Stop(0)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &harLog{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Log.Entries) != 2 {
+		t.Fatalf("expected the entries before and after the reload, got %d", len(doc.Log.Entries))
+	}
+}
+
+func TestReplayTruncatedBodyFails(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture.jsonl")
+	entry := &harEntry{
+		Request: harRequest{Method: "GET", URL: "http://app/big"},
+		Response: harResponse{
+			Status:  200,
+			Content: harContent{Text: "abc", Size: 10, Truncated: true},
+		},
+	}
+	line, _ := json.Marshal(entry)
+	if err := os.WriteFile(file, append(line, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProxy(&Config{Capture: &CaptureConfig{Mode: CaptureReplay, File: file}})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "http://app/big", nil))
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "truncated") {
+		t.Fatalf("expected the truncated replay to fail, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCaptureHARWrittenOnStop(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	file := filepath.Join(t.TempDir(), "capture.har")
+	if err := os.WriteFile(file, []byte("previous capture"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{{Name: "upstream", Address: strings.TrimPrefix(upstream.URL, "http://")}},
+		Routes:   []*RouteConfig{{Name: "all", Match: &RouteMatch{PathPrefix: "/"}, Cluster: "upstream"}},
+		Capture:  &CaptureConfig{Mode: CaptureRecord, Format: CaptureHAR, File: file, MaxEntries: 2},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		res, err := http.Get(front.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		res.Body.Close()
+	}
+	if data, _ := os.ReadFile(file); string(data) != "previous capture" {
+		t.Fatalf("expected the HAR document to be written on stop only, got %q", data)
+	}
+	p.Stop(0)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &harLog{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		t.Fatalf("expected the previous file to be replaced by the HAR document: %v", err)
+	}
+	if len(doc.Log.Entries) != 2 {
+		t.Fatalf("expected the entries up to max_entries, got %d", len(doc.Log.Entries))
+	}
+}
+
+func TestCaptureRedactsQuery(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("page " + req.URL.Query().Get("page")))
+	}))
+	defer upstream.Close()
+
+	file := filepath.Join(t.TempDir(), "capture.jsonl")
+	config := func(mode string) *Config {
+		return &Config{
+			Clusters: []*ClusterConfig{{Name: "upstream", Address: strings.TrimPrefix(upstream.URL, "http://")}},
+			Routes:   []*RouteConfig{{Name: "all", Match: &RouteMatch{PathPrefix: "/"}, Cluster: "upstream"}},
+			Capture:  &CaptureConfig{Mode: mode, File: file, RedactHeaders: []string{"token", "api_key"}},
+		}
+	}
+	p, err := NewProxy(config(CaptureRecord))
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+	res, err := http.Get(front.URL + "/items?page=2&token=secret&API_KEY=secret")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	res.Body.Close()
+	p.Stop(0)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("expected the query parameters to be redacted, got %s", data)
+	}
+	entry := &harEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		t.Fatal(err)
+	}
+	query := map[string]string{}
+	for _, q := range entry.Request.QueryString {
+		query[q.Name] = q.Value
+	}
+	if query["page"] != "2" || query["token"] != redactedValue || query["API_KEY"] != redactedValue {
+		t.Fatalf("expected only the redacted parameters to be replaced, got %v", query)
+	}
+
+	// The redacted parameters match any value on replay.
+	p, err = NewProxy(config(CaptureReplay))
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/items?page=2&token=other&API_KEY=other", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "page 2" || w.Header().Get(replayHeader) != "true" {
+		t.Fatalf("expected the recorded response, got %d %q", w.Code, w.Body.String())
+	}
+}
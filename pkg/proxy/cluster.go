@@ -0,0 +1,489 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The load balancing policies of a cluster.
+const (
+	RoundRobin     = "round_robin"
+	LeastRequest   = "least_request"
+	ConsistentHash = "consistent_hash"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = time.Second
+	defaultHealthyThreshold    = 2
+	defaultUnhealthyThreshold  = 3
+	defaultOutlierErrors       = 5
+	defaultBaseEjectionTime    = 30 * time.Second
+	defaultMaxEjectionPercent  = 50
+	consistentHashVirtualNodes = 100
+	maxEjectionTimeMultiplier  = 10
+	healthCheckUserAgent       = "capa-proxy-health-check"
+)
+
+// ClusterConfig is a named upstream cluster that routes forward requests to.
+// Requests are load balanced between the endpoints of the cluster.
+type ClusterConfig struct {
+	Name string `json:"name"`
+	// Address is a shorthand for a cluster of a single endpoint.
+	Address   string   `json:"address,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"`
+	// LbPolicy is one of round_robin, least_request or consistent_hash. Defaults to round_robin.
+	LbPolicy string `json:"lb_policy,omitempty"`
+	// HashHeader is the request header hashed by the consistent_hash policy.
+	HashHeader       string                  `json:"hash_header,omitempty"`
+	HealthCheck      *HealthCheckConfig      `json:"health_check,omitempty"`
+	OutlierDetection *OutlierDetectionConfig `json:"outlier_detection,omitempty"`
+}
+
+// HealthCheckConfig actively checks the endpoints of a cluster with HTTP requests.
+// An endpoint is healthy when it answers the path with a 2xx status.
+type HealthCheckConfig struct {
+	Path               string   `json:"path"`
+	Interval           Duration `json:"interval,omitempty"`
+	Timeout            Duration `json:"timeout,omitempty"`
+	HealthyThreshold   int      `json:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int      `json:"unhealthy_threshold,omitempty"`
+}
+
+// OutlierDetectionConfig passively ejects the endpoints failing consecutive requests
+// from the load balancing, for an ejection time growing with the number of ejections.
+type OutlierDetectionConfig struct {
+	ConsecutiveErrors  int      `json:"consecutive_errors,omitempty"`
+	BaseEjectionTime   Duration `json:"base_ejection_time,omitempty"`
+	MaxEjectionPercent int      `json:"max_ejection_percent,omitempty"`
+}
+
+// EndpointState is the state of a cluster endpoint, as shown on the admin API.
+type EndpointState struct {
+	Address        string     `json:"address"`
+	Healthy        bool       `json:"healthy"`
+	EjectedUntil   *time.Time `json:"ejected_until,omitempty"`
+	ActiveRequests int64      `json:"active_requests"`
+}
+
+// ClusterState is the state of a cluster, as shown on the admin API.
+type ClusterState struct {
+	Name      string           `json:"name"`
+	LbPolicy  string           `json:"lb_policy"`
+	Endpoints []*EndpointState `json:"endpoints"`
+}
+
+type cluster struct {
+	// next is the round robin counter, first for 64-bit alignment.
+	next int64
+
+	name       string
+	lbPolicy   string
+	hashHeader string
+	endpoints  []*endpoint
+	// ring is the sorted hash ring of the consistent_hash policy.
+	ring []ringNode
+
+	healthCheck *HealthCheckConfig
+	outlier     *OutlierDetectionConfig
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type endpoint struct {
+	// activeRequests is first for 64-bit alignment.
+	activeRequests int64
+	// healthy is set by the active health checks. Endpoints start healthy.
+	healthy int32
+	address string
+
+	sync.Mutex
+	checkSuccesses    int
+	checkFailures     int
+	consecutiveErrors int
+	ejections         int
+	ejectedUntil      time.Time
+}
+
+// endpointBody is a response body that ends the active request of its endpoint when closed.
+type endpointBody struct {
+	io.ReadCloser
+
+	endpoint *endpoint
+	once     sync.Once
+}
+
+func (b *endpointBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.endpoint.activeRequests, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+type ringNode struct {
+	hash     uint32
+	endpoint *endpoint
+}
+
+func newCluster(c *ClusterConfig) (*cluster, error) {
+	if c == nil || c.Name == "" {
+		return nil, fmt.Errorf("cluster name is required")
+	}
+	addresses := c.Endpoints
+	if c.Address != "" {
+		addresses = append([]string{c.Address}, addresses...)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("cluster %q: one of address or endpoints is required", c.Name)
+	}
+
+	cl := &cluster{
+		name:       c.Name,
+		lbPolicy:   c.LbPolicy,
+		hashHeader: http.CanonicalHeaderKey(c.HashHeader),
+		stop:       make(chan struct{}),
+	}
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, fmt.Errorf("cluster %q: invalid address %q: %v", c.Name, address, err)
+		}
+		if seen[address] {
+			return nil, fmt.Errorf("cluster %q: duplicate endpoint %q", c.Name, address)
+		}
+		seen[address] = true
+		cl.endpoints = append(cl.endpoints, &endpoint{address: address, healthy: 1})
+	}
+
+	switch cl.lbPolicy {
+	case "":
+		cl.lbPolicy = RoundRobin
+	case RoundRobin, LeastRequest:
+	case ConsistentHash:
+		if c.HashHeader == "" {
+			return nil, fmt.Errorf("cluster %q: hash_header is required by consistent_hash", c.Name)
+		}
+		cl.buildRing()
+	default:
+		return nil, fmt.Errorf("cluster %q: unknown lb_policy %q", c.Name, c.LbPolicy)
+	}
+
+	if hc := c.HealthCheck; hc != nil {
+		if hc.Path == "" || hc.Interval < 0 || hc.Timeout < 0 || hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+			return nil, fmt.Errorf("cluster %q: invalid health check", c.Name)
+		}
+		checked := *hc
+		if checked.Interval == 0 {
+			checked.Interval = Duration(defaultHealthCheckInterval)
+		}
+		if checked.Timeout == 0 {
+			checked.Timeout = Duration(defaultHealthCheckTimeout)
+		}
+		if checked.HealthyThreshold == 0 {
+			checked.HealthyThreshold = defaultHealthyThreshold
+		}
+		if checked.UnhealthyThreshold == 0 {
+			checked.UnhealthyThreshold = defaultUnhealthyThreshold
+		}
+		cl.healthCheck = &checked
+	}
+
+	if od := c.OutlierDetection; od != nil {
+		if od.ConsecutiveErrors < 0 || od.BaseEjectionTime < 0 || od.MaxEjectionPercent < 0 || od.MaxEjectionPercent > 100 {
+			return nil, fmt.Errorf("cluster %q: invalid outlier detection", c.Name)
+		}
+		detection := *od
+		if detection.ConsecutiveErrors == 0 {
+			detection.ConsecutiveErrors = defaultOutlierErrors
+		}
+		if detection.BaseEjectionTime == 0 {
+			detection.BaseEjectionTime = Duration(defaultBaseEjectionTime)
+		}
+		if detection.MaxEjectionPercent == 0 {
+			detection.MaxEjectionPercent = defaultMaxEjectionPercent
+		}
+		cl.outlier = &detection
+	}
+	return cl, nil
+}
+
+func (c *cluster) buildRing() {
+	for _, ep := range c.endpoints {
+		for i := 0; i < consistentHashVirtualNodes; i++ {
+			c.ring = append(c.ring, ringNode{
+				hash:     crc32.ChecksumIEEE([]byte(ep.address + "#" + strconv.Itoa(i))),
+				endpoint: ep,
+			})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool {
+		return c.ring[i].hash < c.ring[j].hash
+	})
+}
+
+// inherit carries the health and ejection states of the endpoints over from the
+// previous cluster of the same name, when its endpoints are unchanged, so that a
+// config update doesn't bring back the unhealthy and ejected endpoints.
+func (c *cluster) inherit(old *cluster) {
+	if old == nil || !sameEndpoints(c.endpoints, old.endpoints) {
+		return
+	}
+	previous := make(map[string]*endpoint, len(old.endpoints))
+	for _, ep := range old.endpoints {
+		previous[ep.address] = ep
+	}
+	for _, ep := range c.endpoints {
+		prev := previous[ep.address]
+		prev.Lock()
+		if c.healthCheck != nil {
+			atomic.StoreInt32(&ep.healthy, atomic.LoadInt32(&prev.healthy))
+			ep.checkSuccesses = prev.checkSuccesses
+			ep.checkFailures = prev.checkFailures
+		}
+		if c.outlier != nil {
+			ep.consecutiveErrors = prev.consecutiveErrors
+			ep.ejections = prev.ejections
+			ep.ejectedUntil = prev.ejectedUntil
+		}
+		prev.Unlock()
+	}
+}
+
+// sameEndpoints reports whether both endpoint lists have the same addresses.
+func sameEndpoints(a []*endpoint, b []*endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	addresses := make(map[string]bool, len(a))
+	for _, ep := range a {
+		addresses[ep.address] = true
+	}
+	for _, ep := range b {
+		if !addresses[ep.address] {
+			return false
+		}
+	}
+	return true
+}
+
+// available reports whether the endpoint is healthy and not ejected.
+func (ep *endpoint) available(now time.Time) bool {
+	if atomic.LoadInt32(&ep.healthy) == 0 {
+		return false
+	}
+	ep.Lock()
+	defer ep.Unlock()
+	return now.After(ep.ejectedUntil)
+}
+
+// pick picks an endpoint for the request according to the load balancing policy.
+// When no endpoint is available, every endpoint is considered rather than failing.
+func (c *cluster) pick(req *http.Request) *endpoint {
+	// The local cluster forwards the redirected requests to their original destination.
+	if c.name == defaultClusterName {
+		if dst := originalDstOf(req); dst != "" {
+			return &endpoint{address: dst, healthy: 1}
+		}
+	}
+
+	now := time.Now()
+	candidates := make([]*endpoint, 0, len(c.endpoints))
+	for _, ep := range c.endpoints {
+		if ep.available(now) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = c.endpoints
+	}
+
+	switch c.lbPolicy {
+	case LeastRequest:
+		best := candidates[rand.Intn(len(candidates))]
+		for _, ep := range candidates {
+			if atomic.LoadInt64(&ep.activeRequests) < atomic.LoadInt64(&best.activeRequests) {
+				best = ep
+			}
+		}
+		return best
+	case ConsistentHash:
+		if key := req.Header.Get(c.hashHeader); key != "" {
+			return c.hashPick(crc32.ChecksumIEEE([]byte(key)), candidates)
+		}
+	}
+	n := atomic.AddInt64(&c.next, 1)
+	return candidates[int(n-1)%len(candidates)]
+}
+
+// hashPick walks the ring clockwise from the hash to the first candidate endpoint.
+func (c *cluster) hashPick(hash uint32, candidates []*endpoint) *endpoint {
+	allowed := make(map[*endpoint]bool, len(candidates))
+	for _, ep := range candidates {
+		allowed[ep] = true
+	}
+	start := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= hash
+	})
+	for i := 0; i < len(c.ring); i++ {
+		node := c.ring[(start+i)%len(c.ring)]
+		if allowed[node.endpoint] {
+			return node.endpoint
+		}
+	}
+	return candidates[0]
+}
+
+// report records the outcome of a request to the endpoint for outlier detection.
+func (c *cluster) report(ep *endpoint, failed bool) {
+	if c.outlier == nil {
+		return
+	}
+	ep.Lock()
+	if !failed {
+		ep.consecutiveErrors = 0
+		ep.Unlock()
+		return
+	}
+	ep.consecutiveErrors++
+	eject := ep.consecutiveErrors >= c.outlier.ConsecutiveErrors
+	ep.Unlock()
+
+	if eject && c.canEject() {
+		ep.Lock()
+		ep.ejections++
+		multiplier := ep.ejections
+		if multiplier > maxEjectionTimeMultiplier {
+			multiplier = maxEjectionTimeMultiplier
+		}
+		ep.ejectedUntil = time.Now().Add(time.Duration(c.outlier.BaseEjectionTime) * time.Duration(multiplier))
+		ep.consecutiveErrors = 0
+		until := ep.ejectedUntil
+		ep.Unlock()
+		log.Warnf("[Capa.proxy] endpoint %s of cluster %s ejected until %s", ep.address, c.name, until.Format(time.RFC3339))
+	}
+}
+
+// canEject reports whether one more endpoint may be ejected within the max ejection percent.
+func (c *cluster) canEject() bool {
+	now := time.Now()
+	ejected := 0
+	for _, ep := range c.endpoints {
+		ep.Lock()
+		if now.Before(ep.ejectedUntil) {
+			ejected++
+		}
+		ep.Unlock()
+	}
+	return (ejected+1)*100 <= c.outlier.MaxEjectionPercent*len(c.endpoints)
+}
+
+// startHealthChecks checks every endpoint of the cluster until the cluster is stopped.
+func (c *cluster) startHealthChecks() {
+	if c.healthCheck == nil {
+		return
+	}
+	for _, ep := range c.endpoints {
+		go c.runHealthCheck(ep)
+	}
+}
+
+// stopHealthChecks stops the health checks. It may be called more than once,
+// e.g. by a config update racing the stop of the proxy.
+func (c *cluster) stopHealthChecks() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *cluster) runHealthCheck(ep *endpoint) {
+	ticker := time.NewTicker(time.Duration(c.healthCheck.Interval))
+	defer ticker.Stop()
+	for {
+		c.checkEndpoint(ep)
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *cluster) checkEndpoint(ep *endpoint) {
+	hc := c.healthCheck
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(hc.Timeout))
+	defer cancel()
+
+	ok := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+ep.address+hc.Path, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", healthCheckUserAgent)
+		if res, err := http.DefaultClient.Do(req); err == nil {
+			res.Body.Close()
+			ok = res.StatusCode >= 200 && res.StatusCode < 300
+		}
+	}
+
+	ep.Lock()
+	defer ep.Unlock()
+	healthy := atomic.LoadInt32(&ep.healthy) == 1
+	if ok {
+		ep.checkSuccesses++
+		ep.checkFailures = 0
+		if !healthy && ep.checkSuccesses >= hc.HealthyThreshold {
+			atomic.StoreInt32(&ep.healthy, 1)
+			log.Infof("[Capa.proxy] endpoint %s of cluster %s is healthy", ep.address, c.name)
+		}
+	} else {
+		ep.checkFailures++
+		ep.checkSuccesses = 0
+		if healthy && ep.checkFailures >= hc.UnhealthyThreshold {
+			atomic.StoreInt32(&ep.healthy, 0)
+			log.Warnf("[Capa.proxy] endpoint %s of cluster %s is unhealthy", ep.address, c.name)
+		}
+	}
+}
+
+func (c *cluster) state() *ClusterState {
+	state := &ClusterState{Name: c.name, LbPolicy: c.lbPolicy}
+	for _, ep := range c.endpoints {
+		ep.Lock()
+		es := &EndpointState{
+			Address:        ep.address,
+			Healthy:        atomic.LoadInt32(&ep.healthy) == 1,
+			ActiveRequests: atomic.LoadInt64(&ep.activeRequests),
+		}
+		if time.Now().Before(ep.ejectedUntil) {
+			ejectedUntil := ep.ejectedUntil
+			es.EjectedUntil = &ejectedUntil
+		}
+		ep.Unlock()
+		state.Endpoints = append(state.Endpoints, es)
+	}
+	return state
+}
+
+// ClusterStates returns the states of the active clusters sorted by name.
+func (p *Proxy) ClusterStates() []*ClusterState {
+	table := p.routeTable()
+	states := make([]*ClusterState, 0, len(table.clusters))
+	for _, c := range table.clusters {
+		states = append(states, c.state())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCluster(t *testing.T, cc *ClusterConfig) *cluster {
+	t.Helper()
+	c, err := newCluster(cc)
+	if err != nil {
+		t.Fatalf("failed to create the cluster: %v", err)
+	}
+	return c
+}
+
+func TestRoundRobin(t *testing.T) {
+	c := newTestCluster(t, &ClusterConfig{Name: "upstream", Endpoints: []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	expected := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.1:80", "10.0.0.2:80"}
+	for i, address := range expected {
+		if ep := c.pick(req); ep.address != address {
+			t.Fatalf("pick %d: expected %s, got %s", i, address, ep.address)
+		}
+	}
+
+	// The unavailable endpoints are skipped.
+	atomic.StoreInt32(&c.endpoints[1].healthy, 0)
+	for i := 0; i < 4; i++ {
+		if ep := c.pick(req); ep == c.endpoints[1] {
+			t.Fatalf("pick %d: expected the unhealthy endpoint to be skipped", i)
+		}
+	}
+}
+
+func TestLeastRequest(t *testing.T) {
+	c := newTestCluster(t, &ClusterConfig{Name: "upstream", LbPolicy: LeastRequest, Endpoints: []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	c.endpoints[0].activeRequests = 3
+	c.endpoints[1].activeRequests = 1
+	c.endpoints[2].activeRequests = 2
+
+	for i := 0; i < 10; i++ {
+		if ep := c.pick(req); ep != c.endpoints[1] {
+			t.Fatalf("pick %d: expected the endpoint with the least requests, got %s", i, ep.address)
+		}
+	}
+	atomic.StoreInt32(&c.endpoints[1].healthy, 0)
+	for i := 0; i < 10; i++ {
+		if ep := c.pick(req); ep != c.endpoints[2] {
+			t.Fatalf("pick %d: expected the available endpoint with the least requests, got %s", i, ep.address)
+		}
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	c := newTestCluster(t, &ClusterConfig{
+		Name:       "upstream",
+		LbPolicy:   ConsistentHash,
+		HashHeader: "x-user",
+		Endpoints:  []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"},
+	})
+	pick := func(user string) *endpoint {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", user)
+		return c.pick(req)
+	}
+
+	picked := map[string]*endpoint{}
+	used := map[*endpoint]bool{}
+	for i := 0; i < 100; i++ {
+		user := "user-" + strconv.Itoa(i)
+		picked[user] = pick(user)
+		used[picked[user]] = true
+		if again := pick(user); again != picked[user] {
+			t.Fatalf("expected %s to stick to %s, got %s", user, picked[user].address, again.address)
+		}
+	}
+	if len(used) != len(c.endpoints) {
+		t.Fatalf("expected the users to be spread over every endpoint, got %d endpoints", len(used))
+	}
+
+	// Only the users of an unavailable endpoint move to other endpoints.
+	down := picked["user-0"]
+	atomic.StoreInt32(&down.healthy, 0)
+	for user, ep := range picked {
+		moved := pick(user)
+		if ep == down && moved == down {
+			t.Fatalf("expected %s to move off the unhealthy endpoint", user)
+		}
+		if ep != down && moved != ep {
+			t.Fatalf("expected %s to stay on %s, got %s", user, ep.address, moved.address)
+		}
+	}
+}
+
+func TestPickWithoutAvailableEndpoint(t *testing.T) {
+	c := newTestCluster(t, &ClusterConfig{Name: "upstream", Endpoints: []string{"10.0.0.1:80", "10.0.0.2:80"}})
+	for _, ep := range c.endpoints {
+		atomic.StoreInt32(&ep.healthy, 0)
+	}
+	if ep := c.pick(httptest.NewRequest(http.MethodGet, "/", nil)); ep == nil {
+		t.Fatalf("expected an endpoint when none is available")
+	}
+}
+
+func TestHealthCheckThresholds(t *testing.T) {
+	var status int32 = http.StatusOK
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/healthz" || req.Header.Get("User-Agent") != healthCheckUserAgent {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer upstream.Close()
+
+	c := newTestCluster(t, &ClusterConfig{
+		Name:    "upstream",
+		Address: strings.TrimPrefix(upstream.URL, "http://"),
+		HealthCheck: &HealthCheckConfig{
+			Path:               "/healthz",
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 3,
+		},
+	})
+	ep := c.endpoints[0]
+	check := func(code int, healthy bool) {
+		t.Helper()
+		atomic.StoreInt32(&status, int32(code))
+		c.checkEndpoint(ep)
+		if got := atomic.LoadInt32(&ep.healthy) == 1; got != healthy {
+			t.Fatalf("expected healthy=%v after a %d check, got %v", healthy, code, got)
+		}
+	}
+
+	check(http.StatusServiceUnavailable, true)
+	check(http.StatusServiceUnavailable, true)
+	// A success resets the consecutive failures.
+	check(http.StatusOK, true)
+	check(http.StatusServiceUnavailable, true)
+	check(http.StatusServiceUnavailable, true)
+	check(http.StatusServiceUnavailable, false)
+
+	check(http.StatusOK, false)
+	check(http.StatusServiceUnavailable, false)
+	check(http.StatusOK, false)
+	check(http.StatusOK, true)
+}
+
+func TestOutlierEjection(t *testing.T) {
+	c := newTestCluster(t, &ClusterConfig{
+		Name:      "upstream",
+		Endpoints: []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.4:80"},
+		OutlierDetection: &OutlierDetectionConfig{
+			ConsecutiveErrors:  2,
+			BaseEjectionTime:   Duration(time.Minute),
+			MaxEjectionPercent: 50,
+		},
+	})
+	now := time.Now()
+	fail := func(ep *endpoint, times int) {
+		for i := 0; i < times; i++ {
+			c.report(ep, true)
+		}
+	}
+
+	a, b, d := c.endpoints[0], c.endpoints[1], c.endpoints[2]
+	fail(a, 1)
+	c.report(a, false)
+	fail(a, 1)
+	if !a.available(now) {
+		t.Fatalf("expected a success to reset the consecutive errors")
+	}
+	fail(a, 1)
+	if a.available(now) {
+		t.Fatalf("expected the endpoint to be ejected after 2 consecutive errors")
+	}
+	fail(b, 2)
+	if b.available(now) {
+		t.Fatalf("expected a second endpoint to be ejected within 50%%")
+	}
+	fail(d, 2)
+	if !d.available(now) {
+		t.Fatalf("expected a third endpoint not to be ejected beyond 50%%")
+	}
+
+	// The ejection time grows with the number of ejections.
+	a.Lock()
+	a.ejectedUntil = time.Time{}
+	a.Unlock()
+	fail(a, 2)
+	a.Lock()
+	ejectedFor := time.Until(a.ejectedUntil)
+	a.Unlock()
+	if ejectedFor <= time.Minute || ejectedFor > 2*time.Minute {
+		t.Fatalf("expected the second ejection to last 2 minutes, got %s", ejectedFor)
+	}
+}
+
+func TestStopHealthChecksTwice(t *testing.T) {
+	table, err := NewRouteTable(&Config{
+		Clusters: []*ClusterConfig{{Name: "upstream", Address: "127.0.0.1:1", HealthCheck: &HealthCheckConfig{Path: "/healthz", Interval: Duration(time.Hour)}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the route table: %v", err)
+	}
+	table.start()
+	table.stop()
+	table.stop()
+}
+
+func TestApplyConfigKeepsEndpointStates(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+	ejected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ejected.Close()
+	addresses := []string{
+		strings.TrimPrefix(unhealthy.URL, "http://"),
+		strings.TrimPrefix(ejected.URL, "http://"),
+		strings.TrimPrefix(healthy.URL, "http://"),
+	}
+
+	for _, policy := range []string{RoundRobin, LeastRequest, ConsistentHash} {
+		t.Run(policy, func(t *testing.T) {
+			config := func(endpoints []string) *Config {
+				return &Config{Clusters: []*ClusterConfig{{
+					Name:       "upstream",
+					Endpoints:  endpoints,
+					LbPolicy:   policy,
+					HashHeader: "x-user",
+					HealthCheck: &HealthCheckConfig{
+						Path:     "/healthz",
+						Interval: Duration(time.Hour),
+					},
+					OutlierDetection: &OutlierDetectionConfig{
+						ConsecutiveErrors:  1,
+						MaxEjectionPercent: 50,
+					},
+				}}}
+			}
+			p, err := NewProxy(config(addresses))
+			if err != nil {
+				t.Fatalf("failed to create the proxy: %v", err)
+			}
+			defer p.Stop(0)
+
+			c, _ := p.routeTable().cluster("upstream")
+			atomic.StoreInt32(&c.endpoints[0].healthy, 0)
+			c.report(c.endpoints[1], true)
+
+			if err := p.ApplyConfig(config(addresses)); err != nil {
+				t.Fatalf("failed to apply the config: %v", err)
+			}
+			c, _ = p.routeTable().cluster("upstream")
+			state := c.state()
+			if state.Endpoints[0].Healthy {
+				t.Fatalf("expected the unhealthy endpoint to stay unhealthy")
+			}
+			if state.Endpoints[1].EjectedUntil == nil {
+				t.Fatalf("expected the ejected endpoint to stay ejected")
+			}
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-User", "user-"+strconv.Itoa(i))
+				if ep := c.pick(req); ep.address != addresses[2] {
+					t.Fatalf("expected the only available endpoint, got %s", ep.address)
+				}
+			}
+
+			// The states start over when the endpoints change.
+			if err := p.ApplyConfig(config(addresses[:2])); err != nil {
+				t.Fatalf("failed to apply the config: %v", err)
+			}
+			c, _ = p.routeTable().cluster("upstream")
+			state = c.state()
+			if !state.Endpoints[0].Healthy || state.Endpoints[1].EjectedUntil != nil {
+				t.Fatalf("expected the endpoints of a changed cluster to start over, got %+v %+v", state.Endpoints[0], state.Endpoints[1])
+			}
+		})
+	}
+}
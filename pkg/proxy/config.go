@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"group.rxcloud/capa/pkg/accesslog"
+	"group.rxcloud/capa/utils"
+)
+
+// ConfigKey is the key of the proxy config in CapaRuntimeConfig.Extends.
+const ConfigKey = "proxy"
+
+// Config is the L7 proxy config. It is read from the `proxy` entry of
+// CapaRuntimeConfig.Extends, or pushed by the configuration component.
+type Config struct {
+	Clusters []*ClusterConfig `json:"clusters,omitempty"`
+	Routes   []*RouteConfig   `json:"routes,omitempty"`
+	Bypass   *BypassConfig    `json:"bypass,omitempty"`
+	TCP      *TCPConfig       `json:"tcp,omitempty"`
+	Capture  *CaptureConfig   `json:"capture,omitempty"`
+	JWT      []*JWTConfig     `json:"jwt,omitempty"`
+	// AccessLog is the access log of the proxied requests. The route of a request is
+	// the name of its matched route, empty when none matched.
+	AccessLog *accesslog.Config `json:"access_log,omitempty"`
+}
+
+// ParseConfig parses the proxy config from its json form.
+func ParseConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if len(data) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config without applying it: the files and the secrets it refers
+// to are not read.
+func (cfg *Config) Validate() error {
+	if _, err := NewRouteTable(cfg); err != nil {
+		return err
+	}
+	if err := validateBypass(cfg.Bypass); err != nil {
+		return err
+	}
+	if err := validateTCP(cfg.TCP); err != nil {
+		return err
+	}
+	if err := validateCapture(cfg.Capture); err != nil {
+		return err
+	}
+	if cfg.Capture != nil {
+		if _, err := compileMatch(cfg.Capture.Match); err != nil {
+			return fmt.Errorf("capture: %v", err)
+		}
+	}
+	names := map[string]bool{}
+	for _, jc := range cfg.JWT {
+		if err := validateJWT(jc); err != nil {
+			return err
+		}
+		if names[jc.Name] {
+			return fmt.Errorf("jwt: duplicate provider %q", jc.Name)
+		}
+		names[jc.Name] = true
+	}
+	if cfg.AccessLog != nil {
+		return cfg.AccessLog.Validate()
+	}
+	return nil
+}
+
+// ParseConfigFromExtends parses the proxy config from the extend configs of the runtime.
+// A missing entry yields an empty config.
+func ParseConfigFromExtends(extends map[string]json.RawMessage) (*Config, error) {
+	return ParseConfig(extends[ConfigKey])
+}
+
+// Duration is a time.Duration that is written as a string like "1.5s" in configs.
+type Duration = utils.Duration
@@ -1,5 +1,7 @@
 package proxy
 
+import "sync/atomic"
+
 // NodeType decides the responsibility of the proxy serves in the mesh
 type NodeType string
 
@@ -12,3 +14,33 @@ const (
 )
 
 var NodeTypes = [...]NodeType{SidecarProxy, ProxyLess}
+
+// IsValid returns whether the node type is one of NodeTypes.
+func (t NodeType) IsValid() bool {
+	for _, nodeType := range NodeTypes {
+		if t == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities returns the features the proxy provides: none until it serves, then the
+// proxying of HTTP, gRPC, TCP and upgraded connections, and the capture, the JWT
+// validation and the access log of its config.
+func (p *Proxy) Capabilities() []string {
+	if atomic.LoadInt32(&p.serving) == 0 {
+		return nil
+	}
+	capabilities := []string{CapabilityHTTPProxy, CapabilityGRPCProxy, CapabilityTCPProxy, CapabilityUpgrade}
+	if c, _ := p.capture.Load().(*capturer); c != nil {
+		capabilities = append(capabilities, CapabilityCapture)
+	}
+	if a, _ := p.authn.Load().(*authenticator); a != nil && len(a.providers) > 0 {
+		capabilities = append(capabilities, CapabilityJWT)
+	}
+	if p.accessLogger() != nil {
+		capabilities = append(capabilities, CapabilityAccessLog)
+	}
+	return capabilities
+}
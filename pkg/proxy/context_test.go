@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"group.rxcloud/capa/pkg/accesslog"
+)
+
+func TestCapabilitiesOfRunningComponents(t *testing.T) {
+	p, err := NewProxy(&Config{})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	if capabilities := p.Capabilities(); len(capabilities) != 0 {
+		t.Fatalf("expected no capabilities before the proxy serves, got %v", capabilities)
+	}
+
+	atomic.StoreInt32(&p.serving, 1)
+	expected := []string{CapabilityHTTPProxy, CapabilityGRPCProxy, CapabilityTCPProxy, CapabilityUpgrade}
+	if capabilities := p.Capabilities(); !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("expected %v, got %v", expected, capabilities)
+	}
+
+	if err := p.ApplyConfig(&Config{AccessLog: &accesslog.Config{Enabled: true}}); err != nil {
+		t.Fatalf("failed to apply the config: %v", err)
+	}
+	defer p.accessLogger().Close()
+	if !(&Node{Capabilities: p.Capabilities()}).HasCapability(CapabilityAccessLog) {
+		t.Fatalf("expected the access log capability, got %v", p.Capabilities())
+	}
+	if (&Node{Capabilities: p.Capabilities()}).HasCapability(CapabilityJWT) {
+		t.Fatalf("expected no jwt capability without providers, got %v", p.Capabilities())
+	}
+}
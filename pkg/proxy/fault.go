@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFaultDuration = 10 * time.Minute
+	maxFaultDuration     = 24 * time.Hour
+)
+
+// FaultConfig injects a delay or an abort into the requests matched by Match, for chaos
+// testing. Faults are toggled at runtime through the admin API and expire automatically.
+type FaultConfig struct {
+	Name  string      `json:"name"`
+	Match *RouteMatch `json:"match,omitempty"`
+	Delay *FaultDelay `json:"delay,omitempty"`
+	Abort *FaultAbort `json:"abort,omitempty"`
+	// Duration is how long the fault stays active, 10 minutes by default and 24 hours at most.
+	Duration Duration `json:"duration,omitempty"`
+}
+
+// FaultDelay delays the matched requests before they are forwarded.
+type FaultDelay struct {
+	Delay Duration `json:"delay"`
+	// Percentage of the matched requests to delay, in (0, 100]. Defaults to 100.
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+// FaultAbort answers the matched requests with a status instead of forwarding them.
+type FaultAbort struct {
+	Status int `json:"status"`
+	// Percentage of the matched requests to abort, in (0, 100]. Defaults to 100.
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+// ActiveFault is an active fault, as shown on the admin API.
+type ActiveFault struct {
+	*FaultConfig
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type fault struct {
+	*requestMatcher
+
+	config    *FaultConfig
+	expiresAt time.Time
+}
+
+// faultInjector holds the active faults. The zero value has no faults.
+type faultInjector struct {
+	sync.Mutex
+
+	// faults are in the order they were enabled in, the first matching one is injected.
+	faults []*fault
+}
+
+// index returns the index of the named fault, or -1.
+func (fi *faultInjector) index(name string) int {
+	for i, f := range fi.faults {
+		if f.config.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// prune removes the expired faults.
+func (fi *faultInjector) prune(now time.Time) {
+	active := fi.faults[:0]
+	for _, f := range fi.faults {
+		if now.After(f.expiresAt) {
+			log.Infof("[Capa.proxy] fault %s expired", f.config.Name)
+			continue
+		}
+		active = append(active, f)
+	}
+	for i := len(active); i < len(fi.faults); i++ {
+		fi.faults[i] = nil
+	}
+	fi.faults = active
+}
+
+func validateFault(fc *FaultConfig) error {
+	if fc.Name == "" {
+		return fmt.Errorf("fault name is required")
+	}
+	if fc.Delay == nil && fc.Abort == nil {
+		return fmt.Errorf("fault %q: one of delay or abort is required", fc.Name)
+	}
+	if d := fc.Delay; d != nil {
+		if d.Delay <= 0 {
+			return fmt.Errorf("fault %q: delay must be positive", fc.Name)
+		}
+		if d.Percentage < 0 || d.Percentage > 100 {
+			return fmt.Errorf("fault %q: invalid delay percentage %v", fc.Name, d.Percentage)
+		}
+	}
+	if a := fc.Abort; a != nil {
+		if a.Status < 200 || a.Status > 599 {
+			return fmt.Errorf("fault %q: invalid abort status %d", fc.Name, a.Status)
+		}
+		if a.Percentage < 0 || a.Percentage > 100 {
+			return fmt.Errorf("fault %q: invalid abort percentage %v", fc.Name, a.Percentage)
+		}
+	}
+	if fc.Duration < 0 || time.Duration(fc.Duration) > maxFaultDuration {
+		return fmt.Errorf("fault %q: duration must be within %s", fc.Name, maxFaultDuration)
+	}
+	return nil
+}
+
+// EnableFault activates a fault, replacing the active fault of the same name in place.
+// A new fault is matched after the active ones.
+func (p *Proxy) EnableFault(fc *FaultConfig) (*ActiveFault, error) {
+	if err := validateFault(fc); err != nil {
+		return nil, err
+	}
+	matcher, err := compileMatch(fc.Match)
+	if err != nil {
+		return nil, fmt.Errorf("fault %q: %v", fc.Name, err)
+	}
+	duration := time.Duration(fc.Duration)
+	if duration == 0 {
+		duration = defaultFaultDuration
+	}
+	f := &fault{
+		requestMatcher: matcher,
+		config:         fc,
+		expiresAt:      time.Now().Add(duration),
+	}
+
+	p.faults.Lock()
+	defer p.faults.Unlock()
+	if i := p.faults.index(fc.Name); i >= 0 {
+		p.faults.faults[i] = f
+	} else {
+		p.faults.faults = append(p.faults.faults, f)
+	}
+	log.Warnf("[Capa.proxy] fault %s enabled until %s", fc.Name, f.expiresAt.Format(time.RFC3339))
+	return &ActiveFault{FaultConfig: fc, ExpiresAt: f.expiresAt}, nil
+}
+
+// DisableFault deactivates a fault. It reports whether the fault was active.
+func (p *Proxy) DisableFault(name string) bool {
+	p.faults.Lock()
+	defer p.faults.Unlock()
+	i := p.faults.index(name)
+	if i < 0 {
+		return false
+	}
+	p.faults.faults = append(p.faults.faults[:i], p.faults.faults[i+1:]...)
+	log.Infof("[Capa.proxy] fault %s disabled", name)
+	return true
+}
+
+// ActiveFaults returns the active faults in the order they are matched in. Expired
+// faults are removed.
+func (p *Proxy) ActiveFaults() []*ActiveFault {
+	p.faults.Lock()
+	defer p.faults.Unlock()
+
+	p.faults.prune(time.Now())
+	faults := []*ActiveFault{}
+	for _, f := range p.faults.faults {
+		faults = append(faults, &ActiveFault{FaultConfig: f.config, ExpiresAt: f.expiresAt})
+	}
+	return faults
+}
+
+// matchFault returns the first active fault matching the request, or nil. Expired
+// faults are removed.
+func (p *Proxy) matchFault(req *http.Request) *fault {
+	p.faults.Lock()
+	defer p.faults.Unlock()
+
+	p.faults.prune(time.Now())
+	for _, f := range p.faults.faults {
+		if f.matches(req) {
+			return f
+		}
+	}
+	return nil
+}
+
+// injectFault applies the matching fault, if any, to the request.
+// It reports whether the request was aborted and answered already.
+func (p *Proxy) injectFault(w http.ResponseWriter, req *http.Request) bool {
+	f := p.matchFault(req)
+	if f == nil {
+		return false
+	}
+
+	if d := f.config.Delay; d != nil && hitPercentage(d.Percentage) {
+		select {
+		case <-time.After(time.Duration(d.Delay)):
+		case <-req.Context().Done():
+			return true
+		}
+	}
+
+	if a := f.config.Abort; a != nil && hitPercentage(a.Percentage) {
+		message := fmt.Sprintf("fault %s injected", f.config.Name)
+		// gRPC clients only read the status from the gRPC headers.
+		if isGRPC(req) {
+			writeGRPCError(w, grpcCodeOfStatus(a.Status), errors.New(message))
+			return true
+		}
+		w.Header().Set("Server", "capa-proxy")
+		http.Error(w, message, a.Status)
+		return true
+	}
+	return false
+}
+
+// hitPercentage randomly reports true for the given percentage of calls.
+// An unset percentage always hits.
+func hitPercentage(percentage float64) bool {
+	return percentage == 0 || rand.Float64()*100 < percentage
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchFaultInEnabledOrder(t *testing.T) {
+	p := &Proxy{}
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		_, err := p.EnableFault(&FaultConfig{Name: name, Abort: &FaultAbort{Status: 503}})
+		if err != nil {
+			t.Fatalf("failed to enable fault %s: %v", name, err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 20; i++ {
+		if f := p.matchFault(req); f == nil || f.config.Name != "zeta" {
+			t.Fatalf("expected the first enabled fault to match, got %v", f)
+		}
+	}
+
+	// Replacing a fault keeps its place.
+	p.EnableFault(&FaultConfig{Name: "zeta", Match: &RouteMatch{PathPrefix: "/other"}, Abort: &FaultAbort{Status: 500}})
+	if f := p.matchFault(req); f == nil || f.config.Name != "alpha" {
+		t.Fatalf("expected the second fault to match, got %v", f)
+	}
+	var names []string
+	for _, f := range p.ActiveFaults() {
+		names = append(names, f.Name)
+	}
+	if len(names) != 3 || names[0] != "zeta" || names[1] != "alpha" || names[2] != "mid" {
+		t.Fatalf("expected the faults in enabled order, got %v", names)
+	}
+}
+
+func TestMatchFaultPrunesExpired(t *testing.T) {
+	p := &Proxy{}
+	p.EnableFault(&FaultConfig{Name: "short", Abort: &FaultAbort{Status: 503}, Duration: Duration(time.Millisecond)})
+	p.EnableFault(&FaultConfig{Name: "long", Abort: &FaultAbort{Status: 500}})
+	time.Sleep(5 * time.Millisecond)
+
+	if f := p.matchFault(httptest.NewRequest("GET", "/", nil)); f == nil || f.config.Name != "long" {
+		t.Fatalf("expected the unexpired fault to match, got %v", f)
+	}
+	if len(p.faults.faults) != 1 {
+		t.Fatalf("expected the expired fault to be removed on match, got %d faults", len(p.faults.faults))
+	}
+}
+
+func TestFaultAbortGRPC(t *testing.T) {
+	p := &Proxy{}
+	p.EnableFault(&FaultConfig{Name: "unavailable", Abort: &FaultAbort{Status: 503}})
+
+	req := httptest.NewRequest("POST", "/helloworld.Greeter/SayHello", nil)
+	req.ProtoMajor = 2
+	req.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	if !p.injectFault(w, req) {
+		t.Fatal("expected the request to be aborted")
+	}
+	if w.Code != 200 || w.Header().Get("Grpc-Status") != "14" {
+		t.Fatalf("expected a gRPC Unavailable status, got %d with grpc-status %q", w.Code, w.Header().Get("Grpc-Status"))
+	}
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	grpcContentType  = "application/grpc"
+	grpcDialTimeout  = 5 * time.Second
+	grpcStreamBuffer = 32 * 1024
+)
+
+var (
+	grpcRequestCount   = stats.Int64("capa/proxy/grpc_request_count", "The number of proxied gRPC requests.", stats.UnitDimensionless)
+	grpcRequestLatency = stats.Float64("capa/proxy/grpc_request_latency", "The latency of the proxied gRPC requests, whole streams included.", stats.UnitMilliseconds)
+
+	grpcServiceKey = tag.MustNewKey("grpc_service")
+	grpcMethodKey  = tag.MustNewKey("grpc_method")
+	grpcStatusKey  = tag.MustNewKey("grpc_status")
+)
+
+func init() {
+	err := view.Register(
+		&view.View{
+			Name:        grpcRequestCount.Name(),
+			Description: grpcRequestCount.Description(),
+			Measure:     grpcRequestCount,
+			TagKeys:     []tag.Key{grpcServiceKey, grpcMethodKey, grpcStatusKey},
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        grpcRequestLatency.Name(),
+			Description: grpcRequestLatency.Description(),
+			Measure:     grpcRequestLatency,
+			TagKeys:     []tag.Key{grpcServiceKey, grpcMethodKey, grpcStatusKey},
+			Aggregation: view.Distribution(1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+		},
+	)
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to register grpc metrics: %v", err)
+	}
+}
+
+// grpcStatusHeaders are the headers holding the status of a gRPC call.
+var grpcStatusHeaders = map[string]bool{
+	"Grpc-Status":             true,
+	"Grpc-Message":            true,
+	"Grpc-Status-Details-Bin": true,
+}
+
+// h2cTransport sends the gRPC requests to the upstreams over cleartext HTTP/2 (h2c).
+var h2cTransport = &http2.Transport{
+	AllowHTTP: true,
+	DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+		return net.DialTimeout(network, addr, grpcDialTimeout)
+	},
+}
+
+// isGRPC reports whether the request is a gRPC request, e.g. application/grpc+proto.
+func isGRPC(req *http.Request) bool {
+	return req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("Content-Type"), grpcContentType)
+}
+
+// grpcMethodOf returns the service and method names of a gRPC request from its :path,
+// e.g. "helloworld.Greeter" and "SayHello" for "/helloworld.Greeter/SayHello".
+func grpcMethodOf(req *http.Request) (service string, method string, ok bool) {
+	if !isGRPC(req) {
+		return "", "", false
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return "", "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
+// serveGRPC proxies a gRPC request to the upstream over HTTP/2, streaming the messages
+// both ways and passing the trailers, i.e. grpc-status and grpc-message, as is.
+// Retries and mirroring don't apply to gRPC, as the streams can't be replayed.
+// It returns the gRPC status, and an error when the upstream couldn't be reached.
+func (p *Proxy) serveGRPC(w http.ResponseWriter, req *http.Request, r *route, upstream *cluster) (codes.Code, error) {
+	service, method, _ := grpcMethodOf(req)
+	start := time.Now()
+	status, err := p.forwardGRPC(w, req, r, upstream)
+	if err != nil {
+		log.Debugf("[Capa.proxy] failed to proxy grpc method %s/%s: %v", service, method, err)
+	}
+
+	ctx, _ := tag.New(context.Background(),
+		tag.Upsert(grpcServiceKey, service),
+		tag.Upsert(grpcMethodKey, method),
+		tag.Upsert(grpcStatusKey, status.String()))
+	stats.Record(ctx,
+		grpcRequestCount.M(1),
+		grpcRequestLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
+	return status, err
+}
+
+func (p *Proxy) forwardGRPC(w http.ResponseWriter, req *http.Request, r *route, upstream *cluster) (codes.Code, error) {
+	ctx := req.Context()
+	if r != nil && r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	done := func(bool) {}
+	if r != nil && r.breaker != nil {
+		var err error
+		if done, err = r.breaker.Allow(); err != nil {
+			return writeGRPCError(w, codes.Unavailable, errCircuitOpen), errCircuitOpen
+		}
+	}
+
+	// Forward the request as is to the picked endpoint, streaming its body.
+	ep := upstream.pick(req)
+	proxyReq := req.Clone(ctx)
+	proxyReq.URL.Scheme = "http"
+	proxyReq.URL.Host = ep.address
+	proxyReq.RequestURI = ""
+	proxyReq.Trailer = req.Trailer
+
+	atomic.AddInt64(&ep.activeRequests, 1)
+	defer atomic.AddInt64(&ep.activeRequests, -1)
+	res, err := h2cTransport.RoundTrip(proxyReq)
+	if err != nil {
+		upstream.report(ep, true)
+		done(false)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return writeGRPCError(w, codes.DeadlineExceeded, err), err
+		}
+		return writeGRPCError(w, codes.Unavailable, err), err
+	}
+	defer res.Body.Close()
+
+	// A trailers-only response holds its status in the headers. The status is passed as
+	// trailers, as the headers are flushed before the end of the stream is known.
+	trailers := http.Header{}
+	for name, values := range res.Header {
+		if grpcStatusHeaders[name] {
+			trailers[name] = values
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set("Server", "capa-proxy")
+	w.WriteHeader(res.StatusCode)
+
+	// Stream the response messages, flushing each one to the client right away.
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	buf := make([]byte, grpcStreamBuffer)
+	var readErr error
+	for readErr == nil {
+		var n int
+		n, readErr = res.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				readErr = err
+				break
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	// Pass the trailers, or the status of a trailers-only response.
+	for name, values := range res.Trailer {
+		trailers[name] = values
+	}
+	for name, values := range trailers {
+		w.Header()[http.TrailerPrefix+name] = values
+	}
+	grpcStatus := trailers.Get("grpc-status")
+	status := codes.Unknown
+	if code, err := strconv.Atoi(grpcStatus); err == nil {
+		status = codes.Code(code)
+	} else {
+		// The stream broke, or ended, before its status was received.
+		message := "upstream ended the stream without a grpc-status"
+		switch {
+		case readErr != io.EOF:
+			status = codes.Unavailable
+			message = readErr.Error()
+		case res.StatusCode != http.StatusOK:
+			status = grpcCodeOfStatus(res.StatusCode)
+			message = fmt.Sprintf("upstream answered with HTTP status %d", res.StatusCode)
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(status)))
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(message))
+	}
+
+	failed := res.StatusCode >= http.StatusInternalServerError || status == codes.Unavailable || status == codes.Internal
+	upstream.report(ep, failed)
+	done(!failed)
+	return status, nil
+}
+
+// grpcCodeOfStatus maps an HTTP status to the gRPC code a client gets for it, as in
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md.
+func grpcCodeOfStatus(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// writeGRPCError answers a gRPC request with an error status, as a trailers-only response.
+func writeGRPCError(w http.ResponseWriter, code codes.Code, err error) codes.Code {
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set("Server", "capa-proxy")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	w.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+	w.WriteHeader(http.StatusOK)
+	return code
+}
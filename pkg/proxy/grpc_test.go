@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	grpc_go "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const echoService = "capa.test.Echo"
+
+// echo serves every method of the echo service: Unary answers its message with a
+// trailer, Stream echoes the messages until the client is done, and Deny fails.
+func echo(_ interface{}, stream grpc_go.ServerStream) error {
+	method, _ := grpc_go.MethodFromServerStream(stream)
+	switch method {
+	case "/" + echoService + "/Unary":
+		msg := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(msg); err != nil {
+			return err
+		}
+		stream.SetTrailer(metadata.Pairs("x-echo", msg.Value))
+		return stream.SendMsg(msg)
+	case "/" + echoService + "/Stream":
+		for {
+			msg := &wrapperspb.StringValue{}
+			if err := stream.RecvMsg(msg); err == io.EOF {
+				return status.Error(codes.OutOfRange, "no more messages")
+			} else if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	default:
+		return status.Error(codes.PermissionDenied, "denied")
+	}
+}
+
+// newGRPCProxy starts an upstream serving the handler over h2c and a proxy routing
+// the echo service to it, and returns the address of the proxy.
+func newGRPCProxy(t *testing.T, upstream http.Handler) string {
+	t.Helper()
+	backend := httptest.NewUnstartedServer(h2c.NewHandler(upstream, &http2.Server{}))
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{{Name: "echo", Address: backend.Listener.Addr().String()}},
+		Routes: []*RouteConfig{{
+			Name:    "echo",
+			Match:   &RouteMatch{GrpcService: echoService},
+			Cluster: "echo",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewUnstartedServer(h2c.NewHandler(p, &http2.Server{}))
+	front.Start()
+	t.Cleanup(front.Close)
+	return front.Listener.Addr().String()
+}
+
+func dialGRPC(t *testing.T, address string) *grpc_go.ClientConn {
+	t.Helper()
+	conn, err := grpc_go.Dial(address, grpc_go.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial the proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// grpcRequests returns the number of proxied requests of a method with a status.
+func grpcRequests(method string, code codes.Code) int64 {
+	rows, _ := view.RetrieveData(grpcRequestCount.Name())
+	for _, row := range rows {
+		tags := map[string]string{}
+		for _, tag := range row.Tags {
+			tags[tag.Key.Name()] = tag.Value
+		}
+		if tags[grpcServiceKey.Name()] == echoService && tags[grpcMethodKey.Name()] == method && tags[grpcStatusKey.Name()] == code.String() {
+			return row.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+// waitGRPCRequests waits for the metrics to count the requests, as they are recorded
+// once the response is written.
+func waitGRPCRequests(t *testing.T, method string, code codes.Code, expected int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for grpcRequests(method, code) != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d %s %s requests in the metrics, got %d", expected, code, method, grpcRequests(method, code))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGRPCUnary(t *testing.T) {
+	server := grpc_go.NewServer(grpc_go.UnknownServiceHandler(echo))
+	conn := dialGRPC(t, newGRPCProxy(t, server))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := grpcRequests("Unary", codes.OK)
+	var trailer metadata.MD
+	out := &wrapperspb.StringValue{}
+	err := conn.Invoke(ctx, "/"+echoService+"/Unary", wrapperspb.String("hello capa"), out, grpc_go.Trailer(&trailer))
+	if err != nil {
+		t.Fatalf("unary call failed: %v", err)
+	}
+	if out.Value != "hello capa" {
+		t.Fatalf("expected the echoed message, got %q", out.Value)
+	}
+	if values := trailer.Get("x-echo"); len(values) != 1 || values[0] != "hello capa" {
+		t.Fatalf("expected the upstream trailer, got %v", trailer)
+	}
+	waitGRPCRequests(t, "Unary", codes.OK, before+1)
+
+	// The status of a trailers-only response is passed as is.
+	before = grpcRequests("Deny", codes.PermissionDenied)
+	err = conn.Invoke(ctx, "/"+echoService+"/Deny", wrapperspb.String(""), out)
+	if s, _ := status.FromError(err); s.Code() != codes.PermissionDenied || s.Message() != "denied" {
+		t.Fatalf("expected the upstream status, got %v", err)
+	}
+	waitGRPCRequests(t, "Deny", codes.PermissionDenied, before+1)
+}
+
+func TestGRPCStreaming(t *testing.T) {
+	server := grpc_go.NewServer(grpc_go.UnknownServiceHandler(echo))
+	conn := dialGRPC(t, newGRPCProxy(t, server))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := grpcRequests("Stream", codes.OutOfRange)
+	stream, err := conn.NewStream(ctx, &grpc_go.StreamDesc{ClientStreams: true, ServerStreams: true}, "/"+echoService+"/Stream")
+	if err != nil {
+		t.Fatalf("failed to open the stream: %v", err)
+	}
+	// Each message is echoed before the next one is sent, so the proxy must not buffer.
+	for _, value := range []string{"one", "two", "three"} {
+		if err := stream.SendMsg(wrapperspb.String(value)); err != nil {
+			t.Fatalf("failed to send %q: %v", value, err)
+		}
+		msg := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(msg); err != nil || msg.Value != value {
+			t.Fatalf("expected %q echoed, got %q: %v", value, msg.Value, err)
+		}
+	}
+	stream.CloseSend()
+	err = stream.RecvMsg(&wrapperspb.StringValue{})
+	if s, _ := status.FromError(err); s.Code() != codes.OutOfRange || s.Message() != "no more messages" {
+		t.Fatalf("expected the upstream status at the end of the stream, got %v", err)
+	}
+	waitGRPCRequests(t, "Stream", codes.OutOfRange, before+1)
+}
+
+func TestGRPCWithoutStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		code   string
+	}{
+		{name: "ok", status: http.StatusOK, code: "2"},
+		{name: "unavailable", status: http.StatusServiceUnavailable, code: "14"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address := newGRPCProxy(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", grpcContentType)
+				w.WriteHeader(c.status)
+			}))
+
+			client := &http2.Transport{
+				AllowHTTP: true,
+				DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+					return net.Dial(network, addr)
+				},
+			}
+			req, _ := http.NewRequest(http.MethodPost, "http://"+address+"/"+echoService+"/Unary", strings.NewReader(""))
+			req.Header.Set("Content-Type", grpcContentType)
+			res, err := client.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			if got := res.Trailer.Get("Grpc-Status"); got != c.code {
+				t.Fatalf("expected grpc-status %s, got %q", c.code, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// jwk is a JSON Web Key of a JWKS document, see RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// Symmetric keys.
+	K string `json:"k,omitempty"`
+}
+
+// verificationKey is a parsed public or symmetric key of a JWKS.
+type verificationKey struct {
+	kid string
+	alg string
+	// key is a *rsa.PublicKey, an *ecdsa.PublicKey or a []byte.
+	key interface{}
+}
+
+// parseJWKS parses the keys of a JWKS document. The keys not used for signatures are skipped.
+func parseJWKS(data []byte) ([]*verificationKey, error) {
+	set := &struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %v", err)
+	}
+
+	var keys []*verificationKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %d: %v", i, err)
+		}
+		keys = append(keys, &verificationKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks has no signing key")
+	}
+	return keys, nil
+}
+
+func (k *jwk) parse() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// signingAlgorithms are the supported JWS algorithms. "none" is never accepted.
+var signingAlgorithms = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"HS256": true, "HS384": true, "HS512": true,
+}
+
+// verify checks the signature of the signed content, i.e. "header.payload", with the key.
+func (k *verificationKey) verify(alg string, signed string, signature []byte) error {
+	if !signingAlgorithms[alg] {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if k.alg != "" && k.alg != alg {
+		return fmt.Errorf("key %q is not for %s", k.kid, alg)
+	}
+
+	hash := crypto.SHA256
+	switch alg[2:] {
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		secret, ok := k.key.([]byte)
+		if !ok {
+			return fmt.Errorf("key %q is not for %s", k.kid, alg)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		pub, ok := k.key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not for %s", k.kid, alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return errInvalidSignature
+		}
+		return nil
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := k.key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not for %s", k.kid, alg)
+		}
+		// The signature is the r and s integers, each padded to the size of the curve.
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errInvalidSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
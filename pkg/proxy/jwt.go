@@ -0,0 +1,451 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/components-contrib/secretstores"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	defaultJWTClockSkew        = time.Minute
+	defaultJWKSRefreshInterval = 5 * time.Minute
+	// maxNumericDate is 9999-12-31T23:59:59Z, the latest exp or nbf accepted.
+	maxNumericDate = 253402300799
+
+	// The error codes of the structured body of the 401 responses.
+	authErrorMissingToken = "missing_token"
+	authErrorInvalidToken = "invalid_token"
+)
+
+// JWTConfig validates the bearer JWTs of the requests matched by Match. Requests matched
+// by no JWT config are not authenticated. The JWTs are validated in bypass mode too.
+type JWTConfig struct {
+	Name  string      `json:"name"`
+	Match *RouteMatch `json:"match,omitempty"`
+	// Issuer must equal the iss claim, if set.
+	Issuer string `json:"issuer,omitempty"`
+	// Audiences must contain one of the aud claim values, if set.
+	Audiences []string `json:"audiences,omitempty"`
+	// The JWKS is read from either a local file or a secret store.
+	JWKSFile   string     `json:"jwks_file,omitempty"`
+	JWKSSecret *SecretRef `json:"jwks_secret,omitempty"`
+	// RequiredClaims must be present in the JWT. A non-empty value must equal the claim,
+	// or be one of its values when the claim is an array.
+	RequiredClaims map[string]string `json:"required_claims,omitempty"`
+	// ClaimHeaders forwards the verified claims to the app, from claim name to header name.
+	// The headers sent by the client are always dropped.
+	ClaimHeaders map[string]string `json:"claim_headers,omitempty"`
+	// ClockSkew is tolerated when checking exp and nbf, 1m by default.
+	ClockSkew Duration `json:"clock_skew,omitempty"`
+	// RefreshInterval reloads the JWKS to pick the rotated keys, 5m by default.
+	RefreshInterval Duration `json:"refresh_interval,omitempty"`
+}
+
+// SecretRef is a secret of a secret store. Key is the entry of the secret holding
+// the value, and defaults to the secret name.
+type SecretRef struct {
+	Store string `json:"store"`
+	Name  string `json:"name"`
+	Key   string `json:"key,omitempty"`
+}
+
+// authError is the structured body of the 401 responses.
+type authError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *authError) Error() string {
+	return e.Message
+}
+
+func invalidToken(format string, args ...interface{}) *authError {
+	return &authError{Code: authErrorInvalidToken, Message: fmt.Sprintf(format, args...)}
+}
+
+// authenticator holds the active JWT providers.
+type authenticator struct {
+	providers []*jwtProvider
+	// claimHeaders are the headers set from the verified claims, of every provider.
+	claimHeaders []string
+}
+
+type jwtProvider struct {
+	*requestMatcher
+
+	config    *JWTConfig
+	clockSkew time.Duration
+	load      func() ([]byte, error)
+	// keys holds the []*verificationKey of the last loaded JWKS.
+	keys  atomic.Value
+	stopC chan struct{}
+}
+
+// SetSecretStore makes a secret store available to the proxy, e.g. to read a JWKS.
+// The secret stores must be set before the configs referring to them are applied.
+func (p *Proxy) SetSecretStore(name string, store secretstores.SecretStore) {
+	p.secretLock.Lock()
+	defer p.secretLock.Unlock()
+	if p.secretStores == nil {
+		p.secretStores = map[string]secretstores.SecretStore{}
+	}
+	p.secretStores[name] = store
+}
+
+func (p *Proxy) getSecret(ref *SecretRef) ([]byte, error) {
+	p.secretLock.RLock()
+	store, ok := p.secretStores[ref.Store]
+	p.secretLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown secret store %q", ref.Store)
+	}
+	res, err := store.GetSecret(secretstores.GetSecretRequest{Name: ref.Name})
+	if err != nil {
+		return nil, err
+	}
+	key := ref.Key
+	if key == "" {
+		key = ref.Name
+	}
+	value, ok := res.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no key %q", ref.Name, key)
+	}
+	return []byte(value), nil
+}
+
+// newAuthenticator validates the JWT configs and loads their JWKS.
+func (p *Proxy) newAuthenticator(configs []*JWTConfig) (*authenticator, error) {
+	a := &authenticator{}
+	names := map[string]bool{}
+	for _, jc := range configs {
+		if jc != nil && names[jc.Name] {
+			a.stop()
+			return nil, fmt.Errorf("jwt: duplicate provider %q", jc.Name)
+		}
+		provider, err := p.newJWTProvider(jc)
+		if err != nil {
+			a.stop()
+			return nil, err
+		}
+		names[jc.Name] = true
+		a.providers = append(a.providers, provider)
+		for _, header := range jc.ClaimHeaders {
+			a.claimHeaders = append(a.claimHeaders, header)
+		}
+	}
+	return a, nil
+}
+
+// validateJWT checks a provider config without loading its keys.
+func validateJWT(jc *JWTConfig) error {
+	if jc == nil || jc.Name == "" {
+		return fmt.Errorf("jwt: provider name is required")
+	}
+	if (jc.JWKSFile == "") == (jc.JWKSSecret == nil) {
+		return fmt.Errorf("jwt: provider %s requires exactly one of jwks_file or jwks_secret", jc.Name)
+	}
+	if jc.ClockSkew < 0 || jc.RefreshInterval < 0 {
+		return fmt.Errorf("jwt: provider %s clock_skew and refresh_interval must not be negative", jc.Name)
+	}
+	for claim, header := range jc.ClaimHeaders {
+		if claim == "" || header == "" {
+			return fmt.Errorf("jwt: provider %s has an empty claim header", jc.Name)
+		}
+	}
+	if _, err := compileMatch(jc.Match); err != nil {
+		return fmt.Errorf("jwt: provider %s: %v", jc.Name, err)
+	}
+	return nil
+}
+
+func (p *Proxy) newJWTProvider(jc *JWTConfig) (*jwtProvider, error) {
+	if err := validateJWT(jc); err != nil {
+		return nil, err
+	}
+	matcher, err := compileMatch(jc.Match)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &jwtProvider{
+		requestMatcher: matcher,
+		config:         jc,
+		clockSkew:      time.Duration(jc.ClockSkew),
+	}
+	if provider.clockSkew == 0 {
+		provider.clockSkew = defaultJWTClockSkew
+	}
+	if jc.JWKSFile != "" {
+		provider.load = func() ([]byte, error) {
+			return os.ReadFile(jc.JWKSFile)
+		}
+	} else {
+		ref := jc.JWKSSecret
+		provider.load = func() ([]byte, error) {
+			return p.getSecret(ref)
+		}
+	}
+	if err := provider.reload(); err != nil {
+		return nil, fmt.Errorf("jwt: provider %s: %v", jc.Name, err)
+	}
+
+	interval := time.Duration(jc.RefreshInterval)
+	if interval == 0 {
+		interval = defaultJWKSRefreshInterval
+	}
+	provider.stopC = make(chan struct{})
+	go provider.refreshLoop(interval)
+	return provider, nil
+}
+
+func (j *jwtProvider) reload() error {
+	data, err := j.load()
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	j.keys.Store(keys)
+	return nil
+}
+
+// refreshLoop reloads the JWKS on every interval. The last keys are kept on failures.
+func (j *jwtProvider) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stopC:
+			return
+		case <-ticker.C:
+			if err := j.reload(); err != nil {
+				log.Warnf("[Capa.proxy] failed to reload the jwks of provider %s: %v", j.config.Name, err)
+			}
+		}
+	}
+}
+
+// stop stops the JWKS refreshes.
+func (a *authenticator) stop() {
+	if a == nil {
+		return
+	}
+	for _, provider := range a.providers {
+		close(provider.stopC)
+	}
+}
+
+// authenticate validates the JWT of the request with the first matching provider, and
+// forwards its claims as headers. A request failing the validation is answered with 401,
+// or with Unauthenticated for gRPC, in which case true is returned.
+func (p *Proxy) authenticate(w http.ResponseWriter, req *http.Request) bool {
+	a, _ := p.authn.Load().(*authenticator)
+	if a == nil {
+		return false
+	}
+
+	// The claim headers are only set from verified tokens, never by the client.
+	for _, header := range a.claimHeaders {
+		req.Header.Del(header)
+	}
+
+	for _, provider := range a.providers {
+		if !provider.matches(req) {
+			continue
+		}
+		claims, err := provider.verify(req, time.Now())
+		if err != nil {
+			log.Debugf("[Capa.proxy] rejected request %s %s of provider %s: %v", req.Method, req.URL.Path, provider.config.Name, err)
+			if isGRPC(req) {
+				writeGRPCError(w, codes.Unauthenticated, err)
+				return true
+			}
+			writeAuthError(w, err)
+			return true
+		}
+		provider.forwardClaims(req, claims)
+		return false
+	}
+	return false
+}
+
+// verify returns the claims of the bearer JWT of the request, once validated.
+func (j *jwtProvider) verify(req *http.Request, now time.Time) (map[string]interface{}, *authError) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+		return nil, &authError{Code: authErrorMissingToken, Message: "bearer token is required"}
+	}
+	token := strings.TrimSpace(authorization[7:])
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+	header := &struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, invalidToken("malformed token header")
+	}
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed token claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed token signature")
+	}
+
+	// Check the signature with the key of the token, or with every key when it has no kid.
+	keys, _ := j.keys.Load().([]*verificationKey)
+	verified := false
+	for _, key := range keys {
+		if header.Kid != "" && key.kid != "" && key.kid != header.Kid {
+			continue
+		}
+		if key.verify(header.Alg, parts[0]+"."+parts[1], signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, invalidToken("invalid token signature")
+	}
+
+	exp, ok, err := numericClaim(claims, "exp")
+	if err != nil {
+		return nil, invalidToken("%v", err)
+	}
+	if !ok {
+		return nil, invalidToken("token has no expiry")
+	}
+	if now.Add(-j.clockSkew).After(exp) {
+		return nil, invalidToken("token is expired")
+	}
+	nbf, ok, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return nil, invalidToken("%v", err)
+	}
+	if ok && now.Add(j.clockSkew).Before(nbf) {
+		return nil, invalidToken("token is not valid yet")
+	}
+	if j.config.Issuer != "" && claims["iss"] != j.config.Issuer {
+		return nil, invalidToken("invalid token issuer")
+	}
+	if len(j.config.Audiences) > 0 {
+		matched := false
+		for _, audience := range j.config.Audiences {
+			if claimContains(claims["aud"], audience) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, invalidToken("invalid token audience")
+		}
+	}
+	for name, value := range j.config.RequiredClaims {
+		claim, ok := claims[name]
+		if !ok {
+			return nil, invalidToken("token has no %s claim", name)
+		}
+		if value != "" && !claimContains(claim, value) {
+			return nil, invalidToken("invalid token %s claim", name)
+		}
+	}
+	return claims, nil
+}
+
+// forwardClaims sets the claim headers of the request. Non-string claims are written as json.
+func (j *jwtProvider) forwardClaims(req *http.Request, claims map[string]interface{}) {
+	for claim, header := range j.config.ClaimHeaders {
+		value, ok := claims[claim]
+		if !ok {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			req.Header.Set(header, s)
+			continue
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		req.Header.Set(header, string(b))
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// numericClaim returns a NumericDate claim like exp as a time, and whether the claim is
+// present. A claim that is no date between 1970 and 9999 is an error, so that a token
+// can't overflow its way past the checks.
+func numericClaim(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	claim, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, true, fmt.Errorf("invalid %s claim", name)
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsNaN(f) || f < 0 || f > maxNumericDate {
+		return time.Time{}, true, fmt.Errorf("invalid %s claim", name)
+	}
+	seconds, fraction := math.Modf(f)
+	return time.Unix(int64(seconds), int64(fraction*float64(time.Second))), true, nil
+}
+
+// claimContains reports whether the claim equals the value, or holds it when it is an array.
+func claimContains(claim interface{}, value string) bool {
+	switch c := claim.(type) {
+	case string:
+		return c == value
+	case json.Number:
+		return c.String() == value
+	case bool:
+		return fmt.Sprint(c) == value
+	case []interface{}:
+		for _, item := range c {
+			if claimContains(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeAuthError answers 401 with the structured error as json body.
+func writeAuthError(w http.ResponseWriter, err *authError) {
+	challenge := "Bearer"
+	if err.Code == authErrorInvalidToken {
+		challenge = fmt.Sprintf("Bearer error=%q, error_description=%q", err.Code, err.Message)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Server", "capa-proxy")
+	respondJSON(w, http.StatusUnauthorized, err)
+}
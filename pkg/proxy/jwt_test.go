@@ -0,0 +1,413 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testRSAKey, _   = rsa.GenerateKey(rand.Reader, 2048)
+	testEC256Key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testEC384Key, _ = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	testEC521Key, _ = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	testHMACKey     = []byte("a shared secret of at least 32 bytes")
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// padded returns the big-endian bytes of n, left padded to size.
+func padded(n *big.Int, size int) []byte {
+	b := make([]byte, size)
+	return n.FillBytes(b)
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	return map[string]string{"kty": "EC", "kid": kid, "crv": pub.Curve.Params().Name,
+		"x": b64(padded(pub.X, size)), "y": b64(padded(pub.Y, size))}
+}
+
+func octJWK(kid string, secret []byte) map[string]string {
+	return map[string]string{"kty": "oct", "kid": kid, "k": b64(secret)}
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signJWT returns a JWT of the claims signed with the key, which is an *rsa.PrivateKey,
+// an *ecdsa.PrivateKey or a []byte secret.
+func signJWT(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := b64(h) + "." + b64(c)
+
+	hash := crypto.SHA256
+	switch alg[2:] {
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	var signature []byte
+	var err error
+	switch alg[:2] {
+	case "HS":
+		mac := hmac.New(hash.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS", "PS", "ES":
+		d := hash.New()
+		d.Write([]byte(signed))
+		digest := d.Sum(nil)
+		switch alg[:2] {
+		case "RS":
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), hash, digest)
+		case "PS":
+			signature, err = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			priv := key.(*ecdsa.PrivateKey)
+			var r, s *big.Int
+			r, s, err = ecdsa.Sign(rand.Reader, priv, digest)
+			size := (priv.Curve.Params().BitSize + 7) / 8
+			signature = append(padded(r, size), padded(s, size)...)
+		}
+	case "no":
+		// alg "none" has an empty signature.
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+// tamper flips a bit of the signature of the token.
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if len(signature) == 0 {
+		signature = []byte{0}
+	}
+	signature[len(signature)/2] ^= 1
+	return parts[0] + "." + parts[1] + "." + b64(signature)
+}
+
+func newTestProvider(t *testing.T, jc *JWTConfig, keys ...map[string]string) *jwtProvider {
+	t.Helper()
+	if jc.JWKSFile == "" {
+		jc.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+		writeJWKS(t, jc.JWKSFile, keys...)
+	}
+	if jc.Name == "" {
+		jc.Name = "test"
+	}
+	provider, err := (&Proxy{}).newJWTProvider(jc)
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	t.Cleanup(func() { close(provider.stopC) })
+	return provider
+}
+
+func verifyToken(j *jwtProvider, token string, now time.Time) (map[string]interface{}, *authError) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return j.verify(req, now)
+}
+
+func validClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{"sub": "alice", "exp": now.Add(time.Hour).Unix()}
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	provider := newTestProvider(t, &JWTConfig{},
+		rsaJWK("rsa", &testRSAKey.PublicKey),
+		ecJWK("ec256", &testEC256Key.PublicKey),
+		ecJWK("ec384", &testEC384Key.PublicKey),
+		ecJWK("ec521", &testEC521Key.PublicKey),
+		octJWK("hmac", testHMACKey))
+	now := time.Now()
+
+	tests := []struct {
+		alg string
+		kid string
+		key interface{}
+	}{
+		{"RS256", "rsa", testRSAKey},
+		{"RS384", "rsa", testRSAKey},
+		{"RS512", "rsa", testRSAKey},
+		{"PS256", "rsa", testRSAKey},
+		{"PS384", "rsa", testRSAKey},
+		{"PS512", "rsa", testRSAKey},
+		{"ES256", "ec256", testEC256Key},
+		{"ES384", "ec384", testEC384Key},
+		{"ES512", "ec521", testEC521Key},
+		{"HS256", "hmac", testHMACKey},
+		{"HS384", "hmac", testHMACKey},
+		{"HS512", "hmac", testHMACKey},
+		// Without a kid, every key is tried.
+		{"RS256", "", testRSAKey},
+		{"ES256", "", testEC256Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg+"/"+tt.kid, func(t *testing.T) {
+			token := signJWT(t, tt.alg, tt.kid, tt.key, validClaims(now))
+			claims, err := verifyToken(provider, token, now)
+			if err != nil {
+				t.Fatalf("expected a valid token, got %v", err)
+			}
+			if claims["sub"] != "alice" {
+				t.Fatalf("unexpected claims %v", claims)
+			}
+			if _, err := verifyToken(provider, tamper(token), now); err == nil || err.Code != authErrorInvalidToken {
+				t.Fatalf("expected the tampered token to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTRejectsForgedAlgorithms(t *testing.T) {
+	provider := newTestProvider(t, &JWTConfig{}, rsaJWK("rsa", &testRSAKey.PublicKey))
+	now := time.Now()
+
+	// alg none, with and without a signature.
+	none := signJWT(t, "none", "rsa", nil, validClaims(now))
+	if _, err := verifyToken(provider, none, now); err == nil {
+		t.Fatal("expected alg none to be rejected")
+	}
+	if _, err := verifyToken(provider, tamper(none), now); err == nil {
+		t.Fatal("expected alg none with a signature to be rejected")
+	}
+
+	// HS256 keyed with the RSA public key, as if it were a shared secret.
+	pub := rsaJWK("rsa", &testRSAKey.PublicKey)
+	for _, secret := range [][]byte{testRSAKey.PublicKey.N.Bytes(), []byte(pub["n"])} {
+		forged := signJWT(t, "HS256", "rsa", secret, validClaims(now))
+		if _, err := verifyToken(provider, forged, now); err == nil {
+			t.Fatal("expected an HS256 token signed with the RSA public key to be rejected")
+		}
+	}
+
+	// A token signed by another RSA key.
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "rsa", other, validClaims(now)), now); err == nil {
+		t.Fatal("expected a token of another key to be rejected")
+	}
+}
+
+func TestJWTKidMismatch(t *testing.T) {
+	provider := newTestProvider(t, &JWTConfig{},
+		rsaJWK("first", &testRSAKey.PublicKey),
+		ecJWK("second", &testEC256Key.PublicKey))
+	now := time.Now()
+
+	// The kid selects the key: an RSA signature with the kid of the EC key fails.
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "second", testRSAKey, validClaims(now)), now); err == nil {
+		t.Fatal("expected a token with the kid of another key to be rejected")
+	}
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "unknown", testRSAKey, validClaims(now)), now); err == nil {
+		t.Fatal("expected a token with an unknown kid to be rejected")
+	}
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "first", testRSAKey, validClaims(now)), now); err != nil {
+		t.Fatalf("expected the token with the right kid to be valid, got %v", err)
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("old", &testRSAKey.PublicKey))
+	provider := newTestProvider(t, &JWTConfig{JWKSFile: path, RefreshInterval: Duration(20 * time.Millisecond)})
+	now := time.Now()
+
+	rotated := signJWT(t, "ES256", "new", testEC256Key, validClaims(now))
+	if _, err := verifyToken(provider, rotated, now); err == nil {
+		t.Fatal("expected the token of a key not yet in the jwks to be rejected")
+	}
+
+	// A broken jwks keeps the last keys.
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "old", testRSAKey, validClaims(now)), now); err != nil {
+		t.Fatalf("expected the last keys to be kept, got %v", err)
+	}
+
+	writeJWKS(t, path, ecJWK("new", &testEC256Key.PublicKey))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := verifyToken(provider, rotated, now); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rotated key to be picked by the refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := verifyToken(provider, signJWT(t, "RS256", "old", testRSAKey, validClaims(now)), now); err == nil {
+		t.Fatal("expected the removed key to be rejected")
+	}
+}
+
+func TestJWTTimeClaims(t *testing.T) {
+	provider := newTestProvider(t, &JWTConfig{ClockSkew: Duration(30 * time.Second)}, octJWK("hmac", testHMACKey))
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"valid", map[string]interface{}{"exp": now.Unix() + 60}, true},
+		{"no exp", map[string]interface{}{"sub": "alice"}, false},
+		{"expired within skew", map[string]interface{}{"exp": now.Unix() - 20}, true},
+		{"expired beyond skew", map[string]interface{}{"exp": now.Unix() - 40}, false},
+		{"fractional exp", map[string]interface{}{"exp": float64(now.Unix()) + 0.5}, true},
+		{"nbf within skew", map[string]interface{}{"exp": now.Unix() + 60, "nbf": now.Unix() + 20}, true},
+		{"nbf beyond skew", map[string]interface{}{"exp": now.Unix() + 60, "nbf": now.Unix() + 40}, false},
+		{"string exp", map[string]interface{}{"exp": "9999999999"}, false},
+		{"string nbf", map[string]interface{}{"exp": now.Unix() + 60, "nbf": "later"}, false},
+		{"negative exp", map[string]interface{}{"exp": -1}, false},
+		{"huge exp", map[string]interface{}{"exp": 1e19}, false},
+		{"huger exp", map[string]interface{}{"exp": 1e300}, false},
+		{"exp past year 9999", map[string]interface{}{"exp": 253402300800}, false},
+		{"huge nbf", map[string]interface{}{"exp": now.Unix() + 60, "nbf": 1e19}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifyToken(provider, signJWT(t, "HS256", "hmac", testHMACKey, tt.claims), now)
+			if tt.valid && err != nil {
+				t.Fatalf("expected a valid token, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestJWTIssuerAudienceAndClaims(t *testing.T) {
+	provider := newTestProvider(t, &JWTConfig{
+		Issuer:         "https://issuer.example",
+		Audiences:      []string{"orders", "payments"},
+		RequiredClaims: map[string]string{"scope": "write", "tenant": ""},
+	}, octJWK("hmac", testHMACKey))
+	now := time.Now()
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"exp":    now.Add(time.Hour).Unix(),
+			"iss":    "https://issuer.example",
+			"aud":    []string{"web", "payments"},
+			"scope":  []string{"read", "write"},
+			"tenant": "acme",
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		valid     bool
+	}{
+		{"valid", nil, true},
+		{"string audience", map[string]interface{}{"aud": "orders"}, true},
+		{"scalar required claim", map[string]interface{}{"scope": "write"}, true},
+		{"wrong issuer", map[string]interface{}{"iss": "https://evil.example"}, false},
+		{"no issuer", map[string]interface{}{"iss": nil}, false},
+		{"wrong audience", map[string]interface{}{"aud": []string{"web"}}, false},
+		{"no audience", map[string]interface{}{"aud": nil}, false},
+		{"wrong required claim", map[string]interface{}{"scope": []string{"read"}}, false},
+		{"missing required claim", map[string]interface{}{"tenant": nil}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifyToken(provider, signJWT(t, "HS256", "hmac", testHMACKey, claims(tt.overrides)), now)
+			if tt.valid && err != nil {
+				t.Fatalf("expected a valid token, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, octJWK("hmac", testHMACKey))
+	p := &Proxy{}
+	authn, err := p.newAuthenticator([]*JWTConfig{{
+		Name:         "test",
+		JWKSFile:     path,
+		ClaimHeaders: map[string]string{"sub": "X-User"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create the authenticator: %v", err)
+	}
+	defer authn.stop()
+	p.authn.Store(authn)
+
+	// A valid token forwards its claims, replacing the header sent by the client.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", "hmac", testHMACKey, validClaims(time.Now())))
+	req.Header.Set("X-User", "mallory")
+	if p.authenticate(httptest.NewRecorder(), req) {
+		t.Fatal("expected the request to be authenticated")
+	}
+	if got := req.Header.Get("X-User"); got != "alice" {
+		t.Fatalf("expected the verified subject to be forwarded, got %q", got)
+	}
+
+	// A request without a token is answered 401.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", "mallory")
+	w := httptest.NewRecorder()
+	if !p.authenticate(w, req) || w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	// A gRPC request is answered with Unauthenticated.
+	req = httptest.NewRequest(http.MethodPost, "/helloworld.Greeter/SayHello", nil)
+	req.ProtoMajor = 2
+	req.Header.Set("Content-Type", "application/grpc")
+	w = httptest.NewRecorder()
+	if !p.authenticate(w, req) || w.Header().Get("Grpc-Status") != "16" {
+		t.Fatalf("expected a gRPC Unauthenticated status, got %d with grpc-status %q", w.Code, w.Header().Get("Grpc-Status"))
+	}
+}
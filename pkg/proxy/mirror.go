@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// shadowHeader marks the requests mirrored to a shadow upstream.
+	shadowHeader = "X-Capa-Shadow"
+
+	defaultMirrorMaxBodyBytes = 64 * 1024
+	defaultMirrorTimeout      = 5 * time.Second
+
+	// maxMirroringRequests bounds the in-flight shadow requests. Requests are not
+	// mirrored while the bound is reached.
+	maxMirroringRequests = 128
+)
+
+// MirrorPolicy copies a percentage of the requests of a route to a shadow cluster.
+// Shadow requests are fire and forget: their responses are discarded.
+type MirrorPolicy struct {
+	Cluster string `json:"cluster"`
+	// Percentage of the route requests to mirror, in (0, 100]. Defaults to 100.
+	Percentage float64 `json:"percentage,omitempty"`
+	// MaxBodyBytes is the largest request body buffered for mirroring, 64KiB by default.
+	// Requests with larger bodies are not mirrored.
+	MaxBodyBytes int64    `json:"max_body_bytes,omitempty"`
+	Timeout      Duration `json:"timeout,omitempty"`
+}
+
+type mirrorPolicy struct {
+	cluster      *cluster
+	percentage   float64
+	maxBodyBytes int64
+	timeout      time.Duration
+}
+
+func (t *RouteTable) compileMirrorPolicy(mp *MirrorPolicy) (*mirrorPolicy, error) {
+	upstream, ok := t.cluster(mp.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("unknown mirror cluster %q", mp.Cluster)
+	}
+	if mp.Percentage < 0 || mp.Percentage > 100 {
+		return nil, fmt.Errorf("invalid mirror percentage %v", mp.Percentage)
+	}
+	if mp.MaxBodyBytes < 0 || mp.Timeout < 0 {
+		return nil, fmt.Errorf("mirror max_body_bytes and timeout must not be negative")
+	}
+	policy := &mirrorPolicy{
+		cluster:      upstream,
+		percentage:   mp.Percentage,
+		maxBodyBytes: mp.MaxBodyBytes,
+		timeout:      time.Duration(mp.Timeout),
+	}
+	if policy.maxBodyBytes == 0 {
+		policy.maxBodyBytes = defaultMirrorMaxBodyBytes
+	}
+	if policy.timeout == 0 {
+		policy.timeout = defaultMirrorTimeout
+	}
+	return policy, nil
+}
+
+// mirror copies the request to the shadow cluster of the route, if any, in the background.
+// Only the first MaxBodyBytes of the body are buffered: the primary request keeps
+// streaming the rest of its body, and requests with larger bodies are not mirrored.
+func (p *Proxy) mirror(req *http.Request, r *route) {
+	if r == nil || r.mirror == nil || !hitPercentage(r.mirror.percentage) {
+		return
+	}
+	if atomic.AddInt32(&p.mirroring, 1) > maxMirroringRequests {
+		atomic.AddInt32(&p.mirroring, -1)
+		log.Debugf("[Capa.proxy] too many shadow requests, skip mirroring of route %s", r.name)
+		return
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		buf := &bytes.Buffer{}
+		_, err := io.CopyN(buf, req.Body, r.mirror.maxBodyBytes+1)
+		body = buf.Bytes()
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err != io.EOF {
+			atomic.AddInt32(&p.mirroring, -1)
+			log.Debugf("[Capa.proxy] request body is too large, skip mirroring of route %s", r.name)
+			return
+		}
+	}
+
+	shadowReq := req.Clone(context.Background())
+	shadowReq.Header.Set(shadowHeader, "true")
+	shadowReq.Body = io.NopCloser(bytes.NewReader(body))
+	shadowReq.ContentLength = int64(len(body))
+
+	go func() {
+		defer atomic.AddInt32(&p.mirroring, -1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.mirror.timeout)
+		defer cancel()
+		res, err := p.send(ctx, shadowReq, r.mirror.cluster)
+		if err != nil {
+			log.Debugf("[Capa.proxy] shadow request of route %s failed: %v", r.name, err)
+			return
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}()
+}
+
+// readCloser reads from a reader and closes a closer, e.g. the original request body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
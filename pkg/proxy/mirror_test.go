@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder is an upstream recording the bodies and the shadow headers of its requests.
+type recorder struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	bodies  []string
+	shadows []string
+	// block, when set, holds the requests until it is closed.
+	block chan struct{}
+}
+
+func newRecorder(t *testing.T) *recorder {
+	t.Helper()
+	r := &recorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.lock.Lock()
+		r.bodies = append(r.bodies, string(body))
+		r.shadows = append(r.shadows, req.Header.Get(shadowHeader))
+		block := r.block
+		r.lock.Unlock()
+		if block != nil {
+			<-block
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *recorder) address() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *recorder) requests() ([]string, []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.bodies...), append([]string(nil), r.shadows...)
+}
+
+// newMirrorProxy starts a proxy routing every request to the primary upstream and
+// mirroring them to the shadow upstream.
+func newMirrorProxy(t *testing.T, primary *recorder, shadow *recorder, mirror *MirrorPolicy) (*Proxy, *httptest.Server) {
+	t.Helper()
+	mirror.Cluster = "shadow"
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{
+			{Name: "primary", Address: primary.address()},
+			{Name: "shadow", Address: shadow.address()},
+		},
+		Routes: []*RouteConfig{{
+			Name:    "mirrored",
+			Match:   &RouteMatch{PathPrefix: "/"},
+			Cluster: "primary",
+			Mirror:  mirror,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	t.Cleanup(front.Close)
+	return p, front
+}
+
+func post(t *testing.T, url string, body string) {
+	t.Helper()
+	res, err := http.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+}
+
+// waitMirroring waits until the proxy has the given number of in-flight shadow requests.
+func waitMirroring(t *testing.T, p *Proxy, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&p.mirroring) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d shadow requests in flight, got %d", n, atomic.LoadInt32(&p.mirroring))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMirrorShadowHeader(t *testing.T) {
+	primary, shadow := newRecorder(t), newRecorder(t)
+	p, front := newMirrorProxy(t, primary, shadow, &MirrorPolicy{})
+
+	post(t, front.URL+"/items", "hello capa")
+	waitMirroring(t, p, 0)
+
+	bodies, shadows := primary.requests()
+	if len(bodies) != 1 || bodies[0] != "hello capa" || shadows[0] != "" {
+		t.Fatalf("expected the primary request without the shadow header, got %q %q", bodies, shadows)
+	}
+	bodies, shadows = shadow.requests()
+	if len(bodies) != 1 || bodies[0] != "hello capa" || shadows[0] != "true" {
+		t.Fatalf("expected the shadow request with the shadow header, got %q %q", bodies, shadows)
+	}
+}
+
+func TestMirrorMaxBodyBytes(t *testing.T) {
+	primary, shadow := newRecorder(t), newRecorder(t)
+	p, front := newMirrorProxy(t, primary, shadow, &MirrorPolicy{MaxBodyBytes: 16})
+
+	large := strings.Repeat("x", 32)
+	post(t, front.URL+"/items", large)
+	post(t, front.URL+"/items", strings.Repeat("y", 16))
+	waitMirroring(t, p, 0)
+
+	bodies, _ := primary.requests()
+	if len(bodies) != 2 || bodies[0] != large {
+		t.Fatalf("expected the primary to get the whole large body, got %q", bodies)
+	}
+	bodies, _ = shadow.requests()
+	if len(bodies) != 1 || bodies[0] != strings.Repeat("y", 16) {
+		t.Fatalf("expected only the body within the limit to be mirrored, got %q", bodies)
+	}
+}
+
+func TestMirrorPercentage(t *testing.T) {
+	primary, shadow := newRecorder(t), newRecorder(t)
+	p, front := newMirrorProxy(t, primary, shadow, &MirrorPolicy{Percentage: 25})
+
+	const requests = 400
+	for i := 0; i < requests; i++ {
+		post(t, front.URL+"/items", "")
+	}
+	waitMirroring(t, p, 0)
+
+	bodies, _ := shadow.requests()
+	// 100 are expected, with a standard deviation below 9.
+	if len(bodies) < 50 || len(bodies) > 150 {
+		t.Fatalf("expected about 25%% of %d requests to be mirrored, got %d", requests, len(bodies))
+	}
+}
+
+func TestMirrorInFlightCap(t *testing.T) {
+	primary, shadow := newRecorder(t), newRecorder(t)
+	shadow.block = make(chan struct{})
+	p, front := newMirrorProxy(t, primary, shadow, &MirrorPolicy{})
+
+	for i := 0; i < maxMirroringRequests+10; i++ {
+		post(t, front.URL+"/items", "")
+	}
+	waitMirroring(t, p, maxMirroringRequests)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if bodies, _ := shadow.requests(); len(bodies) == maxMirroringRequests {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d shadow requests", maxMirroringRequests)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if bodies, _ := primary.requests(); len(bodies) != maxMirroringRequests+10 {
+		t.Fatalf("expected every primary request, got %d", len(bodies))
+	}
+
+	// Requests are mirrored again once the shadow requests are done.
+	close(shadow.block)
+	waitMirroring(t, p, 0)
+	post(t, front.URL+"/items", "")
+	waitMirroring(t, p, 0)
+	if bodies, _ := shadow.requests(); len(bodies) != maxMirroringRequests+1 {
+		t.Fatalf("expected the dropped shadow requests not to be sent, got %d", len(bodies))
+	}
+}
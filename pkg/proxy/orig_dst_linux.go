@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// soOriginalDst is the SO_ORIGINAL_DST socket option of netfilter.
+const soOriginalDst = 80
+
+// originalDestination returns the destination of a connection before it was redirected
+// to the proxy by iptables. Only IPv4 connections are supported.
+func originalDestination(conn net.Conn) (string, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return "", fmt.Errorf("not a tcp connection")
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+
+	// The sockaddr_in of the destination is returned in place of the IPv6Mreq:
+	// the family on 2 bytes, the port on 2 bytes in network order, then the IPv4.
+	var addr *syscall.IPv6Mreq
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		addr, sockErr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.SOL_IP, soOriginalDst)
+	})
+	if err != nil {
+		return "", err
+	}
+	if sockErr != nil {
+		return "", sockErr
+	}
+	b := addr.Multiaddr
+	ip := net.IPv4(b[4], b[5], b[6], b[7])
+	port := int(b[2])<<8 | int(b[3])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
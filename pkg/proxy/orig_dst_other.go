@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package proxy
+
+import (
+	"net"
+)
+
+// originalDestination returns the local address of the connection, as the connections
+// are only redirected to the proxy by iptables on Linux.
+func originalDestination(conn net.Conn) (string, error) {
+	return conn.LocalAddr().String(), nil
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker"
+
+	"group.rxcloud/capa/utils"
+)
+
+const (
+	defaultRetryBackoff        = 25 * time.Millisecond
+	defaultCircuitCoolDown     = 30 * time.Second
+	defaultConsecutiveFailures = 5
+)
+
+var (
+	// defaultRetryOn are the upstream status codes retried when a retry policy sets none.
+	defaultRetryOn = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+	// idempotentMethods are the methods retried when a retry policy doesn't allow all methods.
+	idempotentMethods = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodTrace:   true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+	}
+
+	errCircuitOpen = errors.New("circuit breaker is open")
+)
+
+// RetryPolicy retries failed upstream requests with an exponential backoff.
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first try.
+	Attempts   int      `json:"attempts"`
+	Backoff    Duration `json:"backoff,omitempty"`
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+	// RetryOn are the retriable upstream status codes. Connection errors are always retriable.
+	RetryOn []int `json:"retry_on,omitempty"`
+	// RetryNonIdempotent allows retrying requests with non-idempotent methods like POST.
+	RetryNonIdempotent bool `json:"retry_non_idempotent,omitempty"`
+}
+
+// CircuitBreakerPolicy opens the circuit of a route after consecutive upstream failures,
+// and half-opens it after a cool-down to probe whether the upstream has recovered.
+type CircuitBreakerPolicy struct {
+	ConsecutiveFailures uint32   `json:"consecutive_failures,omitempty"`
+	CoolDown            Duration `json:"cool_down,omitempty"`
+	// HalfOpenRequests is the number of probe requests allowed while half-open.
+	HalfOpenRequests uint32 `json:"half_open_requests,omitempty"`
+}
+
+// CircuitState is the circuit breaker state of a route, as shown on the admin API.
+type CircuitState struct {
+	Route               string `json:"route"`
+	State               string `json:"state"`
+	Requests            uint32 `json:"requests"`
+	ConsecutiveFailures uint32 `json:"consecutive_failures"`
+}
+
+type retryPolicy struct {
+	attempts           int
+	backoff            utils.Backoff
+	retryOn            map[int]bool
+	retryNonIdempotent bool
+}
+
+func compileRetryPolicy(rp *RetryPolicy) (*retryPolicy, error) {
+	if rp.Attempts < 0 {
+		return nil, fmt.Errorf("negative retry attempts %d", rp.Attempts)
+	}
+	backoff, err := utils.NewBackoff(rp.Backoff, rp.MaxBackoff, defaultRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	policy := &retryPolicy{
+		attempts:           rp.Attempts,
+		backoff:            backoff,
+		retryOn:            map[int]bool{},
+		retryNonIdempotent: rp.RetryNonIdempotent,
+	}
+	retryOn := rp.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	for _, code := range retryOn {
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid retriable status code %d", code)
+		}
+		policy.retryOn[code] = true
+	}
+	return policy, nil
+}
+
+// retriable reports whether a request with the method may be retried at all.
+func (rp *retryPolicy) retriable(method string) bool {
+	return rp != nil && rp.attempts > 0 && (rp.retryNonIdempotent || idempotentMethods[method])
+}
+
+func newCircuitBreaker(routeName string, cb *CircuitBreakerPolicy) *gobreaker.TwoStepCircuitBreaker {
+	failures := cb.ConsecutiveFailures
+	if failures == 0 {
+		failures = defaultConsecutiveFailures
+	}
+	coolDown := time.Duration(cb.CoolDown)
+	if coolDown <= 0 {
+		coolDown = defaultCircuitCoolDown
+	}
+	return gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        routeName,
+		MaxRequests: cb.HalfOpenRequests,
+		Timeout:     coolDown,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= failures
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			log.Warnf("[Capa.proxy] circuit breaker of route %s changed from %s to %s", name, from, to)
+		},
+	})
+}
+
+// CircuitStates returns the circuit breaker states of the active routes.
+func (p *Proxy) CircuitStates() []CircuitState {
+	states := []CircuitState{}
+	for _, r := range p.routeTable().routes {
+		if r.breaker == nil {
+			continue
+		}
+		counts := r.breaker.Counts()
+		states = append(states, CircuitState{
+			Route:               r.name,
+			State:               r.breaker.State().String(),
+			Requests:            counts.Requests,
+			ConsecutiveFailures: counts.ConsecutiveFailures,
+		})
+	}
+	return states
+}
+
+// roundTrip sends the request to the cluster, applying the circuit breaker and the
+// retry policy of the route, and returns the number of retries. The route may be nil.
+func (p *Proxy) roundTrip(ctx context.Context, req *http.Request, r *route, upstream *cluster) (*http.Response, int, error) {
+	var retry *retryPolicy
+	if r != nil && r.retry.retriable(req.Method) {
+		retry = r.retry
+	}
+
+	// Buffer the request body so it can be sent again on retries.
+	var body []byte
+	if retry != nil && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, 0, err
+		}
+		req.Body.Close()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if retry != nil {
+			// Keep the length of the buffered body, so it isn't sent chunked.
+			req.Body, req.ContentLength = http.NoBody, int64(len(body))
+			if len(body) > 0 {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		res, err := p.tryOnce(ctx, req, r, upstream)
+		if retry == nil || attempt >= retry.attempts || errors.Is(err, errCircuitOpen) || ctx.Err() != nil {
+			return res, attempt, err
+		}
+		if err == nil && !retry.retryOn[res.StatusCode] {
+			return res, attempt, nil
+		}
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(retry.backoff.Of(attempt + 1)):
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		}
+	}
+}
+
+// tryOnce sends the request once, guarded by the circuit breaker of the route.
+func (p *Proxy) tryOnce(ctx context.Context, req *http.Request, r *route, upstream *cluster) (*http.Response, error) {
+	if r == nil || r.breaker == nil {
+		return p.send(ctx, req, upstream)
+	}
+
+	done, err := r.breaker.Allow()
+	if err != nil {
+		return nil, errCircuitOpen
+	}
+	res, err := p.send(ctx, req, upstream)
+	done(err == nil && res.StatusCode < http.StatusInternalServerError)
+	return res, err
+}
+
+// statusOfError maps an error of forwarding a request to the status returned to the caller.
+func statusOfError(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errCircuitOpen):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRetryKeepsContentLength(t *testing.T) {
+	type attempt struct {
+		contentLength    int64
+		transferEncoding []string
+		body             string
+	}
+	var lock sync.Mutex
+	var attempts []attempt
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		lock.Lock()
+		attempts = append(attempts, attempt{req.ContentLength, req.TransferEncoding, string(body)})
+		n := len(attempts)
+		lock.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{{Name: "upstream", Address: strings.TrimPrefix(upstream.URL, "http://")}},
+		Routes: []*RouteConfig{{
+			Name:    "retried",
+			Match:   &RouteMatch{PathPrefix: "/"},
+			Cluster: "upstream",
+			Retry:   &RetryPolicy{Attempts: 2, Backoff: Duration(1)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	for _, body := range []string{"hello capa", ""} {
+		attempts = nil
+		req, _ := http.NewRequest(http.MethodPut, front.URL+"/items", strings.NewReader(body))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected the retry to succeed, got status %d", res.StatusCode)
+		}
+		if len(attempts) != 2 {
+			t.Fatalf("expected 2 attempts, got %d", len(attempts))
+		}
+		for i, a := range attempts {
+			if a.contentLength != int64(len(body)) || len(a.transferEncoding) != 0 || a.body != body {
+				t.Fatalf("attempt %d: expected %d bytes with a content length, got %+v", i, len(body), a)
+			}
+		}
+	}
+}
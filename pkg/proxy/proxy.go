@@ -1,97 +1,180 @@
 package proxy
 
 import (
-	"sync"
-	"time"
+	"fmt"
+	"net"
+	"os"
+	"strings"
 )
 
-// Proxy contains information about an specific instance of a proxy (envoy sidecar, gateway,
-// etc). The Proxy is initialized when a sidecar connects to Pilot, and populated from
-// 'node' info in the protocol as well as data extracted from registries.
-//
-// In current Istio implementation nodes use a 4-parts '~' delimited ID.
-// Type~IPAddress~ID~Domain
-type ProxyConfig struct {
-	sync.RWMutex
-
-	// Type specifies the node type. First part of the ID.
-	Type NodeType
-
-	// IPAddresses is the IP addresses of the proxy used to identify it and its
-	// co-located service instances. Example: "10.60.1.6". In some cases, the host
-	// where the proxy and service instances reside may have more than one IP address
-	IPAddresses []string
-
-	// ID is the unique platform-specific sidecar proxy ID. For k8s it is the pod ID and
-	// namespace <podName.namespace>.
-	ID string
-
-	// Locality is the location of where Envoy proxy runs. This is extracted from
-	// the registry where possible. If the registry doesn't provide a locality for the
-	// proxy it will use the one sent via ADS that can be configured in the Envoy bootstrap
-	Locality *core.Locality
-
-	// DNSDomain defines the DNS domain suffix for short hostnames (e.g.
-	// "default.svc.cluster.local")
-	DNSDomain string
-
-	// ConfigNamespace defines the namespace where this proxy resides
-	// for the purposes of network scoping.
-	// NOTE: DO NOT USE THIS FIELD TO CONSTRUCT DNS NAMES
-	ConfigNamespace string
-
-	// Metadata key-value pairs extending the Node identifier
-	Metadata *NodeMetadata
-
-	// the sidecarScope associated with the proxy
-	SidecarScope *SidecarScope
-
-	// the sidecarScope associated with the proxy previously
-	PrevSidecarScope *SidecarScope
-
-	// The merged gateways associated with the proxy if this is a Router
-	MergedGateway *MergedGateway
-
-	// service instances associated with the proxy
-	ServiceInstances []*ServiceInstance
-
-	// Istio version associated with the Proxy
-	IstioVersion *IstioVersion
-
-	// VerifiedIdentity determines whether a proxy had its identity verified. This
-	// generally occurs by JWT or mTLS authentication. This can be false when
-	// connecting over plaintext. If this is set to true, we can verify the proxy has
-	// access to ConfigNamespace namespace. However, other options such as node type
-	// are not part of an Istio identity and thus are not verified.
-	VerifiedIdentity *spiffe.Identity
-
-	// IPMode of proxy.
-	ipMode IPMode
-
-	// GlobalUnicastIP stores the global unicast IP if available, otherwise nil
-	GlobalUnicastIP string
-
-	// XdsResourceGenerator is used to generate resources for the node, based on the PushContext.
-	// If nil, the default networking/core v2 generator is used. This field can be set
-	// at connect time, based on node metadata, to trigger generation of a different style
-	// of configuration.
-	XdsResourceGenerator XdsResourceGenerator
-
-	// WatchedResources contains the list of watched resources for the proxy, keyed by the DiscoveryRequest TypeUrl.
-	WatchedResources map[string]*WatchedResource
-
-	// XdsNode is the xDS node identifier
-	XdsNode *core.Node
-
-	AutoregisteredWorkloadEntryName string
-
-	// LastPushContext stores the most recent push context for this proxy. This will be monotonically
-	// increasing in version. Requests should send config based on this context; not the global latest.
-	// Historically, the latest was used which can cause problems when computing whether a push is
-	// required, as the computed sidecar scope version would not monotonically increase.
-	LastPushContext *PushContext
-	// LastPushTime records the time of the last push. This is used in conjunction with
-	// LastPushContext; the XDS cache depends on knowing the time of the PushContext to determine if a
-	// key is stale or not.
-	LastPushTime time.Time
+// The environment variables the node is populated from. The pod variables are set with
+// the Kubernetes downward API, the others by the sidecar injector.
+const (
+	PodNameEnv      = "POD_NAME"
+	PodNamespaceEnv = "POD_NAMESPACE"
+	// InstanceIPsEnv is a comma separated list of IPs, like status.podIPs.
+	InstanceIPsEnv = "INSTANCE_IPS"
+	// InstanceIPEnv is the primary IP, like status.podIP. Used when InstanceIPsEnv is unset.
+	InstanceIPEnv = "INSTANCE_IP"
+	NodeTypeEnv   = "CAPA_NODE_TYPE"
+	// LocalityEnv is a "region/zone/sub_zone" locality, any trailing part may be omitted.
+	LocalityEnv = "CAPA_LOCALITY"
+	// CapabilitiesEnv is a comma separated list of capabilities added to the ones of the
+	// running components.
+	CapabilitiesEnv = "CAPA_CAPABILITIES"
+
+	// The app config of a standalone proxy, the sidecar takes it from its runtime config.
+	AppIdEnv    = "CAPA_APP_ID"
+	AppEnvEnv   = "CAPA_ENV"
+	AppCloudEnv = "CAPA_CLOUD"
+)
+
+// The capabilities of a node.
+const (
+	CapabilityHTTPProxy = "http_proxy"
+	CapabilityGRPCProxy = "grpc_proxy"
+	CapabilityTCPProxy  = "tcp_proxy"
+	CapabilityUpgrade   = "upgrade"
+	CapabilityCapture   = "capture"
+	CapabilityJWT       = "jwt"
+	CapabilityAccessLog = "access_log"
+)
+
+// Node is the identity of a Capa node: the sidecar proxy of an app, or an app using Capa
+// without a proxy.
+type Node struct {
+	// Type is the role of the node, a sidecar by default.
+	Type NodeType `json:"type"`
+
+	// PodName and PodNamespace identify the pod of the node. PodName defaults to the hostname.
+	PodName      string `json:"pod_name"`
+	PodNamespace string `json:"pod_namespace,omitempty"`
+
+	// IPAddresses are the IPs of the pod, the primary IP first. They default to the
+	// IPs of the non-loopback interfaces.
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+
+	// Locality is where the node runs, nil when unknown.
+	Locality *Locality `json:"locality,omitempty"`
+
+	// AppId, Cloud and Env are those of the app config.
+	AppId string `json:"app_id"`
+	Cloud string `json:"cloud,omitempty"`
+	Env   string `json:"env,omitempty"`
+
+	// Capabilities are the features the node provides. NodeFromEnv only sets those of
+	// CapabilitiesEnv, the runtime adds the ones of its running components.
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Locality is the location of a node.
+type Locality struct {
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	SubZone string `json:"sub_zone,omitempty"`
+}
+
+// ID returns the unique id of the node, "<pod name>.<pod namespace>".
+func (n *Node) ID() string {
+	if n.PodNamespace == "" {
+		return n.PodName
+	}
+	return n.PodName + "." + n.PodNamespace
+}
+
+// HasCapability returns whether the node provides the capability.
+func (n *Node) HasCapability(capability string) bool {
+	for _, c := range n.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCapabilities adds the capabilities the node doesn't provide yet.
+func (n *Node) AddCapabilities(capabilities ...string) {
+	for _, c := range capabilities {
+		if !n.HasCapability(c) {
+			n.Capabilities = append(n.Capabilities, c)
+		}
+	}
+}
+
+// NodeFromEnv returns the node of the app from the environment.
+func NodeFromEnv(appId, env, cloud string) (*Node, error) {
+	n := &Node{
+		Type:         SidecarProxy,
+		PodName:      os.Getenv(PodNameEnv),
+		PodNamespace: os.Getenv(PodNamespaceEnv),
+		AppId:        appId,
+		Cloud:        cloud,
+		Env:          env,
+	}
+	if t := os.Getenv(NodeTypeEnv); t != "" {
+		n.Type = NodeType(t)
+		if !n.Type.IsValid() {
+			return nil, fmt.Errorf("invalid node type %q", t)
+		}
+	}
+	if n.PodName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		n.PodName = hostname
+	}
+
+	ips := os.Getenv(InstanceIPsEnv)
+	if ips == "" {
+		ips = os.Getenv(InstanceIPEnv)
+	}
+	for _, ip := range splitList(ips) {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid instance ip %q", ip)
+		}
+		n.IPAddresses = append(n.IPAddresses, ip)
+	}
+	if len(n.IPAddresses) == 0 {
+		n.IPAddresses = interfaceIPs()
+	}
+
+	if l := os.Getenv(LocalityEnv); l != "" {
+		parts := strings.Split(l, "/")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid locality %q", l)
+		}
+		parts = append(parts, "", "")
+		n.Locality = &Locality{Region: parts[0], Zone: parts[1], SubZone: parts[2]}
+	}
+
+	n.AddCapabilities(splitList(os.Getenv(CapabilitiesEnv))...)
+	return n, nil
+}
+
+// interfaceIPs returns the IPs of the non-loopback interfaces that are up.
+func interfaceIPs() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP.String())
+	}
+	return ips
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
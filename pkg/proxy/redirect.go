@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// The nat chains set up by capa init. The traffic enters them from the jump rules of
+// PREROUTING and OUTPUT, which the bypass redirect mode removes and restores, so the
+// rules in the chains are left as capa init wrote them.
+const (
+	redirectChain = "CAPA_REDIRECT"
+	inboundChain  = "CAPA_INBOUND"
+	outboundChain = "CAPA_OUTPUT"
+)
+
+// jumpRules are the rules of the built-in chains sending the traffic to the Capa chains.
+var jumpRules = [][]string{
+	{"PREROUTING", "-p", "tcp", "-j", inboundChain},
+	{"OUTPUT", "-p", "tcp", "-j", outboundChain},
+}
+
+// RedirectConfig is the traffic redirected to the proxy by iptables.
+type RedirectConfig struct {
+	// ProxyPort is the port the intercepted connections are redirected to.
+	ProxyPort int
+	// InboundPorts are the ports of the app whose inbound connections are redirected.
+	InboundPorts []int
+	// Outbound redirects the outbound connections of the pod, except those of the
+	// ProxyUID user, which the proxy runs as, and those to loopback.
+	Outbound bool
+	ProxyUID int
+	// ExcludeOutboundPorts are the destination ports of the outbound connections not redirected.
+	ExcludeOutboundPorts []int
+}
+
+// DefaultRedirectConfig returns the redirect config of the default proxy port.
+func DefaultRedirectConfig() *RedirectConfig {
+	return &RedirectConfig{ProxyPort: proxyPort}
+}
+
+// runIptables runs iptables with the arguments. It is replaced in tests.
+var runIptables = func(args ...string) error {
+	out, err := exec.Command("iptables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Rules returns the iptables commands setting up the redirect, each as the arguments
+// of iptables.
+func (rc *RedirectConfig) Rules() [][]string {
+	nat := func(args ...string) []string {
+		return append([]string{"-t", "nat"}, args...)
+	}
+	rules := [][]string{
+		nat("-N", redirectChain),
+		nat("-A", redirectChain, "-p", "tcp", "-j", "REDIRECT", "--to-ports", strconv.Itoa(rc.ProxyPort)),
+		nat("-N", inboundChain),
+	}
+	for _, port := range rc.InboundPorts {
+		rules = append(rules, nat("-A", inboundChain, "-p", "tcp", "--dport", strconv.Itoa(port), "-j", redirectChain))
+	}
+	rules = append(rules, nat("-N", outboundChain))
+	if rc.Outbound {
+		rules = append(rules,
+			nat("-A", outboundChain, "-m", "owner", "--uid-owner", strconv.Itoa(rc.ProxyUID), "-j", "RETURN"),
+			nat("-A", outboundChain, "-d", "127.0.0.1/32", "-j", "RETURN"))
+		for _, port := range rc.ExcludeOutboundPorts {
+			rules = append(rules, nat("-A", outboundChain, "-p", "tcp", "--dport", strconv.Itoa(port), "-j", "RETURN"))
+		}
+		rules = append(rules, nat("-A", outboundChain, "-p", "tcp", "-j", redirectChain))
+	}
+	for _, jump := range jumpRules {
+		rules = append(rules, nat(append([]string{"-A"}, jump...)...))
+	}
+	return rules
+}
+
+// Validate checks the ports and the uid of the config.
+func (rc *RedirectConfig) Validate() error {
+	for _, port := range append(append([]int{rc.ProxyPort}, rc.InboundPorts...), rc.ExcludeOutboundPorts...) {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if rc.Outbound && rc.ProxyUID <= 0 {
+		return fmt.Errorf("the proxy uid is required to redirect the outbound connections, and must not be root")
+	}
+	return nil
+}
+
+// SetupRedirect removes the previous redirect, then redirects the traffic to the proxy.
+func SetupRedirect(rc *RedirectConfig) error {
+	if err := rc.Validate(); err != nil {
+		return err
+	}
+	CleanRedirect()
+	for _, rule := range rc.Rules() {
+		if err := runIptables(rule...); err != nil {
+			CleanRedirect()
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanRedirect removes the jump rules and the chains of the redirect. The rules that
+// don't exist are skipped.
+func CleanRedirect() {
+	RemoveRedirect()
+	for _, chain := range []string{inboundChain, outboundChain, redirectChain} {
+		runIptables("-t", "nat", "-F", chain)
+		runIptables("-t", "nat", "-X", chain)
+	}
+}
+
+// RemoveRedirect removes the jump rules, so the traffic no longer reaches the proxy.
+// The chains set up by capa init are kept for RestoreRedirect.
+func RemoveRedirect() error {
+	var firstErr error
+	for _, jump := range jumpRules {
+		// Delete all the copies of the rule, it may have been added more than once.
+		for runIptables(append([]string{"-t", "nat", "-C"}, jump...)...) == nil {
+			if err := runIptables(append([]string{"-t", "nat", "-D"}, jump...)...); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				break
+			}
+		}
+	}
+	return firstErr
+}
+
+// RestoreRedirect adds the jump rules removed by RemoveRedirect back, unless they exist.
+func RestoreRedirect() error {
+	for _, jump := range jumpRules {
+		if runIptables(append([]string{"-t", "nat", "-C"}, jump...)...) == nil {
+			continue
+		}
+		if err := runIptables(append([]string{"-t", "nat", "-A"}, jump...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
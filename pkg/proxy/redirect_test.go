@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeIptables keeps the rules of the nat table, keyed by chain.
+type fakeIptables struct {
+	rules map[string][]string
+}
+
+func useFakeIptables(t *testing.T) *fakeIptables {
+	f := &fakeIptables{rules: map[string][]string{"PREROUTING": nil, "OUTPUT": nil}}
+	saved := runIptables
+	runIptables = f.run
+	t.Cleanup(func() { runIptables = saved })
+	return f
+}
+
+func (f *fakeIptables) run(args ...string) error {
+	if len(args) < 4 || args[0] != "-t" || args[1] != "nat" {
+		return errors.New("unexpected command")
+	}
+	op, chain, rule := args[2], args[3], strings.Join(args[4:], " ")
+	_, exists := f.rules[chain]
+	switch op {
+	case "-N":
+		if exists {
+			return errors.New("chain exists")
+		}
+		f.rules[chain] = nil
+	case "-X":
+		if !exists || len(f.rules[chain]) > 0 {
+			return errors.New("cannot delete chain")
+		}
+		delete(f.rules, chain)
+	case "-F":
+		if !exists {
+			return errors.New("no chain")
+		}
+		f.rules[chain] = nil
+	case "-A":
+		if !exists {
+			return errors.New("no chain")
+		}
+		f.rules[chain] = append(f.rules[chain], rule)
+	case "-C", "-D":
+		for i, r := range f.rules[chain] {
+			if r == rule {
+				if op == "-D" {
+					f.rules[chain] = append(f.rules[chain][:i], f.rules[chain][i+1:]...)
+				}
+				return nil
+			}
+		}
+		return errors.New("no such rule")
+	}
+	return nil
+}
+
+func TestRedirectBypass(t *testing.T) {
+	f := useFakeIptables(t)
+	rc := &RedirectConfig{ProxyPort: 8000, InboundPorts: []int{80}, Outbound: true, ProxyUID: 1337}
+	if err := SetupRedirect(rc); err != nil {
+		t.Fatalf("failed to set up the redirect: %v", err)
+	}
+	if len(f.rules["PREROUTING"]) != 1 || len(f.rules["OUTPUT"]) != 1 || len(f.rules[outboundChain]) != 3 {
+		t.Fatalf("unexpected rules %v", f.rules)
+	}
+
+	// The bypass only removes the jumps, and restores them once.
+	if err := RemoveRedirect(); err != nil {
+		t.Fatalf("failed to remove the redirect: %v", err)
+	}
+	if len(f.rules["PREROUTING"]) != 0 || len(f.rules["OUTPUT"]) != 0 || len(f.rules[inboundChain]) != 1 {
+		t.Fatalf("expected only the jumps to be removed, got %v", f.rules)
+	}
+	for i := 0; i < 2; i++ {
+		if err := RestoreRedirect(); err != nil {
+			t.Fatalf("failed to restore the redirect: %v", err)
+		}
+	}
+	if len(f.rules["PREROUTING"]) != 1 || len(f.rules["OUTPUT"]) != 1 {
+		t.Fatalf("expected the jumps to be restored once, got %v", f.rules)
+	}
+
+	CleanRedirect()
+	if len(f.rules) != 2 || len(f.rules["PREROUTING"]) != 0 || len(f.rules["OUTPUT"]) != 0 {
+		t.Fatalf("expected the redirect to be removed, got %v", f.rules)
+	}
+}
+
+func TestRedirectRequiresProxyUID(t *testing.T) {
+	useFakeIptables(t)
+	if err := SetupRedirect(&RedirectConfig{ProxyPort: 8000, Outbound: true}); err == nil {
+		t.Fatal("expected the outbound redirect without a proxy uid to be rejected")
+	}
+}
@@ -0,0 +1,405 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// defaultClusterName is the cluster of the local application service, used when
+// no route matches a request. The requests of redirected connections go to their
+// original destination instead.
+const defaultClusterName = "local"
+
+// RouteConfig routes the requests matched by Match to an upstream cluster.
+// Exactly one of Cluster or WeightedClusters must be set.
+type RouteConfig struct {
+	Name             string             `json:"name"`
+	Match            *RouteMatch        `json:"match,omitempty"`
+	Cluster          string             `json:"cluster,omitempty"`
+	WeightedClusters []*WeightedCluster `json:"weighted_clusters,omitempty"`
+
+	// Timeout bounds the whole request to the upstream, retries included.
+	Timeout        Duration              `json:"timeout,omitempty"`
+	Retry          *RetryPolicy          `json:"retry,omitempty"`
+	CircuitBreaker *CircuitBreakerPolicy `json:"circuit_breaker,omitempty"`
+	Mirror         *MirrorPolicy         `json:"mirror,omitempty"`
+	// IdleTimeout closes the upgraded connections, e.g. WebSocket, idle for that long. Defaults to 1h.
+	IdleTimeout Duration `json:"idle_timeout,omitempty"`
+}
+
+// RouteMatch holds the criteria a request must satisfy to match a route.
+// Empty criteria match every request.
+type RouteMatch struct {
+	// Host is an exact host, or a wildcard host like "*.example.com".
+	Host       string         `json:"host,omitempty"`
+	PathPrefix string         `json:"path_prefix,omitempty"`
+	PathRegex  string         `json:"path_regex,omitempty"`
+	Methods    []string       `json:"methods,omitempty"`
+	Headers    []*HeaderMatch `json:"headers,omitempty"`
+	// GrpcService and GrpcMethod match the gRPC requests by the service and method names
+	// of their path, e.g. "helloworld.Greeter" and "SayHello" for "/helloworld.Greeter/SayHello".
+	GrpcService string `json:"grpc_service,omitempty"`
+	GrpcMethod  string `json:"grpc_method,omitempty"`
+}
+
+// HeaderMatch matches a request header by exact value, by regex, or by presence.
+type HeaderMatch struct {
+	Name    string `json:"name"`
+	Exact   string `json:"exact,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Present bool   `json:"present,omitempty"`
+	Invert  bool   `json:"invert,omitempty"`
+}
+
+// WeightedCluster is one split of a weighted route, e.g. the canary release of a service.
+type WeightedCluster struct {
+	Cluster string `json:"cluster"`
+	Weight  int    `json:"weight"`
+}
+
+// RouteTable is an immutable, validated set of routes. Routes are matched in order.
+type RouteTable struct {
+	routes   []*route
+	clusters map[string]*cluster
+}
+
+type route struct {
+	*requestMatcher
+
+	name        string
+	clusters    []*WeightedCluster
+	totalWeight int
+
+	timeout time.Duration
+	retry   *retryPolicy
+	breaker *gobreaker.TwoStepCircuitBreaker
+	mirror  *mirrorPolicy
+
+	idleTimeout time.Duration
+}
+
+// requestMatcher is the compiled form of a RouteMatch.
+type requestMatcher struct {
+	host       string
+	pathPrefix string
+	pathRegex  *regexp.Regexp
+	methods    map[string]bool
+	headers    []*headerMatcher
+
+	grpcService string
+	grpcMethod  string
+}
+
+type headerMatcher struct {
+	name    string
+	exact   string
+	regex   *regexp.Regexp
+	present bool
+	invert  bool
+}
+
+// NewRouteTable validates the config and builds a route table from it.
+// A config with any invalid rule is rejected as a whole.
+func NewRouteTable(cfg *Config) (*RouteTable, error) {
+	local, _ := newCluster(&ClusterConfig{Name: defaultClusterName, Address: fmt.Sprintf("127.0.0.1:%d", servicePort)})
+	table := &RouteTable{
+		clusters: map[string]*cluster{
+			defaultClusterName: local,
+		},
+	}
+
+	declared := map[string]bool{}
+	for _, cc := range cfg.Clusters {
+		c, err := newCluster(cc)
+		if err != nil {
+			return nil, err
+		}
+		if c.name == defaultClusterName {
+			return nil, fmt.Errorf("cluster name %q is reserved for the local service", c.name)
+		}
+		if declared[c.name] {
+			return nil, fmt.Errorf("duplicate cluster %q", c.name)
+		}
+		declared[c.name] = true
+		table.clusters[c.name] = c
+	}
+
+	names := map[string]bool{}
+	for i, rc := range cfg.Routes {
+		if rc == nil {
+			return nil, fmt.Errorf("route #%d is empty", i)
+		}
+		if rc.Name == "" {
+			return nil, fmt.Errorf("route #%d: name is required", i)
+		}
+		if names[rc.Name] {
+			return nil, fmt.Errorf("duplicate route %q", rc.Name)
+		}
+		names[rc.Name] = true
+
+		r, err := table.compileRoute(rc)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %v", rc.Name, err)
+		}
+		table.routes = append(table.routes, r)
+	}
+	return table, nil
+}
+
+func (t *RouteTable) compileRoute(rc *RouteConfig) (*route, error) {
+	matcher, err := compileMatch(rc.Match)
+	if err != nil {
+		return nil, err
+	}
+	r := &route{requestMatcher: matcher, name: rc.Name}
+
+	switch {
+	case rc.Cluster != "" && len(rc.WeightedClusters) > 0:
+		return nil, fmt.Errorf("cluster and weighted_clusters are mutually exclusive")
+	case rc.Cluster != "":
+		r.clusters = []*WeightedCluster{{Cluster: rc.Cluster, Weight: 1}}
+	case len(rc.WeightedClusters) > 0:
+		r.clusters = rc.WeightedClusters
+	default:
+		return nil, fmt.Errorf("one of cluster or weighted_clusters is required")
+	}
+
+	for _, wc := range r.clusters {
+		if wc == nil {
+			return nil, fmt.Errorf("weighted cluster is empty")
+		}
+		if _, ok := t.cluster(wc.Cluster); !ok {
+			return nil, fmt.Errorf("unknown cluster %q", wc.Cluster)
+		}
+		if wc.Weight < 0 {
+			return nil, fmt.Errorf("cluster %q: negative weight %d", wc.Cluster, wc.Weight)
+		}
+		r.totalWeight += wc.Weight
+	}
+	if r.totalWeight == 0 {
+		return nil, fmt.Errorf("total weight of clusters must be positive")
+	}
+
+	if rc.Timeout < 0 {
+		return nil, fmt.Errorf("negative timeout %s", time.Duration(rc.Timeout))
+	}
+	r.timeout = time.Duration(rc.Timeout)
+	if rc.IdleTimeout < 0 {
+		return nil, fmt.Errorf("negative idle timeout %s", time.Duration(rc.IdleTimeout))
+	}
+	r.idleTimeout = time.Duration(rc.IdleTimeout)
+	if rc.Retry != nil {
+		retry, err := compileRetryPolicy(rc.Retry)
+		if err != nil {
+			return nil, err
+		}
+		r.retry = retry
+	}
+	if rc.CircuitBreaker != nil {
+		r.breaker = newCircuitBreaker(rc.Name, rc.CircuitBreaker)
+	}
+	if rc.Mirror != nil {
+		mirror, err := t.compileMirrorPolicy(rc.Mirror)
+		if err != nil {
+			return nil, err
+		}
+		r.mirror = mirror
+	}
+	return r, nil
+}
+
+// compileMatch compiles the match criteria. A nil match matches every request.
+func compileMatch(m *RouteMatch) (*requestMatcher, error) {
+	matcher := &requestMatcher{}
+	if m == nil {
+		return matcher, nil
+	}
+
+	matcher.host = strings.ToLower(m.Host)
+	matcher.pathPrefix = m.PathPrefix
+	if m.PathRegex != "" {
+		re, err := regexp.Compile(m.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex: %v", err)
+		}
+		matcher.pathRegex = re
+	}
+	if len(m.Methods) > 0 {
+		matcher.methods = map[string]bool{}
+		for _, method := range m.Methods {
+			matcher.methods[strings.ToUpper(method)] = true
+		}
+	}
+	for _, hm := range m.Headers {
+		hmatcher, err := compileHeaderMatch(hm)
+		if err != nil {
+			return nil, err
+		}
+		matcher.headers = append(matcher.headers, hmatcher)
+	}
+	matcher.grpcService = m.GrpcService
+	matcher.grpcMethod = m.GrpcMethod
+	return matcher, nil
+}
+
+func compileHeaderMatch(hm *HeaderMatch) (*headerMatcher, error) {
+	if hm == nil || hm.Name == "" {
+		return nil, fmt.Errorf("header name is required")
+	}
+	set := 0
+	if hm.Exact != "" {
+		set++
+	}
+	if hm.Regex != "" {
+		set++
+	}
+	if hm.Present {
+		set++
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("header %q: exactly one of exact, regex or present is required", hm.Name)
+	}
+
+	matcher := &headerMatcher{
+		name:    http.CanonicalHeaderKey(hm.Name),
+		exact:   hm.Exact,
+		present: hm.Present,
+		invert:  hm.Invert,
+	}
+	if hm.Regex != "" {
+		re, err := regexp.Compile(hm.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: invalid regex: %v", hm.Name, err)
+		}
+		matcher.regex = re
+	}
+	return matcher, nil
+}
+
+// cluster looks up a cluster by name.
+func (t *RouteTable) cluster(name string) (*cluster, bool) {
+	c, ok := t.clusters[name]
+	return c, ok
+}
+
+// match returns the first route matching the request, or nil.
+func (t *RouteTable) match(req *http.Request) *route {
+	for _, r := range t.routes {
+		if r.matches(req) {
+			return r
+		}
+	}
+	return nil
+}
+
+// resolve returns the matched route, if any, and the upstream cluster of the request.
+// Requests matching no route go to the local application service.
+func (t *RouteTable) resolve(req *http.Request) (*route, *cluster) {
+	r := t.match(req)
+	clusterName := defaultClusterName
+	if r != nil {
+		clusterName = r.pickCluster()
+	}
+	return r, t.clusters[clusterName]
+}
+
+func (m *requestMatcher) matches(req *http.Request) bool {
+	if m.host != "" && !matchHost(m.host, req.Host) {
+		return false
+	}
+	if m.pathPrefix != "" && !strings.HasPrefix(req.URL.Path, m.pathPrefix) {
+		return false
+	}
+	if m.pathRegex != nil && !m.pathRegex.MatchString(req.URL.Path) {
+		return false
+	}
+	if m.methods != nil && !m.methods[req.Method] {
+		return false
+	}
+	for _, h := range m.headers {
+		if !h.matches(req.Header) {
+			return false
+		}
+	}
+	if m.grpcService != "" || m.grpcMethod != "" {
+		service, method, ok := grpcMethodOf(req)
+		if !ok || (m.grpcService != "" && m.grpcService != service) || (m.grpcMethod != "" && m.grpcMethod != method) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *headerMatcher) matches(header http.Header) bool {
+	values, present := header[h.name]
+	var matched bool
+	switch {
+	case h.present:
+		matched = present
+	case h.regex != nil:
+		matched = present && h.regex.MatchString(values[0])
+	default:
+		matched = present && values[0] == h.exact
+	}
+	return matched != h.invert
+}
+
+// inherit carries the endpoint states of the unchanged clusters over from the
+// previous route table.
+func (t *RouteTable) inherit(old *RouteTable) {
+	if old == nil {
+		return
+	}
+	for name, c := range t.clusters {
+		c.inherit(old.clusters[name])
+	}
+}
+
+// start starts the health checks of the clusters.
+func (t *RouteTable) start() {
+	for _, c := range t.clusters {
+		c.startHealthChecks()
+	}
+}
+
+// stop stops the health checks of the clusters.
+func (t *RouteTable) stop() {
+	for _, c := range t.clusters {
+		c.stopHealthChecks()
+	}
+}
+
+// matchHost matches the request host, without its port, against an exact or a
+// wildcard ("*.example.com") host.
+func matchHost(pattern string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
+
+// pickCluster picks one of the route clusters randomly according to their weights.
+func (r *route) pickCluster() string {
+	if len(r.clusters) == 1 {
+		return r.clusters[0].Cluster
+	}
+	n := rand.Intn(r.totalWeight)
+	for _, wc := range r.clusters {
+		if n < wc.Weight {
+			return wc.Cluster
+		}
+		n -= wc.Weight
+	}
+	return r.clusters[len(r.clusters)-1].Cluster
+}
@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewRouteTableRejectsLocalCluster(t *testing.T) {
+	_, err := NewRouteTable(&Config{
+		Clusters: []*ClusterConfig{{Name: defaultClusterName, Address: "127.0.0.1:8080"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Fatalf("expected the local cluster to be rejected, got %v", err)
+	}
+}
+
+func TestRouteTableWithoutConfig(t *testing.T) {
+	p := &Proxy{}
+	table := p.routeTable()
+	if _, ok := table.cluster(defaultClusterName); !ok {
+		t.Fatalf("expected the local cluster in the default route table")
+	}
+	if p.routeTable() != table {
+		t.Fatalf("expected the default route table to be kept")
+	}
+}
@@ -1,39 +1,254 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/dapr/components-contrib/secretstores"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"group.rxcloud/capa/pkg/accesslog"
 )
 
 const (
 	proxyPort   = 8000
+	adminPort   = 8001
 	servicePort = 80
 )
 
 // Proxy Create a structure to define the proxy functionality.
-type Proxy struct{}
+type Proxy struct {
+	// routes holds the active *RouteTable. It is swapped as a whole on config updates.
+	routes atomic.Value
+
+	// faults holds the faults injected for chaos testing.
+	faults faultInjector
+
+	// mirroring counts the in-flight shadow requests.
+	mirroring int32
+
+	// bypass switches the proxy to passthrough when it is unhealthy.
+	bypass bypassSwitch
+
+	// tcp proxies the non-HTTP connections as plain TCP.
+	tcp tcpProxy
+
+	// capture holds the active *capturer, recording or replaying the requests.
+	capture atomic.Value
+
+	// authn holds the active *authenticator, validating the JWTs of the requests.
+	authn atomic.Value
+
+	secretLock   sync.RWMutex
+	secretStores map[string]secretstores.SecretStore
+
+	// node holds the *Node of the proxy.
+	node atomic.Value
+	// accessLog holds the active *accesslog.Logger, nil when the access log is disabled.
+	accessLog atomic.Value
+
+	// servers are the proxy and admin servers, and cancel stops the bypass monitor, once started.
+	servers []*http.Server
+	cancel  context.CancelFunc
+	// serving is 1 while the proxy server accepts connections.
+	serving int32
+}
 
-func (p *Proxy) forwardRequest(req *http.Request) (*http.Response, time.Duration, error) {
+// NewProxy returns a new proxy with the given config.
+func NewProxy(cfg *Config) (*Proxy, error) {
+	p := &Proxy{}
+	if err := p.ApplyConfig(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ApplyConfig validates the config and replaces the active routes with it.
+// An invalid config is rejected as a whole and the active routes are kept. The
+// clusters whose endpoints are unchanged keep their health and ejection states.
+func (p *Proxy) ApplyConfig(cfg *Config) error {
+	table, err := NewRouteTable(cfg)
+	if err != nil {
+		return err
+	}
+	if err := validateBypass(cfg.Bypass); err != nil {
+		return err
+	}
+	if err := validateTCP(cfg.TCP); err != nil {
+		return err
+	}
+	// Keep the capture when its config is unchanged, as a new one would start over.
+	capture, _ := p.capture.Load().(*capturer)
+	reuseCapture := capture.reusable(cfg.Capture)
+	if !reuseCapture {
+		var err error
+		if capture, err = newCapturer(cfg.Capture); err != nil {
+			return err
+		}
+	}
+	stopCapture := func() {
+		if !reuseCapture {
+			capture.stop()
+		}
+	}
+	accessLog, err := accesslog.New("", cfg.AccessLog)
+	if err != nil {
+		stopCapture()
+		return err
+	}
+	authn, err := p.newAuthenticator(cfg.JWT)
+	if err != nil {
+		stopCapture()
+		accessLog.Close()
+		return err
+	}
+	if old, ok := p.routes.Load().(*RouteTable); ok {
+		table.inherit(old)
+	}
+	table.start()
+	if old, ok := p.routes.Swap(table).(*RouteTable); ok {
+		old.stop()
+	}
+	p.bypass.setConfig(cfg.Bypass)
+	p.tcp.setConfig(cfg.TCP)
+	if old, ok := p.capture.Swap(capture).(*capturer); ok && old != capture {
+		old.stop()
+	}
+	if old, ok := p.authn.Swap(authn).(*authenticator); ok {
+		old.stop()
+	}
+	if old, ok := p.accessLog.Swap(accessLog).(*accesslog.Logger); ok {
+		old.Close()
+	}
+	log.Infof("[Capa.proxy] applied %d routes and %d clusters", len(cfg.Routes), len(cfg.Clusters))
+	return nil
+}
+
+// UpdateConfig applies the json proxy config pushed by the configuration component.
+func (p *Proxy) UpdateConfig(data json.RawMessage) error {
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return err
+	}
+	if err := p.ApplyConfig(cfg); err != nil {
+		log.Warnf("[Capa.proxy] rejected proxy config: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *Proxy) routeTable() *RouteTable {
+	if table, ok := p.routes.Load().(*RouteTable); ok {
+		return table
+	}
+	// A proxy without config routes everything to the local service.
+	table, _ := NewRouteTable(&Config{})
+	p.routes.CompareAndSwap(nil, table)
+	return p.routes.Load().(*RouteTable)
+}
+
+// Start serves the intercepted connections on the proxy port, proxying the non-HTTP
+// ones as plain TCP and accepting HTTP/2 without TLS (h2c) for gRPC. It serves the
+// admin API on the loopback admin port, and switches to bypass mode when the proxy
+// is unhealthy.
+func (p *Proxy) Start() error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", proxyPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on proxy port %d: %v", proxyPort, err)
+	}
+	adminListener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", adminPort))
+	if err != nil {
+		l.Close()
+		return fmt.Errorf("failed to listen on proxy admin port %d: %v", adminPort, err)
+	}
+
+	server := &http.Server{Handler: h2c.NewHandler(p, &http2.Server{}), ConnContext: withOriginalDst}
+	admin := &http.Server{Handler: p.AdminHandler()}
+	p.servers = []*http.Server{server, admin}
+	atomic.StoreInt32(&p.serving, 1)
+	go func() {
+		serve(server, p.Listen(l))
+		atomic.StoreInt32(&p.serving, 0)
+	}()
+	go serve(admin, adminListener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.MonitorBypass(ctx)
+	log.Infof("[Capa.proxy] proxy listening on port %d, admin on port %d", proxyPort, adminPort)
+	return nil
+}
+
+func serve(server *http.Server, l net.Listener) {
+	if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+		log.Errorf("[Capa.proxy] server on %s stopped: %v", l.Addr(), err)
+	}
+}
+
+// Stop stops the servers once their outstanding requests are done, or once the
+// duration elapses, then releases the routes, the capture and the access log.
+func (p *Proxy) Stop(duration time.Duration) {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	for _, server := range p.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warnf("[Capa.proxy] outstanding requests not finished after %s", duration)
+			server.Close()
+		}
+	}
+	if table, ok := p.routes.Load().(*RouteTable); ok {
+		table.stop()
+	}
+	if c, ok := p.capture.Load().(*capturer); ok {
+		c.stop()
+	}
+	if a, ok := p.authn.Load().(*authenticator); ok {
+		a.stop()
+	}
+	if l, ok := p.accessLog.Load().(*accesslog.Logger); ok {
+		l.Close()
+	}
+}
+
+func (p *Proxy) send(ctx context.Context, req *http.Request, upstream *cluster) (*http.Response, error) {
 	// Prepare the destination endpoint to forward the request to.
-	proxyUrl := fmt.Sprintf("http://127.0.0.1:%d%s", servicePort, req.RequestURI)
+	ep := upstream.pick(req)
+	proxyUrl := fmt.Sprintf("http://%s%s", ep.address, req.RequestURI)
 
-	// Print the original URL and the proxied request URL.
-	fmt.Printf("Original URL: http://%s:%d%s\n", req.Host, servicePort, req.RequestURI)
-	fmt.Printf("Proxy URL: %s\n", proxyUrl)
+	log.Debugf("[Capa.proxy] forward http://%s%s to %s", req.Host, req.RequestURI, proxyUrl)
 
 	// Create an HTTP client and a proxy request based on the original request.
 	httpClient := http.Client{}
-	proxyReq, err := http.NewRequest(req.Method, proxyUrl, req.Body)
+	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, proxyUrl, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	proxyReq.Header = req.Header.Clone()
+	proxyReq.Host = req.Host
+	proxyReq.ContentLength = req.ContentLength
 
-	// Capture the duration while making a request to the destination service.
-	start := time.Now()
+	// Track the active requests and the failures of the endpoint for load balancing.
+	atomic.AddInt64(&ep.activeRequests, 1)
 	res, err := httpClient.Do(proxyReq)
-	duration := time.Since(start)
-
-	// Return the response, the request duration, and the error.
-	return res, duration, err
+	upstream.report(ep, err != nil || res.StatusCode >= http.StatusInternalServerError)
+	if err != nil {
+		atomic.AddInt64(&ep.activeRequests, -1)
+		return nil, err
+	}
+	res.Body = &endpointBody{ReadCloser: res.Body, endpoint: ep}
+	return res, nil
 }
 
 func (p *Proxy) writeResponse(w http.ResponseWriter, res *http.Response) {
@@ -55,32 +270,108 @@ func (p *Proxy) writeResponse(w http.ResponseWriter, res *http.Response) {
 	res.Body.Close()
 }
 
-func (p *Proxy) printStats(req *http.Request, res *http.Response, duration time.Duration) {
-	fmt.Printf("Request Duration: %v\n", duration)
-	fmt.Printf("Request Size: %d\n", req.ContentLength)
-	fmt.Printf("Response Size: %d\n", res.ContentLength)
-	fmt.Printf("Response Status: %d\n\n", res.StatusCode)
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Serve the request, counting its bytes for the access log.
+	entry := newAccessEntry(req)
+	aw := &accessWriter{ResponseWriter: w}
+	var body *countingBody
+	if req.Body != nil && req.Body != http.NoBody {
+		body = &countingBody{ReadCloser: req.Body}
+		req.Body = body
+	}
+	p.serve(aw, req, entry)
+	p.logAccess(entry, aw, body)
 }
 
-func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+func (p *Proxy) serve(w http.ResponseWriter, req *http.Request, entry *accesslog.Entry) {
+
+	// Reject the requests without a valid JWT, and forward the claims of the valid ones.
+	if p.authenticate(w, req) {
+		return
+	}
+
+	// Tunnel the upgraded connections, e.g. WebSocket, as they are no request/response.
+	if isUpgrade(req) {
+		r, upstream := p.routeTable().resolve(req)
+		if p.bypass.isActive() {
+			r, upstream = nil, p.routeTable().clusters[defaultClusterName]
+		}
+		entry.Route, entry.Upstream = r.nameOrEmpty(), upstream.name
+		p.serveUpgrade(w, req, r, upstream)
+		return
+	}
+
+	// Proxy gRPC over HTTP/2 end to end, to keep its streaming and trailers.
+	if isGRPC(req) {
+		bypassed := p.bypass.isActive()
+		r, upstream := p.routeTable().resolve(req)
+		if bypassed {
+			r, upstream = nil, p.routeTable().clusters[defaultClusterName]
+		}
+		entry.Route, entry.Upstream = r.nameOrEmpty(), upstream.name
+		if !bypassed && p.injectFault(w, req) {
+			return
+		}
+		status, err := p.serveGRPC(w, req, r, upstream)
+		entry.Status = int(status)
+		if !bypassed {
+			p.bypass.record(err != nil)
+		}
+		return
+	}
+
+	// Pass the request through as is while the proxy is in bypass mode.
+	if p.bypass.isActive() {
+		entry.Upstream = defaultClusterName
+		p.passthrough(w, req)
+		return
+	}
+
+	// Pick the route and the upstream cluster of the request.
+	r, upstream := p.routeTable().resolve(req)
+	entry.Route, entry.Upstream = r.nameOrEmpty(), upstream.name
+
+	// Inject the active faults, which may answer the request right away.
+	if p.injectFault(w, req) {
+		return
+	}
+
+	// Answer the request from the capture file in replay mode, or record it.
+	var x *exchange
+	if c := p.capturing(req); c != nil {
+		if c.config.Mode == CaptureReplay {
+			c.replay(w, req)
+			return
+		}
+		x = c.start(req)
+	}
+
+	// Copy the request to the shadow upstream of the route.
+	p.mirror(req, r)
+
+	// Bound the request with the route timeout.
+	ctx := req.Context()
+	if r != nil && r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
 	// Forward the HTTP request to the destination service.
-	res, duration, err := p.forwardRequest(req)
+	res, retries, err := p.roundTrip(ctx, req, r, upstream)
+	entry.Retries = retries
 
 	// Notify the client if there was an error while forwarding the request.
+	p.bypass.record(err != nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		http.Error(w, err.Error(), statusOfError(err))
 		return
 	}
 
 	// If the request was forwarded successfully, write the response back to
 	// the client.
+	if x != nil {
+		x.finish(res)
+	}
 	p.writeResponse(w, res)
-
-	// Print request and response statistics.
-	p.printStats(req, res, duration)
-}
-
-func main() {
-	// Listen on the predefined proxy port.
-	http.ListenAndServe(fmt.Sprintf(":%d", proxyPort), &Proxy{})
 }
@@ -0,0 +1,384 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	defaultSniffTimeout   = 200 * time.Millisecond
+	defaultTCPIdleTimeout = time.Hour
+	tcpDialTimeout        = 5 * time.Second
+)
+
+// httpPrefixes are the first bytes of the HTTP/1.x requests and of the HTTP/2 preface.
+var httpPrefixes = []string{
+	"GET ", "HEAD ", "POST ", "PUT ", "DELETE ", "OPTIONS ", "PATCH ", "CONNECT ", "TRACE ",
+	"PRI * HTTP/2.0",
+}
+
+var (
+	tcpConnectionCount   = stats.Int64("capa/proxy/tcp_connection_count", "The number of TCP connections proxied as plain TCP.", stats.UnitDimensionless)
+	tcpActiveConnections = stats.Int64("capa/proxy/tcp_active_connections", "The number of open TCP connections proxied as plain TCP.", stats.UnitDimensionless)
+	tcpConnectErrorCount = stats.Int64("capa/proxy/tcp_connect_error_count", "The number of TCP connections whose original destination couldn't be reached.", stats.UnitDimensionless)
+	tcpReceivedBytes     = stats.Int64("capa/proxy/tcp_received_bytes", "The bytes received from the downstream of the TCP connections.", stats.UnitBytes)
+	tcpSentBytes         = stats.Int64("capa/proxy/tcp_sent_bytes", "The bytes sent to the downstream of the TCP connections.", stats.UnitBytes)
+
+	tcpPortKey      = tag.MustNewKey("destination_port")
+	tcpDetectionKey = tag.MustNewKey("detection")
+)
+
+// The ways a connection is detected as plain TCP, tagged on the connection metric.
+const (
+	tcpDetectionPort  = "port"
+	tcpDetectionSniff = "sniff"
+)
+
+func init() {
+	err := view.Register(
+		&view.View{
+			Name:        tcpConnectionCount.Name(),
+			Description: tcpConnectionCount.Description(),
+			Measure:     tcpConnectionCount,
+			TagKeys:     []tag.Key{tcpPortKey, tcpDetectionKey},
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        tcpActiveConnections.Name(),
+			Description: tcpActiveConnections.Description(),
+			Measure:     tcpActiveConnections,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Name:        tcpConnectErrorCount.Name(),
+			Description: tcpConnectErrorCount.Description(),
+			Measure:     tcpConnectErrorCount,
+			TagKeys:     []tag.Key{tcpPortKey},
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        tcpReceivedBytes.Name(),
+			Description: tcpReceivedBytes.Description(),
+			Measure:     tcpReceivedBytes,
+			TagKeys:     []tag.Key{tcpPortKey},
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        tcpSentBytes.Name(),
+			Description: tcpSentBytes.Description(),
+			Measure:     tcpSentBytes,
+			TagKeys:     []tag.Key{tcpPortKey},
+			Aggregation: view.Sum(),
+		},
+	)
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to register tcp metrics: %v", err)
+	}
+}
+
+// TCPConfig decides which intercepted connections are proxied as plain TCP to their
+// original destination, instead of being parsed as HTTP.
+type TCPConfig struct {
+	// Ports are the original destination ports always proxied as plain TCP, e.g. 3306
+	// for MySQL or 6379 for Redis. Protocols where the server speaks first, like MySQL,
+	// should be listed here, as sniffing waits for the first bytes of the client.
+	Ports []int `json:"ports,omitempty"`
+	// SniffTimeout bounds the wait for the first bytes of the other connections, which
+	// are proxied as plain TCP unless they start like HTTP. Defaults to 200ms.
+	SniffTimeout Duration `json:"sniff_timeout,omitempty"`
+	// IdleTimeout closes the TCP connections idle for that long. Defaults to 1h.
+	IdleTimeout Duration `json:"idle_timeout,omitempty"`
+}
+
+// TCPStats are the counters of the plain TCP connections, as shown on the admin API.
+type TCPStats struct {
+	ActiveConnections int64 `json:"active_connections"`
+	TotalConnections  int64 `json:"total_connections"`
+	ConnectErrors     int64 `json:"connect_errors"`
+	ReceivedBytes     int64 `json:"received_bytes"`
+	SentBytes         int64 `json:"sent_bytes"`
+}
+
+type tcpProxy struct {
+	// config holds the active *TCPConfig, with the defaults applied.
+	config atomic.Value
+	stats  tcpCounters
+}
+
+// tcpCounters are the counters behind TCPStats.
+type tcpCounters struct {
+	activeConnections int64
+	totalConnections  int64
+	connectErrors     int64
+	receivedBytes     int64
+	sentBytes         int64
+}
+
+func validateTCP(tc *TCPConfig) error {
+	if tc == nil {
+		return nil
+	}
+	for _, port := range tc.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("tcp: invalid port %d", port)
+		}
+	}
+	if tc.SniffTimeout < 0 || tc.IdleTimeout < 0 {
+		return fmt.Errorf("tcp: sniff_timeout and idle_timeout must not be negative")
+	}
+	return nil
+}
+
+func (t *tcpProxy) setConfig(tc *TCPConfig) {
+	cfg := TCPConfig{}
+	if tc != nil {
+		cfg = *tc
+	}
+	if cfg.SniffTimeout == 0 {
+		cfg.SniffTimeout = Duration(defaultSniffTimeout)
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = Duration(defaultTCPIdleTimeout)
+	}
+	t.config.Store(&cfg)
+}
+
+func (t *tcpProxy) getConfig() *TCPConfig {
+	if cfg, ok := t.config.Load().(*TCPConfig); ok {
+		return cfg
+	}
+	return &TCPConfig{SniffTimeout: Duration(defaultSniffTimeout), IdleTimeout: Duration(defaultTCPIdleTimeout)}
+}
+
+// TCPStats returns the counters of the plain TCP connections.
+func (p *Proxy) TCPStats() TCPStats {
+	return TCPStats{
+		ActiveConnections: atomic.LoadInt64(&p.tcp.stats.activeConnections),
+		TotalConnections:  atomic.LoadInt64(&p.tcp.stats.totalConnections),
+		ConnectErrors:     atomic.LoadInt64(&p.tcp.stats.connectErrors),
+		ReceivedBytes:     atomic.LoadInt64(&p.tcp.stats.receivedBytes),
+		SentBytes:         atomic.LoadInt64(&p.tcp.stats.sentBytes),
+	}
+}
+
+// Listen wraps the listener of the intercepted connections. The connections detected as
+// HTTP are returned by the wrapper to be served by the proxy, while the other ones are
+// proxied as plain TCP to their original destination.
+func (p *Proxy) Listen(l net.Listener) net.Listener {
+	sl := &sniffListener{
+		Listener: l,
+		proxy:    p,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	go sl.acceptLoop()
+	return sl
+}
+
+// sniffListener detects the protocol of the accepted connections.
+type sniffListener struct {
+	net.Listener
+
+	proxy *Proxy
+	conns chan net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+func (l *sniffListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
+}
+
+func (l *sniffListener) Close() error {
+	err := l.Listener.Close()
+	l.shutdown(net.ErrClosed)
+	return err
+}
+
+func (l *sniffListener) shutdown(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.closed)
+	})
+}
+
+func (l *sniffListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			l.shutdown(err)
+			return
+		}
+		go l.detect(conn)
+	}
+}
+
+// detect proxies the connection as plain TCP when its original destination port is
+// listed, or when its first bytes don't look like HTTP. Otherwise the connection is
+// handed to the HTTP server, its sniffed bytes included.
+func (l *sniffListener) detect(conn net.Conn) {
+	cfg := l.proxy.tcp.getConfig()
+	dst, err := originalDestination(conn)
+	if err != nil {
+		log.Debugf("[Capa.proxy] failed to get the original destination of %s: %v", conn.RemoteAddr(), err)
+		dst = conn.LocalAddr().String()
+	}
+	_, port, _ := net.SplitHostPort(dst)
+
+	reader := bufio.NewReader(conn)
+	detection := tcpDetectionPort
+	if !containsPort(cfg.Ports, port) {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(cfg.SniffTimeout)))
+		isHTTP := sniffHTTP(reader)
+		conn.SetReadDeadline(time.Time{})
+		if isHTTP {
+			// The requests without a route go to the original destination, when redirected.
+			sc := &sniffedConn{Conn: conn, reader: reader}
+			if dst != conn.LocalAddr().String() {
+				sc.dst = dst
+			}
+			select {
+			case l.conns <- sc:
+			case <-l.closed:
+				conn.Close()
+			}
+			return
+		}
+		detection = tcpDetectionSniff
+	}
+
+	if dst == conn.LocalAddr().String() {
+		// The connection was not redirected, so there is nowhere to proxy it to.
+		log.Debugf("[Capa.proxy] closed non-HTTP connection of %s without original destination", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+	l.proxy.serveTCP(conn, buffered(reader), dst, port, detection)
+}
+
+// sniffHTTP reports whether the connection starts like an HTTP request. It reads no
+// more bytes than needed to decide, and returns false when the bytes don't come in time.
+func sniffHTTP(reader *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := reader.Peek(n)
+		if err != nil {
+			return false
+		}
+		partial := false
+		for _, prefix := range httpPrefixes {
+			if strings.HasPrefix(string(b), prefix) {
+				return true
+			}
+			if strings.HasPrefix(prefix, string(b)) {
+				partial = true
+			}
+		}
+		if !partial {
+			return false
+		}
+	}
+}
+
+func containsPort(ports []int, port string) bool {
+	for _, p := range ports {
+		if strconv.Itoa(p) == port {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffedConn is a connection whose first bytes were already read by sniffing.
+type sniffedConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+	// dst is the original destination of the connection, empty when it wasn't redirected.
+	dst string
+}
+
+// originalDstKey is the context key of the original destination of the requests.
+type originalDstKey struct{}
+
+// withOriginalDst adds the original destination of a sniffed connection to its context,
+// for the requests read from the connection.
+func withOriginalDst(ctx context.Context, conn net.Conn) context.Context {
+	if sc, ok := conn.(*sniffedConn); ok && sc.dst != "" {
+		return context.WithValue(ctx, originalDstKey{}, sc.dst)
+	}
+	return ctx
+}
+
+// originalDstOf returns the original destination of the request, empty when unknown.
+func originalDstOf(req *http.Request) string {
+	dst, _ := req.Context().Value(originalDstKey{}).(string)
+	return dst
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// serveTCP pipes the connection, with the bytes already sniffed, to its original destination.
+func (p *Proxy) serveTCP(conn net.Conn, sniffed io.Reader, dst string, port string, detection string) {
+	defer conn.Close()
+	ctx, _ := tag.New(context.Background(), tag.Upsert(tcpPortKey, port))
+
+	upstreamConn, err := net.DialTimeout("tcp", dst, tcpDialTimeout)
+	if err != nil {
+		atomic.AddInt64(&p.tcp.stats.connectErrors, 1)
+		stats.Record(ctx, tcpConnectErrorCount.M(1))
+		log.Warnf("[Capa.proxy] failed to connect to the original destination %s: %v", dst, err)
+		return
+	}
+	defer upstreamConn.Close()
+
+	atomic.AddInt64(&p.tcp.stats.totalConnections, 1)
+	active := atomic.AddInt64(&p.tcp.stats.activeConnections, 1)
+	detectionCtx, _ := tag.New(ctx, tag.Upsert(tcpDetectionKey, detection))
+	stats.Record(detectionCtx, tcpConnectionCount.M(1), tcpActiveConnections.M(active))
+	defer func() {
+		stats.Record(ctx, tcpActiveConnections.M(atomic.AddInt64(&p.tcp.stats.activeConnections, -1)))
+	}()
+
+	idleTimeout := time.Duration(p.tcp.getConfig().IdleTimeout)
+	// The admin counters are updated on every write, the metrics when a way ends.
+	client := &idleConn{Conn: conn, timeout: idleTimeout, written: &p.tcp.stats.sentBytes}
+	backend := &idleConn{Conn: upstreamConn, timeout: idleTimeout, written: &p.tcp.stats.receivedBytes}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		stats.Record(ctx, tcpReceivedBytes.M(pipe(backend, client, sniffed)))
+	}()
+	go func() {
+		defer wg.Done()
+		stats.Record(ctx, tcpSentBytes.M(pipe(client, backend, strings.NewReader(""))))
+	}()
+	wg.Wait()
+}
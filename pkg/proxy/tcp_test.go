@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalClusterPicksOriginalDestination(t *testing.T) {
+	p := &Proxy{}
+	local, _ := p.routeTable().cluster(defaultClusterName)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if ep := local.pick(req); ep.address != "127.0.0.1:80" {
+		t.Fatalf("expected the local service without original destination, got %s", ep.address)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx := withOriginalDst(context.Background(), &sniffedConn{Conn: server, dst: "10.0.0.7:8080"})
+	if ep := local.pick(req.WithContext(ctx)); ep.address != "10.0.0.7:8080" {
+		t.Fatalf("expected the original destination, got %s", ep.address)
+	}
+}
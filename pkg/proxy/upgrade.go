@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultUpgradeIdleTimeout = time.Hour
+	upgradeDialTimeout        = 5 * time.Second
+	// defaultUpgradeTimeout bounds the upgrade handshake with the upstream, unless the
+	// route has a timeout.
+	defaultUpgradeTimeout = 30 * time.Second
+)
+
+// isUpgrade reports whether the request asks for a protocol upgrade, e.g. to WebSocket.
+func isUpgrade(req *http.Request) bool {
+	if req.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade forwards an upgrade request to the upstream. When the upstream switches
+// protocols, both connections are hijacked and the bytes are piped both ways until
+// both sides are closed or the connection is idle for too long.
+func (p *Proxy) serveUpgrade(w http.ResponseWriter, req *http.Request, r *route, upstream *cluster) {
+	idleTimeout := defaultUpgradeIdleTimeout
+	if r != nil && r.idleTimeout > 0 {
+		idleTimeout = r.idleTimeout
+	}
+	timeout := defaultUpgradeTimeout
+	if r != nil && r.timeout > 0 {
+		timeout = r.timeout
+	}
+
+	// Dial the upstream endpoint and send it the original upgrade request.
+	ep := upstream.pick(req)
+	upstreamConn, err := net.DialTimeout("tcp", ep.address, upgradeDialTimeout)
+	if err != nil {
+		upstream.report(ep, true)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstreamConn.Close()
+
+	atomic.AddInt64(&ep.activeRequests, 1)
+	defer atomic.AddInt64(&ep.activeRequests, -1)
+
+	// Bound the handshake, the deadline is cleared once the upstream answered.
+	upstreamConn.SetDeadline(time.Now().Add(timeout))
+	if err := req.Write(upstreamConn); err != nil {
+		upstream.report(ep, true)
+		http.Error(w, err.Error(), upgradeErrorStatus(err))
+		return
+	}
+	upstreamReader := bufio.NewReader(upstreamConn)
+	res, err := http.ReadResponse(upstreamReader, req)
+	if err != nil {
+		upstream.report(ep, true)
+		http.Error(w, err.Error(), upgradeErrorStatus(err))
+		return
+	}
+	upstreamConn.SetDeadline(time.Time{})
+	upstream.report(ep, res.StatusCode >= http.StatusInternalServerError)
+
+	// The upstream refused to switch protocols, answer as a plain response.
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		p.writeResponse(w, res)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be upgraded", http.StatusInternalServerError)
+		return
+	}
+	clientConn, clientRW, err := hijacker.Hijack()
+	if err != nil {
+		log.Warnf("[Capa.proxy] failed to hijack the upgraded connection: %v", err)
+		return
+	}
+	defer clientConn.Close()
+
+	// Answer the client with the switching protocols response of the upstream.
+	if err := res.Write(clientRW); err != nil || clientRW.Flush() != nil {
+		return
+	}
+
+	// Pipe the bytes both ways, including the bytes already buffered on both sides.
+	client := &idleConn{Conn: clientConn, timeout: idleTimeout}
+	backend := &idleConn{Conn: upstreamConn, timeout: idleTimeout}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pipe(backend, client, buffered(clientRW.Reader))
+	}()
+	go func() {
+		defer wg.Done()
+		pipe(client, backend, buffered(upstreamReader))
+	}()
+	wg.Wait()
+}
+
+// upgradeErrorStatus maps an error of the upgrade handshake to the status returned to the caller.
+func upgradeErrorStatus(err error) int {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// pipe copies the bytes buffered from src, then src itself, to dst, and returns the
+// number of bytes copied. When src ends, dst is half-closed so the peer sees the end
+// while the other way keeps going. When a side fails or idles out, both are closed
+// so the other way ends too.
+func pipe(dst *idleConn, src *idleConn, buffered io.Reader) int64 {
+	n, err := io.Copy(dst, io.MultiReader(buffered, src))
+	if err != nil {
+		dst.Close()
+		src.Close()
+		return n
+	}
+	if cw, ok := dst.Conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		dst.Close()
+	}
+	return n
+}
+
+// buffered returns the bytes already buffered by the reader, without reading any more.
+func buffered(r *bufio.Reader) io.Reader {
+	b, _ := r.Peek(r.Buffered())
+	return bytes.NewReader(b)
+}
+
+// idleConn is a connection that times out when no byte is read or written for a while.
+type idleConn struct {
+	net.Conn
+
+	timeout time.Duration
+	// written counts the bytes written to the connection, if set.
+	written *int64
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	n, err := c.Conn.Write(b)
+	if c.written != nil {
+		atomic.AddInt64(c.written, int64(n))
+	}
+	return n, err
+}
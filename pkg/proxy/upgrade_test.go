@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// newUpgradeProxy starts an echo WebSocket server and a proxy routing every request to it.
+func newUpgradeProxy(t *testing.T, idleTimeout time.Duration) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/echo" {
+			http.Error(w, "no websocket here", http.StatusForbidden)
+			return
+		}
+		websocket.Handler(func(ws *websocket.Conn) {
+			var msg string
+			for websocket.Message.Receive(ws, &msg) == nil {
+				websocket.Message.Send(ws, msg)
+			}
+		}).ServeHTTP(w, req)
+	}))
+	t.Cleanup(echo.Close)
+
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{{Name: "echo", Address: strings.TrimPrefix(echo.URL, "http://")}},
+		Routes: []*RouteConfig{{
+			Name:        "echo",
+			Match:       &RouteMatch{PathPrefix: "/"},
+			Cluster:     "echo",
+			IdleTimeout: Duration(idleTimeout),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	t.Cleanup(front.Close)
+	return echo, front
+}
+
+func dialWebSocket(front *httptest.Server, path string) (*websocket.Conn, error) {
+	return websocket.Dial("ws"+strings.TrimPrefix(front.URL, "http")+path, "", front.URL)
+}
+
+func TestUpgradeEcho(t *testing.T) {
+	_, front := newUpgradeProxy(t, 0)
+
+	ws, err := dialWebSocket(front, "/echo")
+	if err != nil {
+		t.Fatalf("failed to dial through the proxy: %v", err)
+	}
+	defer ws.Close()
+
+	for _, msg := range []string{"hello", "capa", strings.Repeat("x", 64*1024)} {
+		if err := websocket.Message.Send(ws, msg); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		var got string
+		if err := websocket.Message.Receive(ws, &got); err != nil {
+			t.Fatalf("failed to receive: %v", err)
+		}
+		if got != msg {
+			t.Fatalf("expected echo of %d bytes, got %d bytes", len(msg), len(got))
+		}
+	}
+}
+
+func TestUpgradeRefused(t *testing.T) {
+	_, front := newUpgradeProxy(t, 0)
+
+	_, err := dialWebSocket(front, "/refused")
+	if err == nil {
+		t.Fatal("expected the upgrade to be refused")
+	}
+	if !strings.Contains(err.Error(), "bad status") {
+		t.Fatalf("expected a bad status error, got %v", err)
+	}
+}
+
+func TestUpgradeIdleTimeout(t *testing.T) {
+	_, front := newUpgradeProxy(t, 100*time.Millisecond)
+
+	ws, err := dialWebSocket(front, "/echo")
+	if err != nil {
+		t.Fatalf("failed to dial through the proxy: %v", err)
+	}
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got string
+	if err := websocket.Message.Receive(ws, &got); err != io.EOF {
+		t.Fatalf("expected the idle connection to be closed, got %q, %v", got, err)
+	}
+}
+
+func TestUpgradeHandshakeTimeout(t *testing.T) {
+	// The upstream accepts the connection but never answers the upgrade.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	p, err := NewProxy(&Config{
+		Clusters: []*ClusterConfig{{Name: "silent", Address: silent.Addr().String()}},
+		Routes: []*RouteConfig{{
+			Name:    "silent",
+			Match:   &RouteMatch{PathPrefix: "/"},
+			Cluster: "silent",
+			Timeout: Duration(100 * time.Millisecond),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, front.URL+"/echo", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("expected the proxy to answer, got %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected %d, got %d", http.StatusGatewayTimeout, res.StatusCode)
+	}
+}
@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+// ConfigKey is the key of the pubsub config in CapaRuntimeConfig.Extends.
+const ConfigKey = "pubsub"
+
+// Config declares the pubsub components of the runtime, and the declarative
+// subscriptions of the app.
+type Config struct {
+	Components    []*Component          `json:"components,omitempty"`
+	Subscriptions []*SubscriptionConfig `json:"subscriptions,omitempty"`
+}
+
+// Component is a pubsub component.
+type Component = components.Component
+
+// SubscriptionConfig subscribes the app to a topic of a pubsub component. The app can
+// also subscribe programmatically, by listing its subscriptions on the callback channel.
+type SubscriptionConfig struct {
+	PubsubName string            `json:"pubsub_name"`
+	Topic      string            `json:"topic"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	// DeadLetterTopic is the topic of the same pubsub receiving the messages that kept
+	// failing. They are dropped when not set.
+	DeadLetterTopic string `json:"dead_letter_topic,omitempty"`
+	// MaxConcurrency is the number of messages delivered at once, 1 by default.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Retry retries the messages, 3 times by default.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// ParseConfig parses the pubsub config from its json form. Empty data yields no pubsubs.
+func ParseConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if err := components.ParseConfig(ConfigKey, data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the config by creating its components, which have no side effects.
+func (c *Config) Validate() error {
+	_, _, err := NewPubSubs(c)
+	return err
+}
+
+// NewPubSubs creates and inits the pubsubs of the config, by name, and its subscriptions.
+// The pubsubs are not opened.
+func NewPubSubs(c *Config) (map[string]PubSub, []*Subscription, error) {
+	if err := components.Validate(ConfigKey, c.Components); err != nil {
+		return nil, nil, err
+	}
+	pubSubs := map[string]PubSub{}
+	for _, component := range c.Components {
+		ps, err := NewPubSub(component.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("pubsub: component %q: %v", component.Name, err)
+		}
+		if err := ps.Init(component.InitMetadata()); err != nil {
+			return nil, nil, fmt.Errorf("pubsub: component %q: %v", component.Name, err)
+		}
+		pubSubs[component.Name] = ps
+	}
+
+	var subscriptions []*Subscription
+	topics := map[string]bool{}
+	for i, sc := range c.Subscriptions {
+		sub, err := NewSubscription(sc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("pubsub: subscription %d: %v", i, err)
+		}
+		if _, ok := pubSubs[sub.PubsubName]; !ok {
+			return nil, nil, fmt.Errorf("pubsub: subscription %d: unknown pubsub %q", i, sub.PubsubName)
+		}
+		if topics[sub.Key()] {
+			return nil, nil, fmt.Errorf("pubsub: duplicate subscription of %s", sub.Key())
+		}
+		topics[sub.Key()] = true
+		subscriptions = append(subscriptions, sub)
+	}
+	return pubSubs, subscriptions, nil
+}
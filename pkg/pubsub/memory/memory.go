@@ -0,0 +1,416 @@
+// Package memory is a pubsub broker running in the sidecar. Its topics are queues, each
+// consumed by the single subscription of the app to the topic. The messages are kept in
+// memory, and in a journal file when a path is set, so that they survive a restart.
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/pubsub"
+)
+
+// ComponentType is the type of the in-memory components.
+const ComponentType = "pubsub.in-memory"
+
+const (
+	// pathKey is the metadata of the journal file, the messages are not persisted when not set.
+	pathKey = "path"
+	// redeliveryIntervalKey is the metadata of the delay before a failed message is
+	// delivered again, like "5s".
+	redeliveryIntervalKey = "redeliveryInterval"
+
+	defaultRedeliveryInterval = time.Second
+
+	// compactAcks is the number of acknowledgements journaled after which the journal
+	// is compacted, once they outnumber the messages left.
+	compactAcks = 1000
+)
+
+// The operations of the journal.
+const (
+	opPublish = "publish"
+	opAck     = "ack"
+)
+
+// record is a line of the journal, and a message of a topic.
+type record struct {
+	Op          string            `json:"op"`
+	Seq         uint64            `json:"seq"`
+	Topic       string            `json:"topic,omitempty"`
+	Data        []byte            `json:"data,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type topic struct {
+	// pending are the messages waiting for a delivery, in order.
+	pending    []*record
+	subscribed bool
+	// notify wakes up the subscription when a message is pending.
+	notify chan struct{}
+}
+
+// Broker is the in-memory broker. The published messages are synced to the journal
+// before Publish returns. The acknowledgements are not, a message acknowledged right
+// before a crash is delivered again.
+type Broker struct {
+	path               string
+	redeliveryInterval time.Duration
+
+	lock   sync.Mutex
+	topics map[string]*topic
+	// unacked are the messages not acknowledged yet, by sequence number.
+	unacked map[uint64]*record
+	seq     uint64
+	file    *os.File
+	// acks is the number of acknowledgements journaled since the last compaction.
+	acks   int
+	closed bool
+}
+
+// NewInMemory returns a new in-memory pubsub.
+func NewInMemory() pubsub.PubSub {
+	return &Broker{
+		topics:             map[string]*topic{},
+		unacked:            map[uint64]*record{},
+		redeliveryInterval: defaultRedeliveryInterval,
+	}
+}
+
+func (b *Broker) Init(metadata pubsub.Metadata) error {
+	if path := metadata.Properties[pathKey]; path != "" {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		b.path = path
+	}
+	if value := metadata.Properties[redeliveryIntervalKey]; value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", redeliveryIntervalKey, value, err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("%s must be positive", redeliveryIntervalKey)
+		}
+		b.redeliveryInterval = interval
+	}
+	return nil
+}
+
+// Open replays the journal, the messages not acknowledged are pending again.
+func (b *Broker) Open() error {
+	if b.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.replay(); err != nil {
+		return err
+	}
+	for _, r := range b.unackedRecords() {
+		t := b.topic(r.Topic)
+		t.pending = append(t.pending, r)
+	}
+	return b.compact()
+}
+
+// replay restores the messages of the journal. A torn last line, from a crash in the
+// middle of a write, is ignored. The lock must be held.
+func (b *Broker) replay() error {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		r := &record{}
+		if err := json.Unmarshal(line, r); err != nil {
+			if i == len(lines)-1 {
+				log.Warnf("[Capa.pubsub] ignoring the torn last record of %s", b.path)
+				break
+			}
+			return fmt.Errorf("corrupted journal %s at line %d: %v", b.path, i+1, err)
+		}
+		switch r.Op {
+		case opPublish:
+			b.unacked[r.Seq] = r
+			if r.Seq > b.seq {
+				b.seq = r.Seq
+			}
+		case opAck:
+			delete(b.unacked, r.Seq)
+		}
+	}
+	return nil
+}
+
+// unackedRecords returns the messages not acknowledged, in publish order. The lock must be held.
+func (b *Broker) unackedRecords() []*record {
+	records := make([]*record, 0, len(b.unacked))
+	for _, r := range b.unacked {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	return records
+}
+
+// compact replaces the journal with the messages not acknowledged, and opens it for
+// appending. The lock must be held.
+func (b *Broker) compact() error {
+	tmp := b.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriter(file)
+	for _, r := range b.unackedRecords() {
+		data, err := json.Marshal(r)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return err
+	}
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+	b.file, err = os.OpenFile(b.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	b.acks = 0
+	return nil
+}
+
+// write appends the record to the journal. The lock must be held.
+func (b *Broker) write(r *record, sync bool) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return b.file.Sync()
+	}
+	return nil
+}
+
+// topic returns the topic of the name, created when missing. The lock must be held.
+func (b *Broker) topic(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{notify: make(chan struct{}, 1)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// push adds the message to the pending ones of the topic, first when it is delivered
+// again. The lock must be held.
+func (b *Broker) push(t *topic, r *record, first bool) {
+	if first {
+		t.pending = append([]*record{r}, t.pending...)
+	} else {
+		t.pending = append(t.pending, r)
+	}
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, req *pubsub.PublishRequest) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return pubsub.ErrClosed
+	}
+	r := &record{
+		Op:          opPublish,
+		Seq:         b.seq + 1,
+		Topic:       req.Topic,
+		Data:        req.Data,
+		ContentType: req.ContentType,
+		Metadata:    req.Metadata,
+	}
+	if b.file != nil {
+		if err := b.write(r, true); err != nil {
+			return err
+		}
+	}
+	b.seq = r.Seq
+	b.unacked[r.Seq] = r
+	b.push(b.topic(req.Topic), r, false)
+	return nil
+}
+
+func (b *Broker) Subscribe(ctx context.Context, req *pubsub.SubscribeRequest, handler pubsub.Handler) error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return pubsub.ErrClosed
+	}
+	t := b.topic(req.Topic)
+	if t.subscribed {
+		b.lock.Unlock()
+		return fmt.Errorf("topic %s is already subscribed", req.Topic)
+	}
+	t.subscribed = true
+	b.lock.Unlock()
+	defer func() {
+		b.lock.Lock()
+		t.subscribed = false
+		b.lock.Unlock()
+	}()
+
+	maxConcurrency := req.MaxConcurrency
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	slots := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		r := b.next(ctx, t)
+		if r == nil {
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			msg := &pubsub.NewMessage{
+				ID:          strconv.FormatUint(r.Seq, 10),
+				Topic:       r.Topic,
+				Data:        r.Data,
+				ContentType: r.ContentType,
+				Metadata:    r.Metadata,
+			}
+			if err := handler(ctx, msg); err != nil {
+				b.redeliver(ctx, t, r)
+				return
+			}
+			b.ack(r)
+		}()
+	}
+}
+
+// next waits for a pending message of the topic, nil when the context is done first.
+func (b *Broker) next(ctx context.Context, t *topic) *record {
+	for {
+		b.lock.Lock()
+		if len(t.pending) > 0 {
+			r := t.pending[0]
+			t.pending = t.pending[1:]
+			b.lock.Unlock()
+			return r
+		}
+		b.lock.Unlock()
+		select {
+		case <-t.notify:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// redeliver makes the failed message pending again after the redelivery interval, or
+// right away when the subscription is stopped.
+func (b *Broker) redeliver(ctx context.Context, t *topic, r *record) {
+	if ctx.Err() != nil {
+		b.lock.Lock()
+		b.push(t, r, true)
+		b.lock.Unlock()
+		return
+	}
+	time.AfterFunc(b.redeliveryInterval, func() {
+		b.lock.Lock()
+		b.push(t, r, true)
+		b.lock.Unlock()
+	})
+}
+
+// ack acknowledges the message, which won't be delivered again.
+func (b *Broker) ack(r *record) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, ok := b.unacked[r.Seq]; !ok {
+		return
+	}
+	delete(b.unacked, r.Seq)
+	if b.file == nil {
+		return
+	}
+	if err := b.write(&record{Op: opAck, Seq: r.Seq}, false); err != nil {
+		log.Warnf("[Capa.pubsub] failed to journal the acknowledgement of message %d: %v", r.Seq, err)
+		return
+	}
+	b.acks++
+	if b.acks >= compactAcks && len(b.unacked) < b.acks {
+		if err := b.compact(); err != nil {
+			log.Warnf("[Capa.pubsub] failed to compact %s: %v", b.path, err)
+		}
+	}
+}
+
+func (b *Broker) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
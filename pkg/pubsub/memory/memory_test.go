@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/pkg/pubsub"
+)
+
+func newBroker(t *testing.T, properties map[string]string) *Broker {
+	t.Helper()
+	b := NewInMemory().(*Broker)
+	if err := b.Init(pubsub.Metadata{Properties: properties}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	if err := b.Open(); err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	return b
+}
+
+func publish(t *testing.T, b *Broker, topic string, data ...string) {
+	t.Helper()
+	for _, d := range data {
+		if err := b.Publish(context.Background(), &pubsub.PublishRequest{Topic: topic, Data: []byte(d)}); err != nil {
+			t.Fatalf("failed to publish: %v", err)
+		}
+	}
+}
+
+// receive subscribes to the topic until n messages were handled, and returns their data.
+func receive(t *testing.T, b *Broker, topic string, n int, handler pubsub.Handler) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var lock sync.Mutex
+	var received []string
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Subscribe(ctx, &pubsub.SubscribeRequest{Topic: topic}, func(ctx context.Context, msg *pubsub.NewMessage) error {
+			err := handler(ctx, msg)
+			lock.Lock()
+			defer lock.Unlock()
+			received = append(received, string(msg.Data))
+			if len(received) == n {
+				cancel()
+			}
+			return err
+		})
+	}()
+	if err := <-done; err != nil {
+		t.Fatalf("subscription failed: %v", err)
+	}
+	if len(received) < n {
+		t.Fatalf("expected %d messages, got %v", n, received)
+	}
+	return received
+}
+
+func ack(ctx context.Context, msg *pubsub.NewMessage) error {
+	return nil
+}
+
+func TestDeliverInOrder(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.Close()
+	publish(t, b, "orders", "1", "2", "3")
+	publish(t, b, "other", "x")
+	received := receive(t, b, "orders", 3, ack)
+	if received[0] != "1" || received[1] != "2" || received[2] != "3" {
+		t.Fatalf("expected the messages in publish order, got %v", received)
+	}
+	if len(b.unacked) != 1 {
+		t.Fatalf("expected only the message of the other topic to be unacknowledged, got %d", len(b.unacked))
+	}
+}
+
+func TestRedeliverFailed(t *testing.T) {
+	b := newBroker(t, map[string]string{redeliveryIntervalKey: "10ms"})
+	defer b.Close()
+	publish(t, b, "orders", "1")
+	var ids []string
+	failures := 2
+	received := receive(t, b, "orders", 3, func(ctx context.Context, msg *pubsub.NewMessage) error {
+		ids = append(ids, msg.ID)
+		if failures > 0 {
+			failures--
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if received[2] != "1" || ids[0] != ids[1] || ids[1] != ids[2] {
+		t.Fatalf("expected the same message to be delivered again, got %v with ids %v", received, ids)
+	}
+	if len(b.unacked) != 0 {
+		t.Fatalf("expected the message to be acknowledged, got %d unacknowledged", len(b.unacked))
+	}
+}
+
+func TestSingleSubscriber(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Subscribe(ctx, &pubsub.SubscribeRequest{Topic: "orders"}, ack)
+		close(done)
+	}()
+	for {
+		b.lock.Lock()
+		subscribed := b.topic("orders").subscribed
+		b.lock.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := b.Subscribe(ctx, &pubsub.SubscribeRequest{Topic: "orders"}, ack); err == nil {
+		t.Fatal("expected a second subscription of the topic to be rejected")
+	}
+	cancel()
+	<-done
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.Close()
+	for i := 0; i < 20; i++ {
+		publish(t, b, "orders", "m")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var active, peak, handled int32
+	err := b.Subscribe(ctx, &pubsub.SubscribeRequest{Topic: "orders", MaxConcurrency: 3}, func(ctx context.Context, msg *pubsub.NewMessage) error {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		if atomic.AddInt32(&handled, 1) == 20 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscription failed: %v", err)
+	}
+	if peak != 3 {
+		t.Fatalf("expected 3 messages handled at once, got %d", peak)
+	}
+}
+
+func TestRedeliverAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pubsub", "journal")
+	b := newBroker(t, map[string]string{pathKey: path})
+	publish(t, b, "orders", "1", "2", "3")
+	received := receive(t, b, "orders", 1, ack)
+	if received[0] != "1" {
+		t.Fatalf("expected the first message, got %v", received)
+	}
+	b.Close()
+
+	b = newBroker(t, map[string]string{pathKey: path})
+	var ids []string
+	received = receive(t, b, "orders", 2, func(ctx context.Context, msg *pubsub.NewMessage) error {
+		ids = append(ids, msg.ID)
+		return nil
+	})
+	if received[0] != "2" || received[1] != "3" || ids[0] != "2" || ids[1] != "3" {
+		t.Fatalf("expected the unacknowledged messages with their ids, got %v with ids %v", received, ids)
+	}
+	publish(t, b, "orders", "4")
+	if b.seq != 4 {
+		t.Fatalf("expected the sequence to continue after the restart, got %d", b.seq)
+	}
+	b.Close()
+	if err := b.Publish(context.Background(), &pubsub.PublishRequest{Topic: "orders"}); !errors.Is(err, pubsub.ErrClosed) {
+		t.Fatalf("expected the closed broker to reject the message, got %v", err)
+	}
+}
+
+func TestDeadLetterTopic(t *testing.T) {
+	b := newBroker(t, nil)
+	defer b.Close()
+	sub, err := pubsub.NewSubscription(&pubsub.SubscriptionConfig{
+		PubsubName:      "pubsub",
+		Topic:           "orders",
+		DeadLetterTopic: "dead",
+		Retry:           &pubsub.RetryPolicy{Attempts: 1, Backoff: 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the subscription: %v", err)
+	}
+	publish(t, b, "orders", "poison")
+	ctx, cancel := context.WithCancel(context.Background())
+	go sub.Run(ctx, b, func(ctx context.Context, sub *pubsub.Subscription, msg *pubsub.NewMessage) error {
+		return errors.New("failed")
+	})
+	received := receive(t, b, "dead", 1, ack)
+	cancel()
+	if received[0] != "poison" {
+		t.Fatalf("expected the message in the dead-letter topic, got %v", received)
+	}
+}
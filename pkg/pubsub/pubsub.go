@@ -0,0 +1,68 @@
+// Package pubsub is the publish and subscribe building block: the apps publish messages
+// to the topics of the pubsub components, and the runtime delivers the messages of the
+// topics the app subscribes to over the callback channel.
+package pubsub
+
+import (
+	"context"
+	"errors"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+var (
+	// ErrDropped is returned by a Sender when the app drops a message. The message is not
+	// retried, and goes to the dead-letter topic when configured.
+	ErrDropped = errors.New("message dropped by the app")
+	// ErrClosed is returned by the operations of a closed pubsub.
+	ErrClosed = errors.New("pubsub is closed")
+)
+
+// Metadata is the metadata of a pubsub component, from its config.
+type Metadata = components.Metadata
+
+// PubSub is a message broker component. It delivers each message of a topic at least
+// once, so a message can be delivered again, like after a restart.
+type PubSub interface {
+	// Init applies the metadata of the component. It must not have side effects, so
+	// that the config can be checked by creating its components.
+	Init(metadata Metadata) error
+	// Open opens the broker, like its files, before its first use.
+	Open() error
+	Close() error
+	Publish(ctx context.Context, req *PublishRequest) error
+	// Subscribe calls the handler on the messages of the topic, at most max concurrency
+	// at once, until the context is done. It returns once the handlers it called returned.
+	// The messages whose handler fails are delivered again later.
+	Subscribe(ctx context.Context, req *SubscribeRequest, handler Handler) error
+}
+
+// PublishRequest is a message published to a topic.
+type PublishRequest struct {
+	Topic       string
+	Data        []byte
+	ContentType string
+	Metadata    map[string]string
+}
+
+// SubscribeRequest is the subscription of a topic.
+type SubscribeRequest struct {
+	Topic    string
+	Metadata map[string]string
+	// MaxConcurrency is the number of messages delivered at once, at least 1.
+	MaxConcurrency int
+}
+
+// NewMessage is a message delivered to a subscription. Its id is the same when it is
+// delivered again.
+type NewMessage struct {
+	ID          string
+	Topic       string
+	Data        []byte
+	ContentType string
+	Metadata    map[string]string
+}
+
+// Handler handles a message of a subscription. The message is acknowledged when it
+// returns nil, and delivered again otherwise.
+type Handler func(ctx context.Context, msg *NewMessage) error
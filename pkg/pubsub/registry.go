@@ -0,0 +1,21 @@
+package pubsub
+
+import "fmt"
+
+// pubSubs are the factories of the pubsub components, by component type.
+var pubSubs = map[string]func() PubSub{}
+
+// RegisterPubSub registers the factory of the pubsubs of the component type, like
+// "pubsub.in-memory".
+func RegisterPubSub(componentType string, factory func() PubSub) {
+	pubSubs[componentType] = factory
+}
+
+// NewPubSub returns a new pubsub of the component type.
+func NewPubSub(componentType string) (PubSub, error) {
+	factory, ok := pubSubs[componentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown pubsub type %q", componentType)
+	}
+	return factory(), nil
+}
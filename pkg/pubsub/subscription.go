@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/utils"
+)
+
+const defaultMaxConcurrency = 1
+
+// The metadata added to the messages of a dead-letter topic.
+const (
+	// OriginalTopicKey is the topic the message was published to.
+	OriginalTopicKey = "originalTopic"
+	// DeadLetterReasonKey is the last error of the delivery of the message.
+	DeadLetterReasonKey = "deadLetterReason"
+)
+
+// RetryPolicy retries the delivery of the messages of a subscription, before they go
+// to the dead-letter topic.
+type RetryPolicy = utils.RetryPolicy
+
+// Sender sends a message of the subscription to the app.
+type Sender func(ctx context.Context, sub *Subscription, msg *NewMessage) error
+
+// Subscription is the subscription of the app to a topic, with its delivery options.
+type Subscription struct {
+	PubsubName      string
+	Topic           string
+	Metadata        map[string]string
+	DeadLetterTopic string
+
+	maxConcurrency int
+	retrier        *utils.Retrier
+}
+
+// NewSubscription returns the subscription of the config, with the default delivery
+// options for the ones not set.
+func NewSubscription(c *SubscriptionConfig) (*Subscription, error) {
+	if c.PubsubName == "" {
+		return nil, fmt.Errorf("pubsub_name is required")
+	}
+	if c.Topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	if c.DeadLetterTopic == c.Topic {
+		return nil, fmt.Errorf("the dead-letter topic of %s can't be the topic itself", c.Topic)
+	}
+	sub := &Subscription{
+		PubsubName:      c.PubsubName,
+		Topic:           c.Topic,
+		Metadata:        c.Metadata,
+		DeadLetterTopic: c.DeadLetterTopic,
+		maxConcurrency:  c.MaxConcurrency,
+	}
+	if sub.maxConcurrency < 0 {
+		return nil, fmt.Errorf("negative max_concurrency %d", sub.maxConcurrency)
+	}
+	if sub.maxConcurrency == 0 {
+		sub.maxConcurrency = defaultMaxConcurrency
+	}
+
+	retrier, err := utils.NewRetrier(c.Retry)
+	if err != nil {
+		return nil, err
+	}
+	sub.retrier = retrier
+	return sub, nil
+}
+
+// Key identifies the subscription, the app subscribes once to a topic of a pubsub.
+func (s *Subscription) Key() string {
+	return s.PubsubName + "/" + s.Topic
+}
+
+// Run delivers the messages of the topic with the sender until the context is done.
+func (s *Subscription) Run(ctx context.Context, ps PubSub, send Sender) error {
+	req := &SubscribeRequest{Topic: s.Topic, Metadata: s.Metadata, MaxConcurrency: s.maxConcurrency}
+	return ps.Subscribe(ctx, req, func(ctx context.Context, msg *NewMessage) error {
+		return s.deliver(ctx, ps, send, msg)
+	})
+}
+
+// deliver sends the message, and retries it until the app acknowledges it or the attempts
+// are exhausted. It returns nil, acknowledging the message to the broker, once it needs
+// no other delivery: the message given up is published to the dead-letter topic, or
+// dropped without one. It returns an error, leaving the message to the broker which
+// delivers it again later, when the subscription is stopped or when the dead-letter
+// topic can't take it. The input bindings deliver their events the same way.
+func (s *Subscription) deliver(ctx context.Context, ps PubSub, send Sender, msg *NewMessage) error {
+	attempts, err := s.retrier.Do(ctx,
+		func() error {
+			return send(ctx, s, msg)
+		},
+		func(err error) bool {
+			return !errors.Is(err, ErrDropped)
+		},
+		func(retry int, backoff time.Duration, err error) {
+			log.Warnf("[Capa.pubsub] failed to deliver message %s of %s, retry %d in %s: %v", msg.ID, s.Key(), retry, backoff, err)
+		})
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	err = fmt.Errorf("failed to deliver message %s of %s after %d attempts: %w", msg.ID, s.Key(), attempts, err)
+	if s.DeadLetterTopic == "" {
+		log.Errorf("[Capa.pubsub] %v, the message is dropped", err)
+		return nil
+	}
+	metadata := make(map[string]string, len(msg.Metadata)+2)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[OriginalTopicKey] = msg.Topic
+	metadata[DeadLetterReasonKey] = err.Error()
+	dlErr := ps.Publish(ctx, &PublishRequest{
+		Topic:       s.DeadLetterTopic,
+		Data:        msg.Data,
+		ContentType: msg.ContentType,
+		Metadata:    metadata,
+	})
+	if dlErr != nil {
+		log.Errorf("[Capa.pubsub] %v, and failed to publish it to the dead-letter topic %s: %v", err, s.DeadLetterTopic, dlErr)
+		return dlErr
+	}
+	log.Errorf("[Capa.pubsub] %v, the message is published to the dead-letter topic %s", err, s.DeadLetterTopic)
+	return nil
+}
@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/utils"
+)
+
+// publisher is a pubsub recording the published messages.
+type publisher struct {
+	PubSub
+	published []*PublishRequest
+	err       error
+}
+
+func (p *publisher) Publish(ctx context.Context, req *PublishRequest) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, req)
+	return nil
+}
+
+func newTestSubscription(t *testing.T, deadLetterTopic string, attempts int) *Subscription {
+	t.Helper()
+	sub, err := NewSubscription(&SubscriptionConfig{
+		PubsubName:      "pubsub",
+		Topic:           "orders",
+		DeadLetterTopic: deadLetterTopic,
+		Retry:           &RetryPolicy{Attempts: attempts, Backoff: utils.Duration(time.Millisecond)},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the subscription: %v", err)
+	}
+	return sub
+}
+
+// failing returns a sender failing with the errors in turn, then succeeding, and counting its calls.
+func failing(calls *int, errs ...error) Sender {
+	return func(ctx context.Context, sub *Subscription, msg *NewMessage) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+var testMessage = &NewMessage{ID: "1", Topic: "orders", Data: []byte("data"), Metadata: map[string]string{"k": "v"}}
+
+func TestDeliverRetries(t *testing.T) {
+	sub := newTestSubscription(t, "dead", 3)
+	ps := &publisher{}
+	calls := 0
+	failure := errors.New("app is failing")
+	if err := sub.deliver(context.Background(), ps, failing(&calls, failure, failure), testMessage); err != nil {
+		t.Fatalf("expected the retried message to be delivered: %v", err)
+	}
+	if calls != 3 || len(ps.published) != 0 {
+		t.Fatalf("expected 3 calls and no dead letter, got %d calls and %d dead letters", calls, len(ps.published))
+	}
+}
+
+func TestDeliverDeadLetter(t *testing.T) {
+	sub := newTestSubscription(t, "dead", 2)
+	ps := &publisher{}
+	calls := 0
+	failure := errors.New("app is failing")
+	if err := sub.deliver(context.Background(), ps, failing(&calls, failure, failure, failure), testMessage); err != nil {
+		t.Fatalf("expected the message to be dead-lettered: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected the first try and 2 retries, got %d calls", calls)
+	}
+	if len(ps.published) != 1 {
+		t.Fatalf("expected a dead letter, got %d", len(ps.published))
+	}
+	dl := ps.published[0]
+	if dl.Topic != "dead" || string(dl.Data) != "data" || dl.Metadata["k"] != "v" || dl.Metadata[OriginalTopicKey] != "orders" ||
+		!strings.Contains(dl.Metadata[DeadLetterReasonKey], "app is failing") {
+		t.Fatalf("unexpected dead letter %+v", dl)
+	}
+	if _, ok := testMessage.Metadata[OriginalTopicKey]; ok {
+		t.Fatal("expected the metadata of the message to be left unchanged")
+	}
+
+	// The broker keeps the message when the dead-letter topic can't take it.
+	ps.err = errors.New("broker is down")
+	calls = 0
+	if err := sub.deliver(context.Background(), ps, failing(&calls, failure, failure, failure), testMessage); err == nil {
+		t.Fatal("expected the failed dead letter to be returned to the broker")
+	}
+}
+
+func TestDeliverDrop(t *testing.T) {
+	ps := &publisher{}
+	calls := 0
+	sub := newTestSubscription(t, "dead", 3)
+	if err := sub.deliver(context.Background(), ps, failing(&calls, ErrDropped), testMessage); err != nil {
+		t.Fatalf("expected the dropped message to be dead-lettered: %v", err)
+	}
+	if calls != 1 || len(ps.published) != 1 {
+		t.Fatalf("expected no retry of a dropped message, got %d calls and %d dead letters", calls, len(ps.published))
+	}
+
+	// Without a dead-letter topic, the message is acknowledged and lost.
+	calls = 0
+	sub = newTestSubscription(t, "", 3)
+	if err := sub.deliver(context.Background(), ps, failing(&calls, ErrDropped), testMessage); err != nil || calls != 1 {
+		t.Fatalf("expected the dropped message to be acknowledged after 1 call, got %v after %d", err, calls)
+	}
+}
+
+func TestDeliverStopped(t *testing.T) {
+	sub := newTestSubscription(t, "dead", 3)
+	ps := &publisher{}
+	ctx, cancel := context.WithCancel(context.Background())
+	send := func(ctx context.Context, sub *Subscription, msg *NewMessage) error {
+		cancel()
+		return errors.New("app is stopping")
+	}
+	if err := sub.deliver(ctx, ps, send, testMessage); err == nil || len(ps.published) != 0 {
+		t.Fatalf("expected the message to be left to the broker, got %v and %d dead letters", err, len(ps.published))
+	}
+}
+
+func TestSubscriptionDefaults(t *testing.T) {
+	sub, err := NewSubscription(&SubscriptionConfig{PubsubName: "pubsub", Topic: "orders"})
+	if err != nil {
+		t.Fatalf("failed to create the subscription: %v", err)
+	}
+	if sub.retrier == nil || sub.maxConcurrency != defaultMaxConcurrency {
+		t.Fatalf("unexpected defaults %+v", sub)
+	}
+}
+
+func TestNewSubscriptionErrors(t *testing.T) {
+	for _, c := range []*SubscriptionConfig{
+		{Topic: "orders"},
+		{PubsubName: "pubsub"},
+		{PubsubName: "pubsub", Topic: "orders", DeadLetterTopic: "orders"},
+		{PubsubName: "pubsub", Topic: "orders", MaxConcurrency: -1},
+		{PubsubName: "pubsub", Topic: "orders", Retry: &RetryPolicy{Attempts: -1}},
+		{PubsubName: "pubsub", Topic: "orders", Retry: &RetryPolicy{Backoff: utils.Duration(time.Second), MaxBackoff: utils.Duration(time.Millisecond)}},
+	} {
+		if _, err := NewSubscription(c); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+}
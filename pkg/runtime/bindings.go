@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/bindings"
+	"group.rxcloud/capa/pkg/bindings/cron"
+	"group.rxcloud/capa/pkg/bindings/exec"
+	"group.rxcloud/capa/pkg/bindings/filedrop"
+	"group.rxcloud/capa/pkg/bindings/http"
+	"group.rxcloud/capa/pkg/bindings/localfs"
+	"group.rxcloud/capa/pkg/bindings/webhook"
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+// The built-in binding components work offline.
+func init() {
+	bindings.RegisterOutputBinding(localfs.ComponentType, localfs.NewLocalFS)
+	bindings.RegisterOutputBinding(http.ComponentType, http.NewHTTP)
+	bindings.RegisterOutputBinding(exec.ComponentType, exec.NewExec)
+	bindings.RegisterInputBinding(cron.ComponentType, cron.NewCron)
+	bindings.RegisterInputBinding(filedrop.ComponentType, filedrop.NewFileDrop)
+	bindings.RegisterInputBinding(webhook.ComponentType, webhook.NewWebhook)
+}
+
+func (a *CapaRuntime) initBindings() error {
+	cfg, err := bindings.ParseConfig(a.runtimeConfig.Extends[bindings.ConfigKey])
+	if err != nil {
+		return err
+	}
+	a.outputBindings, a.inputBindings, err = bindings.NewBindings(cfg)
+	if err != nil {
+		return err
+	}
+	for _, component := range cfg.Components {
+		kind := "output"
+		if bindings.IsInputBinding(component.Type) {
+			kind = "input"
+		}
+		log.Infof("[Capa.runtime.args] %s binding: %s, type: %s", kind, component.Name, component.Type)
+	}
+	if len(a.inputBindings) > 0 {
+		return a.initAppChannel()
+	}
+	return nil
+}
+
+func (a *CapaRuntime) sendToOutputBinding(ctx context.Context, name string, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	return a.outputBindings.Invoke(ctx, name, req)
+}
+
+// startInputBindings starts delivering the events of the input bindings to the app,
+// until the runtime is shut down.
+func (a *CapaRuntime) startInputBindings() {
+	for _, input := range a.inputBindings {
+		a.inputBindingsWG.Add(1)
+		go func(input *bindings.Input) {
+			defer a.inputBindingsWG.Done()
+			if err := input.Read(a.ctx, a.sendBindingEventToApp); err != nil {
+				log.Errorf("[Capa.runtime] input binding %s stopped: %v", input.Name, err)
+			}
+		}(input)
+	}
+}
+
+// sendBindingEventToApp delivers an event to the app. The app acknowledges it, or asks
+// to retry it, or drops it.
+func (a *CapaRuntime) sendBindingEventToApp(ctx context.Context, name string, event *bindings.ReadResponse) error {
+	res, err := a.appCallback.OnBindingEvent(ctx, &runtimev1pb.BindingEventRequest{
+		Name:     name,
+		Data:     event.Data,
+		Metadata: event.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+	switch res.Status {
+	case runtimev1pb.BindingEventResponse_SUCCESS:
+		return nil
+	case runtimev1pb.BindingEventResponse_DROP:
+		return bindings.ErrDropped
+	default:
+		return fmt.Errorf("the app answered %s", res.Status)
+	}
+}
+
+// stopInputBindings waits, at most the duration, for the input bindings to stop once the
+// runtime context is canceled.
+func (a *CapaRuntime) stopInputBindings(duration time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		a.inputBindingsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(duration):
+		log.Warnf("input bindings not stopped after %s", duration)
+	}
+}
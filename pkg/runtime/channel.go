@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"net"
+	"strconv"
+
+	grpc_go "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+)
+
+// appHost is the host of the app, which runs next to the sidecar.
+const appHost = "127.0.0.1"
+
+// initAppChannel connects to the callback API served by the app on the runtime callback
+// port. The connection is made in the background, the app may start after the sidecar.
+func (a *CapaRuntime) initAppChannel() error {
+	if a.appConn != nil {
+		return nil
+	}
+	address := net.JoinHostPort(appHost, strconv.Itoa(a.runtimeConfig.SidecarManagement.RuntimeCallbackPort))
+	conn, err := grpc_go.Dial(address, grpc_go.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	a.appConn = conn
+	a.appCallback = runtimev1pb.NewAppCallbackClient(conn)
+	return nil
+}
+
+func (a *CapaRuntime) closeAppChannel() {
+	if a.appConn != nil {
+		a.appConn.Close()
+	}
+}
@@ -2,6 +2,11 @@ package runtime
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
 	"time"
 )
 
@@ -15,6 +20,8 @@ type SidecarConfig struct {
 	APIListenAddresses  []string `json:"api_listen_addresses"`
 	RuntimePort         int      `json:"runtime_port"`
 	RuntimeCallbackPort int      `json:"runtime_callback_port"`
+	// HTTPPort is the port of the runtime HTTP API, disabled when 0.
+	HTTPPort int `json:"http_port,omitempty"`
 
 	GracefulShutdownDuration time.Duration `json:"graceful_shutdown_duration"`
 }
@@ -33,3 +40,120 @@ func ParseRuntimeConfig(data json.RawMessage) (*CapaRuntimeConfig, error) {
 	}
 	return cfg, nil
 }
+
+// LoadRuntimeConfig reads the runtime config from a json file. Its syntax errors are
+// reported with their position in the file.
+func LoadRuntimeConfig(path string) (*CapaRuntimeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseRuntimeConfig(data)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, fmt.Errorf("%s:%s: %v", path, PositionOf(data, syntaxErr.Offset), err)
+		case errors.As(err, &typeErr):
+			return nil, fmt.Errorf("%s:%s: %v", path, PositionOf(data, typeErr.Offset), err)
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// ConfigError is an error of a section of the runtime config.
+type ConfigError struct {
+	// Path is the json path of the section, like "sidecar.runtime_port".
+	Path string
+	Err  error
+	// Warning is set for the problems the runtime ignores, like an unknown extend
+	// config, which may be meant for a newer version.
+	Warning bool
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the config, and returns its first error. The warnings are ignored.
+func (c *CapaRuntimeConfig) Validate() error {
+	for _, err := range c.ValidateAll() {
+		if !err.Warning {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateAll checks the app and sidecar configs, and the extend configs with their
+// registered validators. It returns all the errors and warnings found.
+func (c *CapaRuntimeConfig) ValidateAll() []*ConfigError {
+	var errs []*ConfigError
+	report := func(path string, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{Path: path, Err: fmt.Errorf(format, args...)})
+	}
+	warn := func(path string, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{Path: path, Err: fmt.Errorf(format, args...), Warning: true})
+	}
+
+	if c.AppManagement == nil || c.AppManagement.AppId == "" {
+		report("app.app_id", "is required")
+	}
+	sidecar := c.SidecarManagement
+	if sidecar == nil {
+		report("sidecar", "is required")
+		sidecar = &SidecarConfig{}
+	}
+	if len(sidecar.APIListenAddresses) == 0 {
+		report("sidecar.api_listen_addresses", "is required")
+	}
+	for i, address := range sidecar.APIListenAddresses {
+		if address != "localhost" && net.ParseIP(address) == nil {
+			report(fmt.Sprintf("sidecar.api_listen_addresses[%d]", i), "invalid address %q", address)
+		}
+	}
+	if err := validatePort(sidecar.RuntimePort); err != nil {
+		report("sidecar.runtime_port", "%v", err)
+	}
+	if err := validatePort(sidecar.RuntimeCallbackPort); err != nil {
+		report("sidecar.runtime_callback_port", "%v", err)
+	}
+	if sidecar.HTTPPort != 0 {
+		if err := validatePort(sidecar.HTTPPort); err != nil {
+			report("sidecar.http_port", "%v", err)
+		}
+	}
+	if sidecar.GracefulShutdownDuration < 0 {
+		report("sidecar.graceful_shutdown_duration", "must not be negative")
+	}
+
+	keys := make([]string, 0, len(c.Extends))
+	for key := range c.Extends {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		validate, ok := extendsValidators[key]
+		if !ok {
+			warn("extends."+key, "unknown section, ignored")
+			continue
+		}
+		if err := validate(c.Extends[key]); err != nil {
+			report("extends."+key, "%v", err)
+		}
+	}
+	return errs
+}
+
+func validatePort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	return nil
+}
@@ -1,6 +1,15 @@
 package runtime
 
+import "time"
+
 const (
-	proxyPort   = 8081
-	servicePort = 8080
+	// DefaultRuntimePort is the port of the runtime gRPC API.
+	DefaultRuntimePort = 8081
+	// DefaultRuntimeCallbackPort is the port the app serves the runtime callbacks on.
+	DefaultRuntimeCallbackPort = 8080
+	// DefaultGracefulShutdownDuration is how long outstanding operations may take on shutdown.
+	DefaultGracefulShutdownDuration = 5 * time.Second
 )
+
+// DefaultAPIListenAddresses are the addresses the runtime APIs listen on.
+var DefaultAPIListenAddresses = []string{"127.0.0.1"}
@@ -0,0 +1,55 @@
+package runtime
+
+import (
+	"encoding/json"
+
+	"group.rxcloud/capa/pkg/accesslog"
+	"group.rxcloud/capa/pkg/bindings"
+	"group.rxcloud/capa/pkg/proxy"
+	"group.rxcloud/capa/pkg/pubsub"
+	"group.rxcloud/capa/pkg/state"
+)
+
+// extendsValidators are the validators of the extend configs, by key.
+var extendsValidators = map[string]func(data json.RawMessage) error{}
+
+// RegisterExtendsValidator registers the validator of the extend config of the key.
+// The extend configs without a validator are reported as unknown with a warning.
+func RegisterExtendsValidator(key string, validate func(data json.RawMessage) error) {
+	extendsValidators[key] = validate
+}
+
+func init() {
+	RegisterExtendsValidator(proxy.ConfigKey, func(data json.RawMessage) error {
+		cfg, err := proxy.ParseConfig(data)
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	})
+	RegisterExtendsValidator(accesslog.ConfigKey, func(data json.RawMessage) error {
+		_, err := accesslog.ParseConfig(data)
+		return err
+	})
+	RegisterExtendsValidator(bindings.ConfigKey, func(data json.RawMessage) error {
+		cfg, err := bindings.ParseConfig(data)
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	})
+	RegisterExtendsValidator(state.ConfigKey, func(data json.RawMessage) error {
+		cfg, err := state.ParseConfig(data)
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	})
+	RegisterExtendsValidator(pubsub.ConfigKey, func(data json.RawMessage) error {
+		cfg, err := pubsub.ParseConfig(data)
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	})
+}
@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Position is a position in a config file.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// PositionOf returns the position of the byte offset in the data.
+func PositionOf(data []byte, offset int64) Position {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return Position{Line: line, Column: column}
+}
+
+// Positions are the positions of the values of a json document by path, like
+// "extends.proxy" or "sidecar.api_listen_addresses[0]".
+type Positions map[string]Position
+
+// ParsePositions returns the positions of the values of a json document. It returns
+// the positions found up to the first syntax error.
+func ParsePositions(data []byte) Positions {
+	positions := Positions{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	walkPositions(dec, data, "", positions)
+	return positions
+}
+
+// Lookup returns the position of the path, or of its closest parent with a position.
+func (p Positions) Lookup(path string) (Position, bool) {
+	for path != "" {
+		if pos, ok := p[path]; ok {
+			return pos, true
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return Position{}, false
+}
+
+func walkPositions(dec *json.Decoder, data []byte, path string, positions Positions) bool {
+	tok, err := dec.Token()
+	if err != nil {
+		return false
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return true
+	}
+	switch delim {
+	case '{':
+		for dec.More() {
+			offset := valueOffset(data, dec.InputOffset())
+			tok, err := dec.Token()
+			if err != nil {
+				return false
+			}
+			key, _ := tok.(string)
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			positions[child] = PositionOf(data, offset)
+			if !walkPositions(dec, data, child, positions) {
+				return false
+			}
+		}
+	case '[':
+		for i := 0; dec.More(); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			positions[child] = PositionOf(data, valueOffset(data, dec.InputOffset()))
+			if !walkPositions(dec, data, child, positions) {
+				return false
+			}
+		}
+	}
+	// The closing delimiter.
+	_, err = dec.Token()
+	return err == nil
+}
+
+// valueOffset skips the separators from the end of the previous token to the next value.
+func valueOffset(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import "testing"
+
+func TestParsePositions(t *testing.T) {
+	data := []byte(`{
+  "sidecar": {
+    "api_listen_addresses": ["127.0.0.1", "[::1]"]
+  },
+  "extends": {
+    "proxy": {
+      "routes": [
+        {"name": "first"},
+        {
+          "name": "second"
+        }
+      ]
+    },
+    "pubsub": {"components": []}
+  }
+}`)
+	positions := ParsePositions(data)
+
+	cases := []struct {
+		path     string
+		expected Position
+		ok       bool
+	}{
+		{path: "sidecar", expected: Position{Line: 2, Column: 3}, ok: true},
+		{path: "sidecar.api_listen_addresses[0]", expected: Position{Line: 3, Column: 30}, ok: true},
+		{path: "sidecar.api_listen_addresses[1]", expected: Position{Line: 3, Column: 43}, ok: true},
+		{path: "extends", expected: Position{Line: 5, Column: 3}, ok: true},
+		{path: "extends.proxy", expected: Position{Line: 6, Column: 5}, ok: true},
+		{path: "extends.proxy.routes[0]", expected: Position{Line: 8, Column: 9}, ok: true},
+		{path: "extends.proxy.routes[0].name", expected: Position{Line: 8, Column: 10}, ok: true},
+		{path: "extends.proxy.routes[1].name", expected: Position{Line: 10, Column: 11}, ok: true},
+		{path: "extends.pubsub.components", expected: Position{Line: 14, Column: 16}, ok: true},
+		// Lookup falls back to the closest parent with a position.
+		{path: "extends.proxy.routes[1].match.path_prefix", expected: Position{Line: 9, Column: 9}, ok: true},
+		{path: "extends.pubsub.components[0].name", expected: Position{Line: 14, Column: 16}, ok: true},
+		{path: "extends.unknown", expected: Position{Line: 5, Column: 3}, ok: true},
+		{path: "unknown", ok: false},
+		{path: "", ok: false},
+	}
+	for _, c := range cases {
+		pos, ok := positions.Lookup(c.path)
+		if ok != c.ok || pos != c.expected {
+			t.Errorf("%q: expected %s (%v), got %s (%v)", c.path, c.expected, c.ok, pos, ok)
+		}
+	}
+}
+
+func TestParsePositionsUpToSyntaxError(t *testing.T) {
+	positions := ParsePositions([]byte("{\n  \"extends\": {\n    \"proxy\": {,\n    \"state\": {}\n  }\n}"))
+	if pos, ok := positions["extends.proxy"]; !ok || pos != (Position{Line: 3, Column: 5}) {
+		t.Fatalf("expected the position before the syntax error, got %s (%v)", pos, ok)
+	}
+	if _, ok := positions["extends.state"]; ok {
+		t.Fatalf("expected no position after the syntax error")
+	}
+}
@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/proxy"
+)
+
+// initProxy creates and starts the L7 proxy of a sidecar node, when the proxy is configured.
+func (a *CapaRuntime) initProxy() error {
+	data, ok := a.runtimeConfig.Extends[proxy.ConfigKey]
+	if !ok {
+		return nil
+	}
+	if a.node.Type != proxy.SidecarProxy {
+		log.Warnf("[Capa.runtime] the proxy config is ignored by a %s node", a.node.Type)
+		return nil
+	}
+	cfg, err := proxy.ParseConfig(data)
+	if err != nil {
+		return err
+	}
+	p, err := proxy.NewProxy(cfg)
+	if err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	p.SetNode(a.node)
+	if err := p.Start(); err != nil {
+		p.Stop(0)
+		return fmt.Errorf("proxy: %v", err)
+	}
+	a.proxy = p
+	return nil
+}
+
+// UpdateProxyConfig applies a new proxy config, like one pushed by the configuration
+// component. An invalid config is rejected and the active one is kept.
+func (a *CapaRuntime) UpdateProxyConfig(data json.RawMessage) error {
+	if a.proxy == nil {
+		return fmt.Errorf("the proxy is not running")
+	}
+	return a.proxy.UpdateConfig(data)
+}
+
+// stopProxy stops the proxy, waiting at most the duration for its outstanding requests.
+func (a *CapaRuntime) stopProxy(duration time.Duration) {
+	if a.proxy != nil {
+		a.proxy.Stop(duration)
+	}
+}
@@ -0,0 +1,190 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	grpc_go "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/pubsub"
+	"group.rxcloud/capa/pkg/pubsub/memory"
+)
+
+const (
+	// The CloudEvents attributes of the messages delivered to the app.
+	cloudEventType        = "com.capa.event.sent"
+	cloudEventSpecVersion = "1.0"
+
+	// listSubscriptionsRetry is the delay before listing the subscriptions of the app again.
+	listSubscriptionsRetry = time.Second
+)
+
+// The built-in pubsub works offline.
+func init() {
+	pubsub.RegisterPubSub(memory.ComponentType, memory.NewInMemory)
+}
+
+// initPubSub creates and opens the pubsubs. The app channel is needed to deliver the
+// messages, and to list the programmatic subscriptions of the app.
+func (a *CapaRuntime) initPubSub() error {
+	cfg, err := pubsub.ParseConfig(a.runtimeConfig.Extends[pubsub.ConfigKey])
+	if err != nil {
+		return err
+	}
+	pubSubs, subscriptions, err := pubsub.NewPubSubs(cfg)
+	if err != nil {
+		return err
+	}
+	a.pubSubs = map[string]pubsub.PubSub{}
+	for _, component := range cfg.Components {
+		ps := pubSubs[component.Name]
+		if err := ps.Open(); err != nil {
+			a.closePubSubs()
+			return fmt.Errorf("pubsub: component %q: %v", component.Name, err)
+		}
+		a.pubSubs[component.Name] = ps
+		log.Infof("[Capa.runtime.args] pubsub: %s, type: %s", component.Name, component.Type)
+	}
+	a.subscriptions = subscriptions
+	if len(a.pubSubs) > 0 {
+		return a.initAppChannel()
+	}
+	return nil
+}
+
+// startSubscriptions starts delivering the messages of the declarative subscriptions,
+// then of the programmatic ones once the app lists them, until the runtime is shut down.
+func (a *CapaRuntime) startSubscriptions() {
+	if len(a.pubSubs) == 0 {
+		return
+	}
+	subscribed := map[string]bool{}
+	for _, sub := range a.subscriptions {
+		subscribed[sub.Key()] = true
+		a.runSubscription(sub)
+	}
+	a.subscriptionsWG.Add(1)
+	go func() {
+		defer a.subscriptionsWG.Done()
+		for _, sub := range a.listAppSubscriptions() {
+			if subscribed[sub.Key()] {
+				log.Warnf("[Capa.runtime] the app subscribes to %s, which is already subscribed, the subscription is ignored", sub.Key())
+				continue
+			}
+			subscribed[sub.Key()] = true
+			a.runSubscription(sub)
+		}
+	}()
+}
+
+func (a *CapaRuntime) runSubscription(sub *pubsub.Subscription) {
+	log.Infof("[Capa.runtime] subscribed to %s", sub.Key())
+	a.subscriptionsWG.Add(1)
+	go func() {
+		defer a.subscriptionsWG.Done()
+		if err := sub.Run(a.ctx, a.pubSubs[sub.PubsubName], a.sendTopicEventToApp); err != nil {
+			log.Errorf("[Capa.runtime] subscription to %s stopped: %v", sub.Key(), err)
+		}
+	}()
+}
+
+// listAppSubscriptions returns the programmatic subscriptions of the app. It waits for the
+// app to be up, and an app not serving them has none.
+func (a *CapaRuntime) listAppSubscriptions() []*pubsub.Subscription {
+	var res *runtimev1pb.ListTopicSubscriptionsResponse
+	for {
+		var err error
+		res, err = a.appCallback.ListTopicSubscriptions(a.ctx, &emptypb.Empty{}, grpc_go.WaitForReady(true))
+		if err == nil {
+			break
+		}
+		if status.Code(err) == codes.Unimplemented || a.ctx.Err() != nil {
+			return nil
+		}
+		log.Warnf("[Capa.runtime] failed to list the subscriptions of the app, retry in %s: %v", listSubscriptionsRetry, err)
+		select {
+		case <-time.After(listSubscriptionsRetry):
+		case <-a.ctx.Done():
+			return nil
+		}
+	}
+
+	var subscriptions []*pubsub.Subscription
+	for _, s := range res.Subscriptions {
+		sub, err := pubsub.NewSubscription(&pubsub.SubscriptionConfig{
+			PubsubName:      s.PubsubName,
+			Topic:           s.Topic,
+			Metadata:        s.Metadata,
+			DeadLetterTopic: s.DeadLetterTopic,
+			MaxConcurrency:  int(s.MaxConcurrency),
+		})
+		if err != nil {
+			log.Errorf("[Capa.runtime] invalid subscription of the app to %s/%s: %v", s.PubsubName, s.Topic, err)
+			continue
+		}
+		if _, ok := a.pubSubs[sub.PubsubName]; !ok {
+			log.Errorf("[Capa.runtime] the app subscribes to %s of the unknown pubsub %s", sub.Topic, sub.PubsubName)
+			continue
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions
+}
+
+// sendTopicEventToApp delivers a message to the app as a CloudEvent. The app acknowledges
+// it, or asks to retry it, or drops it. The delivery waits for the app to be up, so that
+// the messages don't use their attempts while the app starts or restarts.
+func (a *CapaRuntime) sendTopicEventToApp(ctx context.Context, sub *pubsub.Subscription, msg *pubsub.NewMessage) error {
+	res, err := a.appCallback.OnTopicEvent(ctx, &runtimev1pb.TopicEventRequest{
+		Id:              msg.ID,
+		Source:          a.runtimeConfig.AppManagement.AppId,
+		Type:            cloudEventType,
+		SpecVersion:     cloudEventSpecVersion,
+		DataContentType: msg.ContentType,
+		Data:            msg.Data,
+		Topic:           msg.Topic,
+		PubsubName:      sub.PubsubName,
+		Metadata:        msg.Metadata,
+	}, grpc_go.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	switch res.Status {
+	case runtimev1pb.TopicEventResponse_SUCCESS:
+		return nil
+	case runtimev1pb.TopicEventResponse_DROP:
+		return pubsub.ErrDropped
+	default:
+		return fmt.Errorf("the app answered %s", res.Status)
+	}
+}
+
+// stopSubscriptions waits, at most the duration, for the subscriptions to stop once the
+// runtime context is canceled.
+func (a *CapaRuntime) stopSubscriptions(duration time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		a.subscriptionsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(duration):
+		log.Warnf("subscriptions not stopped after %s", duration)
+	}
+}
+
+// closePubSubs closes the opened pubsubs.
+func (a *CapaRuntime) closePubSubs() {
+	for name, ps := range a.pubSubs {
+		if err := ps.Close(); err != nil {
+			log.Warnf("[Capa.runtime] failed to close pubsub %s: %v", name, err)
+		}
+	}
+}
@@ -2,10 +2,21 @@ package runtime
 
 import (
 	"context"
+	"github.com/dapr/dapr/pkg/config"
+	"github.com/dapr/dapr/pkg/cors"
 	log "github.com/sirupsen/logrus"
+	grpc_go "google.golang.org/grpc"
+	"group.rxcloud/capa/pkg/accesslog"
 	"group.rxcloud/capa/pkg/actors"
+	"group.rxcloud/capa/pkg/bindings"
 	"group.rxcloud/capa/pkg/grpc"
-	"os"
+	"group.rxcloud/capa/pkg/http"
+	runtimev1pb "group.rxcloud/capa/pkg/proto/runtime/v1"
+	"group.rxcloud/capa/pkg/proxy"
+	"group.rxcloud/capa/pkg/pubsub"
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/version"
+	"sync"
 	"time"
 )
 
@@ -14,12 +25,45 @@ type CapaRuntime struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	shutdownC chan error
+	// shutdownOnce runs Shutdown once, it is reached from the signals and the Shutdown API
+	shutdownOnce sync.Once
 
 	// configs
 	runtimeConfig *CapaRuntimeConfig
 
 	// actor
 	actor actors.Actors
+
+	// access log of the API servers
+	accessLog *accesslog.Logger
+
+	// node of the sidecar
+	node *proxy.Node
+	// L7 proxy of the sidecar, nil when not configured
+	proxy *proxy.Proxy
+
+	// output bindings, by name
+	outputBindings bindings.OutputBindings
+	// input bindings, delivering their events to the app
+	inputBindings   []*bindings.Input
+	inputBindingsWG sync.WaitGroup
+
+	// state stores, by name
+	stateStores map[string]state.Store
+
+	// pubsubs, by name
+	pubSubs map[string]pubsub.PubSub
+	// subscriptions of the app, delivering the messages of their topics
+	subscriptions   []*pubsub.Subscription
+	subscriptionsWG sync.WaitGroup
+
+	// callback API of the app
+	appConn     *grpc_go.ClientConn
+	appCallback runtimev1pb.AppCallbackClient
+
+	// API servers, the HTTP one is nil when disabled
+	grpcAPIServer grpc.Server
+	httpAPIServer http.Server
 }
 
 // NewCapaRuntime returns a new runtime with the given runtime config.
@@ -28,6 +72,7 @@ func NewCapaRuntime(runtimeConfig *CapaRuntimeConfig) *CapaRuntime {
 	return &CapaRuntime{
 		ctx:           ctx,
 		cancel:        cancel,
+		shutdownC:     make(chan error, 1),
 		runtimeConfig: runtimeConfig,
 	}
 }
@@ -36,6 +81,7 @@ func NewCapaRuntime(runtimeConfig *CapaRuntimeConfig) *CapaRuntime {
 func (a *CapaRuntime) Run(opts ...Option) error {
 	start := time.Now().UTC()
 
+	log.Infof("[Capa.runtime.args] %s", version.Get())
 	appConfig := a.runtimeConfig.AppManagement
 	log.Infof("[Capa.runtime.args] app id: %s", appConfig.AppId)
 	log.Infof("[Capa.runtime.args] app env: %s", appConfig.Env)
@@ -43,6 +89,9 @@ func (a *CapaRuntime) Run(opts ...Option) error {
 	sidecarConfig := a.runtimeConfig.SidecarManagement
 	log.Infof("[Capa.runtime.args] runtime port: %d", sidecarConfig.RuntimePort)
 	log.Infof("[Capa.runtime.args] runtime callback port: %d", sidecarConfig.RuntimeCallbackPort)
+	if sidecarConfig.HTTPPort != 0 {
+		log.Infof("[Capa.runtime.args] runtime http port: %d", sidecarConfig.HTTPPort)
+	}
 	log.Infof("[Capa.runtime.args] runtime shutdown duration: %s", sidecarConfig.GracefulShutdownDuration)
 
 	// init options
@@ -64,13 +113,49 @@ func (a *CapaRuntime) Run(opts ...Option) error {
 }
 
 func (a *CapaRuntime) initRuntime(opts *runtimeOpts) error {
+	err := a.initNode()
+	if err != nil {
+		return err
+	}
+	err = a.initAccessLog()
+	if err != nil {
+		return err
+	}
+	err = a.initProxy()
+	if err != nil {
+		return err
+	}
+	err = a.initBindings()
+	if err != nil {
+		return err
+	}
+	err = a.initState()
+	if err != nil {
+		return err
+	}
+	err = a.initPubSub()
+	if err != nil {
+		return err
+	}
+
 	// Create and start external gRPC servers
 	grpcAPI := a.getGRPCAPI()
 
-	err := a.startGRPCAPIServer(grpcAPI, a.runtimeConfig.SidecarManagement.RuntimePort)
+	err = a.startGRPCAPIServer(grpcAPI, a.runtimeConfig.SidecarManagement.RuntimePort)
 	if err != nil {
 		log.Fatalf("failed to start API gRPC server: %s", err)
 	}
+	if port := a.runtimeConfig.SidecarManagement.HTTPPort; port != 0 {
+		httpAPI := a.getHTTPAPI()
+		err = a.startHTTPAPIServer(httpAPI, port)
+		if err != nil {
+			log.Fatalf("failed to start API HTTP server: %s", err)
+		}
+		httpAPI.MarkStatusAsReady()
+	}
+
+	a.startInputBindings()
+	a.startSubscriptions()
 
 	//err = a.initActors()
 	//if err != nil {
@@ -82,8 +167,45 @@ func (a *CapaRuntime) initRuntime(opts *runtimeOpts) error {
 	return nil
 }
 
+func (a *CapaRuntime) initNode() error {
+	appConfig := a.runtimeConfig.AppManagement
+	node, err := proxy.NodeFromEnv(appConfig.AppId, appConfig.Env, appConfig.Cloud)
+	if err != nil {
+		return err
+	}
+	log.Infof("[Capa.runtime.args] node: %s, type: %s, ips: %v", node.ID(), node.Type, node.IPAddresses)
+	a.node = node
+	return nil
+}
+
+// currentNode returns a copy of the node with the capabilities of the running components.
+func (a *CapaRuntime) currentNode() *proxy.Node {
+	node := *a.node
+	node.Capabilities = append([]string(nil), a.node.Capabilities...)
+	if a.accessLog != nil {
+		node.AddCapabilities(proxy.CapabilityAccessLog)
+	}
+	if a.proxy != nil {
+		node.AddCapabilities(a.proxy.Capabilities()...)
+	}
+	return &node
+}
+
+func (a *CapaRuntime) initAccessLog() error {
+	data, ok := a.runtimeConfig.Extends[accesslog.ConfigKey]
+	if !ok {
+		return nil
+	}
+	cfg, err := accesslog.ParseConfig(data)
+	if err != nil {
+		return err
+	}
+	a.accessLog, err = accesslog.New(a.runtimeConfig.AppManagement.AppId, cfg)
+	return err
+}
+
 func (a *CapaRuntime) getGRPCAPI() grpc.API {
-	return grpc.NewAPI(a.runtimeConfig.AppManagement.AppId, a.actor, a.ShutdownWithWait)
+	return grpc.NewAPI(a.runtimeConfig.AppManagement.AppId, a.currentNode, a.actor, a.sendToOutputBinding, a.stateStores, a.pubSubs, a.ShutdownWithWait)
 }
 
 func (a *CapaRuntime) startGRPCAPIServer(api grpc.API, port int) error {
@@ -92,29 +214,91 @@ func (a *CapaRuntime) startGRPCAPIServer(api grpc.API, port int) error {
 	if err := server.StartNonBlocking(); err != nil {
 		return err
 	}
+	a.grpcAPIServer = server
 	return nil
 }
 
 func (a *CapaRuntime) getNewServerConfig(apiListenAddresses []string, port int) grpc.ServerConfig {
-	return grpc.NewServerConfig(a.runtimeConfig.AppManagement.AppId, apiListenAddresses, port)
+	serverConf := grpc.NewServerConfig(a.runtimeConfig.AppManagement.AppId, apiListenAddresses, port)
+	serverConf.AccessLogger = a.accessLog
+	return serverConf
+}
+
+func (a *CapaRuntime) getHTTPAPI() http.API {
+	return http.NewAPI(a.runtimeConfig.AppManagement.AppId, nil, a.stateStores, a.pubSubs, a.ShutdownWithWait)
+}
+
+func (a *CapaRuntime) startHTTPAPIServer(api http.API, port int) error {
+	serverConf := http.NewServerConfig(a.runtimeConfig.AppManagement.AppId, "", port,
+		a.runtimeConfig.SidecarManagement.APIListenAddresses, nil, 0, cors.DefaultAllowedOrigins, false,
+		http.DefaultMaxRequestBodySize, "", http.DefaultReadBufferSize, false, false)
+	serverConf.AccessLogger = a.accessLog
+	server := http.NewServer(api, serverConf, config.APISpec{})
+	if err := server.StartNonBlocking(); err != nil {
+		return err
+	}
+	a.httpAPIServer = server
+	return nil
 }
 
 // ShutdownWithWait will gracefully stop runtime and wait outstanding operations.
+// The process is left to the caller of WaitUntilShutdown, e.g. capa run keeps running
+// until the app exits, and exits with its code.
 func (a *CapaRuntime) ShutdownWithWait() {
 	a.Shutdown(a.runtimeConfig.SidecarManagement.GracefulShutdownDuration)
-	os.Exit(0)
 }
 
+// Shutdown stops the runtime, waiting at most the duration for the outstanding operations.
+// Only the first call stops the runtime, the later ones wait until it is stopped.
 func (a *CapaRuntime) Shutdown(duration time.Duration) {
+	a.shutdownOnce.Do(func() {
+		a.shutdown(duration)
+	})
+}
+
+func (a *CapaRuntime) shutdown(duration time.Duration) {
 	a.cancel()
 	a.stopActor()
-	log.Infof("dapr shutting down.")
-	log.Info("Stopping Dapr APIs")
+	log.Infof("capa shutting down.")
+	log.Info("Stopping Capa APIs")
 	log.Infof("Waiting %s to finish outstanding operations", duration)
-	<-time.After(duration)
+	a.stopAPIServers(duration)
+	a.stopProxy(duration)
+	a.stopInputBindings(duration)
+	a.stopSubscriptions(duration)
+	a.closeAppChannel()
+	a.closeState()
+	a.closePubSubs()
+	a.accessLog.Close()
 	a.shutdownC <- nil
 }
 
+// WaitUntilShutdown blocks until the runtime is shut down.
+func (a *CapaRuntime) WaitUntilShutdown() error {
+	return <-a.shutdownC
+}
+
+// stopAPIServers stops the API servers once their outstanding calls are done, or
+// once the duration elapses.
+func (a *CapaRuntime) stopAPIServers(duration time.Duration) {
+	if a.grpcAPIServer == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		a.grpcAPIServer.Close()
+		if a.httpAPIServer != nil {
+			a.httpAPIServer.Close()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(duration):
+		log.Warnf("outstanding operations not finished after %s", duration)
+	}
+}
+
 func (a *CapaRuntime) stopActor() {
 	if a.actor != nil {
 		log.Info("Shutting down actor")
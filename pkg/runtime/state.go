@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/state/local"
+	"group.rxcloud/capa/pkg/state/memory"
+)
+
+// The built-in state stores work offline.
+func init() {
+	state.RegisterStore(memory.ComponentType, memory.NewInMemoryStore)
+	state.RegisterStore(local.ComponentType, local.NewLocal)
+}
+
+// initState creates and opens the state stores.
+func (a *CapaRuntime) initState() error {
+	cfg, err := state.ParseConfig(a.runtimeConfig.Extends[state.ConfigKey])
+	if err != nil {
+		return err
+	}
+	stores, err := state.NewStores(cfg)
+	if err != nil {
+		return err
+	}
+	a.stateStores = map[string]state.Store{}
+	for _, component := range cfg.Components {
+		store := stores[component.Name]
+		if err := store.Open(); err != nil {
+			a.closeState()
+			return fmt.Errorf("state: component %q: %v", component.Name, err)
+		}
+		a.stateStores[component.Name] = store
+		log.Infof("[Capa.runtime.args] state store: %s, type: %s", component.Name, component.Type)
+	}
+	return nil
+}
+
+// closeState closes the opened state stores.
+func (a *CapaRuntime) closeState() {
+	for name, store := range a.stateStores {
+		if err := store.Close(); err != nil {
+			log.Warnf("[Capa.runtime] failed to close state store %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+// ConfigKey is the key of the state config in CapaRuntimeConfig.Extends.
+const ConfigKey = "state"
+
+// Config declares the state store components of the runtime.
+type Config struct {
+	Components []*Component `json:"components,omitempty"`
+}
+
+// Component is a state store component.
+type Component = components.Component
+
+// ParseConfig parses the state config from its json form. Empty data yields no stores.
+func ParseConfig(data json.RawMessage) (*Config, error) {
+	cfg := &Config{}
+	if err := components.ParseConfig(ConfigKey, data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the config by creating its components, which have no side effects.
+func (c *Config) Validate() error {
+	_, err := NewStores(c)
+	return err
+}
+
+// NewStores creates and inits the state stores of the config, by name. They are not opened.
+func NewStores(c *Config) (map[string]Store, error) {
+	if err := components.Validate(ConfigKey, c.Components); err != nil {
+		return nil, err
+	}
+	stores := map[string]Store{}
+	for _, component := range c.Components {
+		store, err := NewStore(component.Type)
+		if err != nil {
+			return nil, fmt.Errorf("state: component %q: %v", component.Name, err)
+		}
+		if err := store.Init(component.InitMetadata()); err != nil {
+			return nil, fmt.Errorf("state: component %q: %v", component.Name, err)
+		}
+		stores[component.Name] = store
+	}
+	return stores, nil
+}
@@ -0,0 +1,178 @@
+// Package local is a durable state store for a single host. It keeps the state in memory
+// and journals each transaction to a file, which is replayed when the store is opened.
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"group.rxcloud/capa/pkg/state"
+	"group.rxcloud/capa/pkg/state/memory"
+)
+
+// ComponentType is the type of the local components.
+const ComponentType = "state.local"
+
+// pathKey is the metadata of the journal file.
+const pathKey = "path"
+
+// Local is an in-memory store journaling its transactions to a file, one json line each.
+// The journal is compacted when the store is opened. A write with strong consistency
+// returns once its transaction is synced to the disk.
+type Local struct {
+	*memory.Store
+
+	path string
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// NewLocal returns a new local state store.
+func NewLocal() state.Store {
+	l := &Local{}
+	l.Store = memory.New(l.journal)
+	return l
+}
+
+func (l *Local) Init(metadata state.Metadata) error {
+	path := metadata.Properties[pathKey]
+	if path == "" {
+		return fmt.Errorf("%s is required", pathKey)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	l.path = path
+	return nil
+}
+
+// Open replays the journal, then rewrites it with the live keys only.
+func (l *Local) Open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	if err := l.replay(); err != nil {
+		return err
+	}
+	if err := l.compact(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.file = file
+	return nil
+}
+
+// replay restores the transactions of the journal. A torn last line, from a crash in
+// the middle of a write, is ignored.
+func (l *Local) replay() error {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var changes []memory.Change
+		if err := json.Unmarshal(line, &changes); err != nil {
+			if i == len(lines)-1 {
+				log.Warnf("[Capa.state] ignoring the torn last transaction of %s", l.path)
+				break
+			}
+			return fmt.Errorf("corrupted journal %s at line %d: %v", l.path, i+1, err)
+		}
+		l.Restore(changes)
+	}
+	return nil
+}
+
+// compact replaces the journal with a single transaction creating the live keys.
+func (l *Local) compact() error {
+	tmp := l.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriter(file)
+	if changes := l.Snapshot(); len(changes) > 0 {
+		if err := writeLine(w, changes); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func writeLine(w *bufio.Writer, changes []memory.Change) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// journal appends the transaction to the journal, in a single write.
+func (l *Local) journal(changes []memory.Change, sync bool) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+func (l *Local) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
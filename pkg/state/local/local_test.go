@@ -0,0 +1,98 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"group.rxcloud/capa/pkg/state"
+)
+
+func open(t *testing.T, path string) *Local {
+	t.Helper()
+	l := NewLocal().(*Local)
+	if err := l.Init(state.Metadata{Properties: map[string]string{pathKey: path}}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	if err := l.Open(); err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	return l
+}
+
+func get(t *testing.T, l *Local, key string) *state.GetResponse {
+	t.Helper()
+	res, err := l.Get(context.Background(), &state.GetRequest{Key: key})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", key, err)
+	}
+	return res
+}
+
+func TestReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal")
+	l := open(t, path)
+	ctx := context.Background()
+	l.Set(ctx, &state.SetRequest{Key: "a", Value: []byte("1")})
+	l.Set(ctx, &state.SetRequest{Key: "b", Value: []byte("2"), Options: state.SetStateOption{Consistency: state.Strong}})
+	l.Delete(ctx, &state.DeleteRequest{Key: "a"})
+	etag := get(t, l, "b").ETag
+	l.Close()
+
+	// A crash in the middle of a write leaves a torn last line.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("failed to open the journal: %v", err)
+	}
+	f.WriteString(`[{"key":"c","value":"M`)
+	f.Close()
+
+	l = open(t, path)
+	if res := get(t, l, "a"); res.Data != nil {
+		t.Fatalf("expected the deleted key to stay deleted, got %s", res.Data)
+	}
+	if res := get(t, l, "b"); string(res.Data) != "2" || *res.ETag != *etag {
+		t.Fatalf("expected b with etag %s, got %+v", *etag, res)
+	}
+	if res := get(t, l, "c"); res.Data != nil {
+		t.Fatalf("expected the torn transaction to be ignored, got %s", res.Data)
+	}
+	l.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Fatalf("expected the journal to be compacted to one line, got %d", lines)
+	}
+}
+
+func TestETagsNotReusedAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	l := open(t, path)
+	ctx := context.Background()
+	l.Set(ctx, &state.SetRequest{Key: "a", Value: []byte("1")})
+	l.Set(ctx, &state.SetRequest{Key: "b", Value: []byte("2")})
+	etag := get(t, l, "b").ETag
+	l.Delete(ctx, &state.DeleteRequest{Key: "b"})
+	l.Close()
+
+	// Compacted twice, the journal no longer has the deleted key.
+	open(t, path).Close()
+	l = open(t, path)
+	defer l.Close()
+	l.Set(ctx, &state.SetRequest{Key: "b", Value: []byte("3")})
+	if res := get(t, l, "b"); *res.ETag == *etag {
+		t.Fatalf("expected a new etag after the restart, got %s again", *etag)
+	}
+}
+
+func TestCorruptedJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	os.WriteFile(path, []byte("not json\n[]\n"), 0644)
+	l := NewLocal().(*Local)
+	l.Init(state.Metadata{Properties: map[string]string{pathKey: path}})
+	if err := l.Open(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected a corrupted journal to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package memory is a state store keeping the state in memory. It is the base of the
+// local store, which journals the changes of the state to a file.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"group.rxcloud/capa/pkg/state"
+)
+
+// ComponentType is the type of the in-memory components.
+const ComponentType = "state.in-memory"
+
+const (
+	// ttlKey is the request metadata of the time to live of a key in seconds. The keys
+	// don't expire by default, or when it is not positive.
+	ttlKey = "ttlInSeconds"
+	// ttlExpireTimeKey is the response metadata of the expiry time of a key, in RFC 3339.
+	ttlExpireTimeKey = "ttlExpireTime"
+)
+
+// Change is a change of the state of a key.
+type Change struct {
+	Key     string     `json:"key"`
+	Value   []byte     `json:"value,omitempty"`
+	ETag    uint64     `json:"etag,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Deleted bool       `json:"deleted,omitempty"`
+	// Seq, when set, is the last ETag of the store and the change has no key. It keeps
+	// the ETags of the deleted and expired keys from being reused after a snapshot.
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+// Journal persists the changes of a transaction before they are applied. When sync is
+// set, the changes must be durable once it returns.
+type Journal func(changes []Change, sync bool) error
+
+type item struct {
+	value   []byte
+	etag    uint64
+	expires time.Time
+}
+
+// Store keeps the state in memory. The ETags are the versions of the store, so that a
+// key deleted then created again has a new ETag. The reads are always strong.
+type Store struct {
+	lock  sync.RWMutex
+	items map[string]*item
+	// seq is the last ETag of the store.
+	seq     uint64
+	journal Journal
+}
+
+// NewInMemoryStore returns a new in-memory state store.
+func NewInMemoryStore() state.Store {
+	return New(nil)
+}
+
+// New returns a new store journaling its changes with the journal, when not nil.
+func New(journal Journal) *Store {
+	return &Store{
+		items:   map[string]*item{},
+		journal: journal,
+	}
+}
+
+func (s *Store) Init(metadata state.Metadata) error {
+	return nil
+}
+
+func (s *Store) Open() error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) Features() []state.Feature {
+	return []state.Feature{state.FeatureETag, state.FeatureTransactional}
+}
+
+func (s *Store) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	if err := state.CheckRequestOptions(req.Options); err != nil {
+		return nil, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	it := s.live(req.Key, time.Now())
+	if it == nil {
+		return &state.GetResponse{}, nil
+	}
+	etag := strconv.FormatUint(it.etag, 10)
+	res := &state.GetResponse{Data: it.value, ETag: &etag}
+	if !it.expires.IsZero() {
+		res.Metadata = map[string]string{ttlExpireTimeKey: it.expires.UTC().Format(time.RFC3339)}
+	}
+	return res, nil
+}
+
+func (s *Store) Set(ctx context.Context, req *state.SetRequest) error {
+	return s.Multi(ctx, &state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{{Operation: state.Upsert, Request: req}},
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, req *state.DeleteRequest) error {
+	return s.Multi(ctx, &state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{{Operation: state.Delete, Request: req}},
+	})
+}
+
+// Multi checks all the operations of the transaction before applying them. An operation
+// sees the changes of the previous ones.
+func (s *Store) Multi(ctx context.Context, req *state.TransactionalStateRequest) error {
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// pending are the items changed by the transaction, nil when deleted.
+	pending := map[string]*item{}
+	current := func(key string) *item {
+		if it, ok := pending[key]; ok {
+			return it
+		}
+		return s.live(key, now)
+	}
+	var changes []Change
+	seq := s.seq
+	sync := false
+	for _, op := range req.Operations {
+		switch op.Operation {
+		case state.Upsert:
+			r, ok := op.Request.(*state.SetRequest)
+			if !ok {
+				return fmt.Errorf("invalid request of operation %s", op.Operation)
+			}
+			if err := state.CheckRequestOptions(r.Options); err != nil {
+				return err
+			}
+			if err := checkETag(r.Key, current(r.Key), r.ETag, r.Options.Concurrency, true); err != nil {
+				return err
+			}
+			expires, err := expiry(r.Metadata, now)
+			if err != nil {
+				return err
+			}
+			seq++
+			pending[r.Key] = &item{value: r.Value, etag: seq, expires: expires}
+			change := Change{Key: r.Key, Value: r.Value, ETag: seq}
+			if !expires.IsZero() {
+				change.Expires = &expires
+			}
+			changes = append(changes, change)
+			sync = sync || r.Options.Consistency == state.Strong
+		case state.Delete:
+			r, ok := op.Request.(*state.DeleteRequest)
+			if !ok {
+				return fmt.Errorf("invalid request of operation %s", op.Operation)
+			}
+			if err := state.CheckRequestOptions(r.Options); err != nil {
+				return err
+			}
+			if err := checkETag(r.Key, current(r.Key), r.ETag, r.Options.Concurrency, false); err != nil {
+				return err
+			}
+			pending[r.Key] = nil
+			changes = append(changes, Change{Key: r.Key, Deleted: true})
+			sync = sync || r.Options.Consistency == state.Strong
+		default:
+			return fmt.Errorf("unsupported operation %s", op.Operation)
+		}
+	}
+
+	if s.journal != nil && len(changes) > 0 {
+		if err := s.journal(changes, sync); err != nil {
+			return err
+		}
+	}
+	s.apply(changes)
+	return nil
+}
+
+// checkETag checks the ETag of a write of the key, whose current item is nil when missing.
+func checkETag(key string, it *item, etag *string, concurrency string, set bool) error {
+	if concurrency == state.LastWrite {
+		return nil
+	}
+	if etag == nil {
+		if set && concurrency == state.FirstWrite && it != nil {
+			return fmt.Errorf("%w: key %s already exists", state.ErrETagMismatch, key)
+		}
+		return nil
+	}
+	version, err := strconv.ParseUint(*etag, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w %q", state.ErrETagInvalid, *etag)
+	}
+	if it == nil || it.etag != version {
+		return fmt.Errorf("%w: key %s", state.ErrETagMismatch, key)
+	}
+	return nil
+}
+
+// expiry returns the expiry time of the ttl of the metadata, zero when none.
+func expiry(metadata map[string]string, now time.Time) (time.Time, error) {
+	value, ok := metadata[ttlKey]
+	if !ok {
+		return time.Time{}, nil
+	}
+	ttl, err := strconv.Atoi(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", ttlKey, value)
+	}
+	if ttl <= 0 {
+		return time.Time{}, nil
+	}
+	return now.Add(time.Duration(ttl) * time.Second), nil
+}
+
+// live returns the item of the key, nil when missing or expired. The lock must be held.
+func (s *Store) live(key string, now time.Time) *item {
+	it := s.items[key]
+	if it == nil || (!it.expires.IsZero() && !now.Before(it.expires)) {
+		return nil
+	}
+	return it
+}
+
+// apply applies the changes. The lock must be held.
+func (s *Store) apply(changes []Change) {
+	for _, c := range changes {
+		if c.Seq > 0 {
+			if c.Seq > s.seq {
+				s.seq = c.Seq
+			}
+			continue
+		}
+		if c.Deleted {
+			delete(s.items, c.Key)
+			continue
+		}
+		it := &item{value: c.Value, etag: c.ETag}
+		if c.Expires != nil {
+			it.expires = *c.Expires
+		}
+		s.items[c.Key] = it
+		if c.ETag > s.seq {
+			s.seq = c.ETag
+		}
+	}
+}
+
+// Restore applies the changes journaled before, without journaling them.
+func (s *Store) Restore(changes []Change) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.apply(changes)
+}
+
+// Snapshot returns the changes creating the live keys of the store, sorted by key, after
+// the last ETag of the store.
+func (s *Store) Snapshot() []Change {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	now := time.Now()
+	changes := make([]Change, 0, len(s.items)+1)
+	if s.seq > 0 {
+		changes = append(changes, Change{Seq: s.seq})
+	}
+	for key := range s.items {
+		it := s.live(key, now)
+		if it == nil {
+			continue
+		}
+		change := Change{Key: key, Value: it.value, ETag: it.etag}
+		if !it.expires.IsZero() {
+			expires := it.expires
+			change.Expires = &expires
+		}
+		changes = append(changes, change)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// Scan returns the live states of the store, so that the queries are evaluated in memory.
+func (s *Store) Scan(ctx context.Context) ([]state.QueryItem, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	now := time.Now()
+	items := make([]state.QueryItem, 0, len(s.items))
+	for key := range s.items {
+		it := s.live(key, now)
+		if it == nil {
+			continue
+		}
+		etag := strconv.FormatUint(it.etag, 10)
+		items = append(items, state.QueryItem{Key: key, Data: it.value, ETag: &etag})
+	}
+	return items, nil
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"group.rxcloud/capa/pkg/state"
+)
+
+func get(t *testing.T, s *Store, key string) *state.GetResponse {
+	t.Helper()
+	res, err := s.Get(context.Background(), &state.GetRequest{Key: key})
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", key, err)
+	}
+	return res
+}
+
+func set(s *Store, key, value string, etag *string, concurrency string) error {
+	return s.Set(context.Background(), &state.SetRequest{
+		Key: key, Value: []byte(value), ETag: etag, Options: state.SetStateOption{Concurrency: concurrency},
+	})
+}
+
+func TestETag(t *testing.T) {
+	s := New(nil)
+	if err := set(s, "k", "v1", nil, ""); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	first := get(t, s, "k").ETag
+	if first == nil {
+		t.Fatal("expected an etag")
+	}
+	if err := set(s, "k", "v2", first, ""); err != nil {
+		t.Fatalf("expected the write with the current etag to succeed: %v", err)
+	}
+	second := get(t, s, "k").ETag
+	if *second == *first {
+		t.Fatalf("expected a new etag, got %s again", *first)
+	}
+	if err := set(s, "k", "v3", first, ""); !errors.Is(err, state.ErrETagMismatch) {
+		t.Fatalf("expected a mismatch with a stale etag, got %v", err)
+	}
+	invalid := "abc"
+	if err := set(s, "k", "v3", &invalid, ""); !errors.Is(err, state.ErrETagInvalid) {
+		t.Fatalf("expected an invalid etag, got %v", err)
+	}
+	if err := s.Delete(context.Background(), &state.DeleteRequest{Key: "k", ETag: first}); !errors.Is(err, state.ErrETagMismatch) {
+		t.Fatalf("expected the delete with a stale etag to fail, got %v", err)
+	}
+
+	// A key deleted then created again doesn't get its old etag back.
+	if err := s.Delete(context.Background(), &state.DeleteRequest{Key: "k", ETag: second}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if res := get(t, s, "k"); res.Data != nil || res.ETag != nil {
+		t.Fatalf("expected the key to be deleted, got %+v", res)
+	}
+	if err := set(s, "k", "v4", second, ""); !errors.Is(err, state.ErrETagMismatch) {
+		t.Fatalf("expected a mismatch on a deleted key, got %v", err)
+	}
+	set(s, "k", "v4", nil, "")
+	if third := get(t, s, "k").ETag; *third == *first || *third == *second {
+		t.Fatalf("expected a new etag, got %s", *third)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	s := New(nil)
+	if err := set(s, "k", "v1", nil, state.FirstWrite); err != nil {
+		t.Fatalf("expected first-write to create the key: %v", err)
+	}
+	if err := set(s, "k", "v2", nil, state.FirstWrite); !errors.Is(err, state.ErrETagMismatch) {
+		t.Fatalf("expected first-write without an etag to fail on an existing key, got %v", err)
+	}
+	stale := "999"
+	if err := set(s, "k", "v2", &stale, state.LastWrite); err != nil {
+		t.Fatalf("expected last-write to ignore the etag: %v", err)
+	}
+	if err := set(s, "k", "v3", nil, ""); err != nil {
+		t.Fatalf("expected a write without an etag to succeed: %v", err)
+	}
+	if data := string(get(t, s, "k").Data); data != "v3" {
+		t.Fatalf("expected the last write to win, got %s", data)
+	}
+	if err := set(s, "k", "v4", nil, "sometimes"); err == nil {
+		t.Fatal("expected an unknown concurrency to be rejected")
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	var journaled [][]Change
+	s := New(func(changes []Change, sync bool) error {
+		journaled = append(journaled, changes)
+		return nil
+	})
+	set(s, "a", "1", nil, "")
+	etag := get(t, s, "a").ETag
+	stale := "999"
+
+	err := s.Multi(context.Background(), &state.TransactionalStateRequest{Operations: []state.TransactionalStateOperation{
+		{Operation: state.Upsert, Request: &state.SetRequest{Key: "b", Value: []byte("2")}},
+		{Operation: state.Delete, Request: &state.DeleteRequest{Key: "a", ETag: etag}},
+		{Operation: state.Upsert, Request: &state.SetRequest{Key: "c", Value: []byte("3"), ETag: &stale}},
+	}})
+	if !errors.Is(err, state.ErrETagMismatch) {
+		t.Fatalf("expected the transaction to fail on its last operation, got %v", err)
+	}
+	if res := get(t, s, "b"); res.Data != nil {
+		t.Fatalf("expected the first operation to be rolled back, got %s", res.Data)
+	}
+	if res := get(t, s, "a"); string(res.Data) != "1" || *res.ETag != *etag {
+		t.Fatalf("expected the deleted key to be kept, got %+v", res)
+	}
+	if len(journaled) != 1 {
+		t.Fatalf("expected the failed transaction not to be journaled, got %d transactions", len(journaled))
+	}
+
+	// The operations see the changes of the previous ones.
+	err = s.Multi(context.Background(), &state.TransactionalStateRequest{Operations: []state.TransactionalStateOperation{
+		{Operation: state.Delete, Request: &state.DeleteRequest{Key: "a"}},
+		{Operation: state.Upsert, Request: &state.SetRequest{Key: "a", Value: []byte("new"), Options: state.SetStateOption{Concurrency: state.FirstWrite}}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to run the transaction: %v", err)
+	}
+	if data := string(get(t, s, "a").Data); data != "new" {
+		t.Fatalf("expected the key to be created again, got %s", data)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	s := New(nil)
+	err := s.Set(context.Background(), &state.SetRequest{Key: "k", Value: []byte("v"), Metadata: map[string]string{ttlKey: "60"}})
+	if err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	res := get(t, s, "k")
+	expires, err := time.Parse(time.RFC3339, res.Metadata[ttlExpireTimeKey])
+	if err != nil || time.Until(expires) > time.Minute || time.Until(expires) < 58*time.Second {
+		t.Fatalf("expected the key to expire in a minute, got %q", res.Metadata[ttlExpireTimeKey])
+	}
+
+	s.items["k"].expires = time.Now().Add(-time.Second)
+	if res := get(t, s, "k"); res.Data != nil {
+		t.Fatalf("expected the expired key to be missing, got %s", res.Data)
+	}
+	if err := set(s, "k", "v2", nil, state.FirstWrite); err != nil {
+		t.Fatalf("expected first-write to create the expired key again: %v", err)
+	}
+	if items, _ := s.Scan(context.Background()); len(items) != 1 {
+		t.Fatalf("expected one live key, got %d", len(items))
+	}
+
+	s.Set(context.Background(), &state.SetRequest{Key: "k", Value: []byte("v3"), Metadata: map[string]string{ttlKey: "0"}})
+	if res := get(t, s, "k"); res.Metadata != nil {
+		t.Fatalf("expected no expiry with a ttl of 0, got %v", res.Metadata)
+	}
+	if err := s.Set(context.Background(), &state.SetRequest{Key: "k", Metadata: map[string]string{ttlKey: "soon"}}); err == nil {
+		t.Fatal("expected an invalid ttl to be rejected")
+	}
+}
+
+func TestSnapshotKeepsSeq(t *testing.T) {
+	s := New(nil)
+	set(s, "a", "1", nil, "")
+	set(s, "b", "2", nil, "")
+	etag := get(t, s, "b").ETag
+	s.Delete(context.Background(), &state.DeleteRequest{Key: "b"})
+
+	restored := New(nil)
+	restored.Restore(s.Snapshot())
+	set(restored, "b", "3", nil, "")
+	if *get(t, restored, "b").ETag == *etag {
+		t.Fatalf("expected the etag %s of the deleted key not to be reused", *etag)
+	}
+}
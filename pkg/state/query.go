@@ -0,0 +1,128 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"group.rxcloud/capa/pkg/state/query"
+)
+
+// ErrQueryNotSupported is returned by the stores that can neither query natively nor
+// list their states.
+var ErrQueryNotSupported = errors.New("state store doesn't support queries")
+
+// QueryRequest is the request to query the states of a store.
+type QueryRequest struct {
+	Query    *query.Query
+	Metadata map[string]string
+}
+
+// QueryResponse is a page of the results of a query. Its token reads the next page, empty
+// for the last one.
+type QueryResponse struct {
+	Results  []QueryItem
+	Token    string
+	Metadata map[string]string
+}
+
+// QueryItem is a state of the results of a query.
+type QueryItem struct {
+	Key   string
+	Data  []byte
+	ETag  *string
+	Error string
+}
+
+// Querier is a store executing the queries natively. The store opts in with FeatureQueryAPI.
+type Querier interface {
+	Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error)
+}
+
+// Scanner is a store listing its states, so that the queries are evaluated in memory.
+type Scanner interface {
+	Scan(ctx context.Context) ([]QueryItem, error)
+}
+
+// Query executes the query natively when the store supports it, and evaluates it over
+// the states of the store otherwise.
+func Query(ctx context.Context, store Store, req *QueryRequest) (*QueryResponse, error) {
+	if querier, ok := store.(Querier); ok && FeatureQueryAPI.IsPresent(store.Features()) {
+		return querier.Query(ctx, req)
+	}
+	scanner, ok := store.(Scanner)
+	if !ok {
+		return nil, ErrQueryNotSupported
+	}
+	items, err := scanner.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateQuery(req.Query, items)
+}
+
+// EvaluateQuery evaluates the query over the states in memory. The states whose data is
+// not json don't match any filter. The results are sorted by key after the sort of the
+// query, and the page token is the offset of the next page.
+func EvaluateQuery(q *query.Query, items []QueryItem) (*QueryResponse, error) {
+	offset := 0
+	if q.Page.Token != "" {
+		var err error
+		offset, err = strconv.Atoi(q.Page.Token)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w: invalid page token %q", query.ErrInvalidQuery, q.Page.Token)
+		}
+	}
+
+	type result struct {
+		item  QueryItem
+		value interface{}
+	}
+	var results []result
+	for _, item := range items {
+		var value interface{}
+		valid := json.Unmarshal(item.Data, &value) == nil
+		if q.Filter != nil && (!valid || !q.Filter.Match(value)) {
+			continue
+		}
+		results = append(results, result{item: item, value: value})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		for _, sorting := range q.Sort {
+			// The states missing the key sort last, whatever the order.
+			a, aok := query.Lookup(results[i].value, sorting.Key)
+			b, bok := query.Lookup(results[j].value, sorting.Key)
+			if aok != bok {
+				return aok
+			}
+			c := query.Compare(a, b)
+			if sorting.Order == query.DESC {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return results[i].item.Key < results[j].item.Key
+	})
+
+	if offset > len(results) {
+		offset = len(results)
+	}
+	end := len(results)
+	if q.Page.Limit > 0 && offset+q.Page.Limit < end {
+		end = offset + q.Page.Limit
+	}
+	res := &QueryResponse{Results: make([]QueryItem, 0, end-offset)}
+	for _, r := range results[offset:end] {
+		res.Results = append(res.Results, r.item)
+	}
+	if end < len(results) {
+		res.Token = strconv.Itoa(end)
+	}
+	return res, nil
+}
@@ -0,0 +1,145 @@
+package query
+
+import "fmt"
+
+// Filter is a condition on the json value of a state.
+type Filter interface {
+	// Match tells whether the decoded json value matches the filter.
+	Match(value interface{}) bool
+}
+
+// EQ matches the values whose key equals the value.
+type EQ struct {
+	Key string
+	Val interface{}
+}
+
+// IN matches the values whose key equals one of the values.
+type IN struct {
+	Key  string
+	Vals []interface{}
+}
+
+// AND matches the values matching all of its filters.
+type AND struct {
+	Filters []Filter
+}
+
+// OR matches the values matching any of its filters.
+type OR struct {
+	Filters []Filter
+}
+
+func (f *EQ) Match(value interface{}) bool {
+	v, ok := Lookup(value, f.Key)
+	return ok && Compare(v, f.Val) == 0
+}
+
+func (f *IN) Match(value interface{}) bool {
+	v, ok := Lookup(value, f.Key)
+	if !ok {
+		return false
+	}
+	for _, val := range f.Vals {
+		if Compare(v, val) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *AND) Match(value interface{}) bool {
+	for _, filter := range f.Filters {
+		if !filter.Match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *OR) Match(value interface{}) bool {
+	for _, filter := range f.Filters {
+		if filter.Match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilter parses a filter, an object with a single operator.
+func parseFilter(obj interface{}) (Filter, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("a filter must be an object with a single operator")
+	}
+	for op, arg := range m {
+		switch op {
+		case "EQ":
+			key, val, err := parseCondition(op, arg)
+			if err != nil {
+				return nil, err
+			}
+			return &EQ{Key: key, Val: val}, nil
+		case "IN":
+			key, val, err := parseCondition(op, arg)
+			if err != nil {
+				return nil, err
+			}
+			vals, ok := val.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("IN filter value must be an array")
+			}
+			return &IN{Key: key, Vals: vals}, nil
+		case "AND":
+			filters, err := parseFilters(op, arg)
+			if err != nil {
+				return nil, err
+			}
+			return &AND{Filters: filters}, nil
+		case "OR":
+			filters, err := parseFilters(op, arg)
+			if err != nil {
+				return nil, err
+			}
+			return &OR{Filters: filters}, nil
+		default:
+			return nil, fmt.Errorf("unsupported filter %q", op)
+		}
+	}
+	return nil, nil
+}
+
+// parseCondition parses the single key and value of an EQ or IN filter.
+func parseCondition(op string, arg interface{}) (string, interface{}, error) {
+	m, ok := arg.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("%s filter must be an object with a single key", op)
+	}
+	for key, val := range m {
+		if key == "" {
+			return "", nil, fmt.Errorf("%s filter key is required", op)
+		}
+		return key, val, nil
+	}
+	return "", nil, nil
+}
+
+// parseFilters parses the filters of an AND or OR filter.
+func parseFilters(op string, arg interface{}) ([]Filter, error) {
+	arr, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s filter must be an array", op)
+	}
+	if len(arr) < 2 {
+		return nil, fmt.Errorf("%s filter must contain at least two entries", op)
+	}
+	filters := make([]Filter, len(arr))
+	for i, entry := range arr {
+		filter, err := parseFilter(entry)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+	return filters, nil
+}
@@ -0,0 +1,85 @@
+// Package query is the query language of the state stores. A query is a json document
+// with an optional filter, sort and page:
+//
+//	{
+//	  "filter": {"AND": [{"EQ": {"person.org": "A"}}, {"IN": {"state": ["CA", "WA"]}}]},
+//	  "sort": [{"key": "person.id", "order": "DESC"}],
+//	  "page": {"limit": 10, "token": "..."}
+//	}
+//
+// The keys are dotted paths into the json values of the states.
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The sort orders.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// ErrInvalidQuery is returned for a malformed query, or a page token the store didn't return.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query is a parsed query.
+type Query struct {
+	// Filter selects the states, all of them when nil.
+	Filter Filter
+	Sort   []Sorting
+	Page   Pagination
+}
+
+// Sorting sorts the results by the value of the key, in ASC order by default.
+type Sorting struct {
+	Key   string `json:"key"`
+	Order string `json:"order,omitempty"`
+}
+
+// Pagination limits the number of results, all of them when 0. The token is the one of
+// the previous page, empty for the first page.
+type Pagination struct {
+	Limit int    `json:"limit"`
+	Token string `json:"token,omitempty"`
+}
+
+type document struct {
+	Filter json.RawMessage `json:"filter"`
+	Sort   []Sorting       `json:"sort"`
+	Page   Pagination      `json:"page"`
+}
+
+// Parse parses and checks a query.
+func Parse(data []byte) (*Query, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	q := &Query{Sort: doc.Sort, Page: doc.Page}
+	if len(doc.Filter) > 0 && string(doc.Filter) != "null" {
+		var obj interface{}
+		if err := json.Unmarshal(doc.Filter, &obj); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		filter, err := parseFilter(obj)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		q.Filter = filter
+	}
+	for _, sorting := range q.Sort {
+		if sorting.Key == "" {
+			return nil, fmt.Errorf("%w: sort key is required", ErrInvalidQuery)
+		}
+		if sorting.Order != "" && sorting.Order != ASC && sorting.Order != DESC {
+			return nil, fmt.Errorf("%w: invalid sort order %q", ErrInvalidQuery, sorting.Order)
+		}
+	}
+	if q.Page.Limit < 0 {
+		return nil, fmt.Errorf("%w: page limit must not be negative", ErrInvalidQuery)
+	}
+	return q, nil
+}
@@ -0,0 +1,91 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func decode(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestFilters(t *testing.T) {
+	alice := decode(`{"person": {"org": "A", "id": 1}, "state": "CA", "tags": ["x"]}`)
+	bob := decode(`{"person": {"org": "B", "id": 2}, "state": "WA"}`)
+	tests := []struct {
+		filter string
+		alice  bool
+		bob    bool
+	}{
+		{`{"EQ": {"person.org": "A"}}`, true, false},
+		{`{"EQ": {"person.id": 2}}`, false, true},
+		{`{"EQ": {"person.id": "2"}}`, false, false},
+		{`{"EQ": {"tags": ["x"]}}`, true, false},
+		{`{"EQ": {"person.missing": null}}`, false, false},
+		{`{"IN": {"state": ["CA", "NY"]}}`, true, false},
+		{`{"IN": {"state": []}}`, false, false},
+		{`{"AND": [{"EQ": {"person.org": "A"}}, {"IN": {"state": ["CA", "WA"]}}]}`, true, false},
+		{`{"AND": [{"EQ": {"person.org": "B"}}, {"EQ": {"state": "CA"}}]}`, false, false},
+		{`{"OR": [{"EQ": {"person.org": "B"}}, {"EQ": {"state": "CA"}}]}`, true, true},
+		{`{"OR": [{"AND": [{"EQ": {"person.id": 1}}, {"EQ": {"state": "WA"}}]}, {"EQ": {"person.id": 2}}]}`, false, true},
+	}
+	for _, test := range tests {
+		q, err := Parse([]byte(`{"filter": ` + test.filter + `}`))
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", test.filter, err)
+		}
+		if got := q.Filter.Match(alice); got != test.alice {
+			t.Errorf("%s: expected %v for alice, got %v", test.filter, test.alice, got)
+		}
+		if got := q.Filter.Match(bob); got != test.bob {
+			t.Errorf("%s: expected %v for bob, got %v", test.filter, test.bob, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`not json`,
+		`{"filter": "EQ"}`,
+		`{"filter": {}}`,
+		`{"filter": {"EQ": {"a": 1}, "IN": {"b": [1]}}}`,
+		`{"filter": {"LT": {"a": 1}}}`,
+		`{"filter": {"EQ": {"a": 1, "b": 2}}}`,
+		`{"filter": {"EQ": {"": 1}}}`,
+		`{"filter": {"IN": {"a": 1}}}`,
+		`{"filter": {"AND": [{"EQ": {"a": 1}}]}}`,
+		`{"filter": {"OR": {"EQ": {"a": 1}}}}`,
+		`{"sort": [{"order": "ASC"}]}`,
+		`{"sort": [{"key": "a", "order": "UP"}]}`,
+		`{"page": {"limit": -1}}`,
+	} {
+		if _, err := Parse([]byte(doc)); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("expected %s to be an invalid query, got %v", doc, err)
+		}
+	}
+
+	q, err := Parse([]byte(`{"filter": null, "sort": [{"key": "a", "order": "DESC"}], "page": {"limit": 5, "token": "10"}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if q.Filter != nil || len(q.Sort) != 1 || q.Sort[0].Order != DESC || q.Page.Limit != 5 || q.Page.Token != "10" {
+		t.Fatalf("unexpected query %+v", q)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{`null`, `false`, `true`, `-1`, `2.5`, `10`, `""`, `"a"`, `"b"`, `[]`, `[1]`, `[1, 2]`, `[2]`, `{"a": 1}`, `{"a": 2}`}
+	for i := range ordered {
+		for j := range ordered {
+			expected := sign(i - j)
+			if c := Compare(decode(ordered[i]), decode(ordered[j])); c != expected {
+				t.Errorf("compare(%s, %s): expected %d, got %d", ordered[i], ordered[j], expected, c)
+			}
+		}
+	}
+}
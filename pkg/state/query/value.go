@@ -0,0 +1,89 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Lookup returns the value of the dotted key in the decoded json value.
+func Lookup(value interface{}, key string) (interface{}, bool) {
+	for _, field := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// rank orders the json types: null, booleans, numbers, strings, arrays, then objects.
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	}
+	return 5
+}
+
+// Compare compares two decoded json values, returning -1, 0 or 1. The values of
+// different types are ordered by type.
+func Compare(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return sign(ra - rb)
+	}
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(a) - len(b))
+	}
+	// The objects compare by their json encoding, whose keys are sorted.
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return strings.Compare(string(ja), string(jb))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
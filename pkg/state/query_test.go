@@ -0,0 +1,102 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"group.rxcloud/capa/pkg/state/query"
+)
+
+func keys(res *QueryResponse) []string {
+	var keys []string
+	for _, item := range res.Results {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var queryItems = []QueryItem{
+	{Key: "e", Data: []byte(`{"org": "B", "id": 5}`)},
+	{Key: "a", Data: []byte(`{"org": "A", "id": 3}`)},
+	{Key: "c", Data: []byte(`{"org": "A", "id": 1}`)},
+	{Key: "d", Data: []byte(`{"org": "B"}`)},
+	{Key: "b", Data: []byte(`{"org": "A", "id": 3}`)},
+	{Key: "f", Data: []byte(`not json`)},
+}
+
+func evaluate(t *testing.T, doc string) *QueryResponse {
+	t.Helper()
+	q, err := query.Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", doc, err)
+	}
+	res, err := EvaluateQuery(q, queryItems)
+	if err != nil {
+		t.Fatalf("failed to evaluate %s: %v", doc, err)
+	}
+	return res
+}
+
+func TestEvaluateQuerySort(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected []string
+	}{
+		// Without a sort, the results are sorted by key, the invalid json included.
+		{`{}`, []string{"a", "b", "c", "d", "e", "f"}},
+		// The states whose data is not json don't match any filter.
+		{`{"filter": {"EQ": {"org": "A"}}}`, []string{"a", "b", "c"}},
+		// The ties are sorted by key, the states missing the sort key last.
+		{`{"sort": [{"key": "id"}]}`, []string{"c", "a", "b", "e", "d", "f"}},
+		{`{"sort": [{"key": "id", "order": "DESC"}]}`, []string{"e", "a", "b", "c", "d", "f"}},
+		{`{"filter": {"OR": [{"EQ": {"org": "B"}}, {"EQ": {"id": 1}}]}, "sort": [{"key": "org", "order": "DESC"}, {"key": "id"}]}`, []string{"e", "d", "c"}},
+	}
+	for _, test := range tests {
+		if got := keys(evaluate(t, test.doc)); !equal(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.doc, test.expected, got)
+		}
+	}
+}
+
+func TestEvaluateQueryPages(t *testing.T) {
+	var pages [][]string
+	token := ""
+	for i := 0; i < 10; i++ {
+		res := evaluate(t, `{"sort": [{"key": "id"}], "page": {"limit": 2, "token": "`+token+`"}}`)
+		pages = append(pages, keys(res))
+		if token = res.Token; token == "" {
+			break
+		}
+	}
+	expected := [][]string{{"c", "a"}, {"b", "e"}, {"d", "f"}}
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %d pages, got %v", len(expected), pages)
+	}
+	for i := range expected {
+		if !equal(pages[i], expected[i]) {
+			t.Fatalf("page %d: expected %v, got %v", i, expected[i], pages[i])
+		}
+	}
+
+	if res := evaluate(t, `{"page": {"token": "100"}}`); len(res.Results) != 0 || res.Token != "" {
+		t.Fatalf("expected an empty last page past the end, got %+v", res)
+	}
+	for _, token := range []string{"abc", "-1"} {
+		q, _ := query.Parse([]byte(`{"page": {"token": "` + token + `"}}`))
+		if _, err := EvaluateQuery(q, queryItems); !errors.Is(err, query.ErrInvalidQuery) {
+			t.Errorf("expected the token %q to be rejected, got %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package state
+
+import "fmt"
+
+// stores are the factories of the state stores, by component type.
+var stores = map[string]func() Store{}
+
+// RegisterStore registers the factory of the state stores of the component type,
+// like "state.in-memory".
+func RegisterStore(componentType string, factory func() Store) {
+	stores[componentType] = factory
+}
+
+// NewStore returns a new state store of the component type.
+func NewStore(componentType string) (Store, error) {
+	factory, ok := stores[componentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown state store type %q", componentType)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,114 @@
+package state
+
+import "fmt"
+
+// GetRequest is the request to get the state of a key.
+type GetRequest struct {
+	Key      string
+	Metadata map[string]string
+	Options  GetStateOption
+}
+
+// GetStateOption controls how a state store reads.
+type GetStateOption struct {
+	// Consistency is strong or eventual.
+	Consistency string
+}
+
+// GetResponse is the state of a key, empty when the key is missing.
+type GetResponse struct {
+	Data     []byte
+	ETag     *string
+	Metadata map[string]string
+}
+
+// SetRequest is the request to save the state of a key.
+type SetRequest struct {
+	Key      string
+	Value    []byte
+	ETag     *string
+	Metadata map[string]string
+	Options  SetStateOption
+}
+
+// SetStateOption controls how a state store writes.
+type SetStateOption struct {
+	// Concurrency is first-write or last-write.
+	Concurrency string
+	// Consistency is strong or eventual.
+	Consistency string
+}
+
+// DeleteRequest is the request to delete the state of a key.
+type DeleteRequest struct {
+	Key      string
+	ETag     *string
+	Metadata map[string]string
+	Options  DeleteStateOption
+}
+
+// DeleteStateOption controls how a state store deletes.
+type DeleteStateOption struct {
+	// Concurrency is first-write or last-write.
+	Concurrency string
+	// Consistency is strong or eventual.
+	Consistency string
+}
+
+// OperationType is an operation of a transaction.
+type OperationType string
+
+const (
+	// Upsert creates or updates a key.
+	Upsert OperationType = "upsert"
+	// Delete deletes a key.
+	Delete OperationType = "delete"
+)
+
+// TransactionalStateRequest is a transaction of operations executed atomically.
+type TransactionalStateRequest struct {
+	Operations []TransactionalStateOperation
+	Metadata   map[string]string
+}
+
+// TransactionalStateOperation is an operation of a transaction. Its request is a *SetRequest
+// for an upsert, and a *DeleteRequest for a delete.
+type TransactionalStateOperation struct {
+	Operation OperationType
+	Request   interface{}
+}
+
+// CheckRequestOptions checks the concurrency and the consistency of the options of a request.
+func CheckRequestOptions(options interface{}) error {
+	switch o := options.(type) {
+	case GetStateOption:
+		return validateConsistency(o.Consistency)
+	case SetStateOption:
+		if err := validateConcurrency(o.Concurrency); err != nil {
+			return err
+		}
+		return validateConsistency(o.Consistency)
+	case DeleteStateOption:
+		if err := validateConcurrency(o.Concurrency); err != nil {
+			return err
+		}
+		return validateConsistency(o.Consistency)
+	}
+	return nil
+}
+
+func validateConcurrency(concurrency string) error {
+	switch concurrency {
+	case "", FirstWrite, LastWrite:
+		return nil
+	}
+	return fmt.Errorf("unrecognized concurrency model %q", concurrency)
+}
+
+func validateConsistency(consistency string) error {
+	switch consistency {
+	case "", Strong, Eventual:
+		return nil
+	}
+	return fmt.Errorf("unrecognized consistency model %q", consistency)
+}
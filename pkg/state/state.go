@@ -0,0 +1,76 @@
+// Package state is the state management building block: the apps save and read their
+// key/value state in the state store components of the runtime.
+package state
+
+import (
+	"context"
+	"errors"
+
+	"group.rxcloud/capa/pkg/components"
+)
+
+// Feature is an optional feature of a state store.
+type Feature string
+
+const (
+	// FeatureETag is the feature of the stores checking the ETags of the writes.
+	FeatureETag Feature = "ETAG"
+	// FeatureTransactional is the feature of the stores executing transactions.
+	FeatureTransactional Feature = "TRANSACTIONAL"
+	// FeatureQueryAPI is the feature of the stores executing the queries natively.
+	FeatureQueryAPI Feature = "QUERY_API"
+)
+
+// The concurrency of the writes.
+const (
+	// FirstWrite checks the ETag of the writes, and a write without an ETag only creates the key.
+	FirstWrite = "first-write"
+	// LastWrite ignores the ETag of the writes, the last one wins.
+	LastWrite = "last-write"
+)
+
+// The consistency of the operations.
+const (
+	Strong   = "strong"
+	Eventual = "eventual"
+)
+
+var (
+	// ErrETagMismatch is returned when the ETag of a write doesn't match the stored one.
+	ErrETagMismatch = errors.New("possible etag mismatch")
+	// ErrETagInvalid is returned when the ETag of a write is malformed.
+	ErrETagInvalid = errors.New("invalid etag value")
+)
+
+// Metadata is the metadata of a state store component, from its config.
+type Metadata = components.Metadata
+
+// Store is a state store component. Reading a missing key returns an empty response.
+// Without a concurrency, the writes with an ETag check it.
+type Store interface {
+	// Init applies the metadata of the component. It must not have side effects, so
+	// that the config can be checked by creating its components.
+	Init(metadata Metadata) error
+	// Open opens the store, like its files, before its first use.
+	Open() error
+	Close() error
+	Features() []Feature
+	Get(ctx context.Context, req *GetRequest) (*GetResponse, error)
+	Set(ctx context.Context, req *SetRequest) error
+	Delete(ctx context.Context, req *DeleteRequest) error
+}
+
+// TransactionalStore is a store executing the operations of a transaction atomically.
+type TransactionalStore interface {
+	Multi(ctx context.Context, req *TransactionalStateRequest) error
+}
+
+// IsPresent tells whether the feature is one of the features.
+func (f Feature) IsPresent(features []Feature) bool {
+	for _, feature := range features {
+		if feature == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// Package version holds the build metadata of capa. It is injected at link time:
+//
+//	go build -ldflags "\
+//	  -X group.rxcloud/capa/pkg/version.version=v0.1.0 \
+//	  -X group.rxcloud/capa/pkg/version.gitCommit=$(git rev-parse HEAD) \
+//	  -X group.rxcloud/capa/pkg/version.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/capa
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set by the linker.
+var (
+	version   = "dev"
+	gitCommit = ""
+	buildDate = ""
+)
+
+// Info is the build metadata of capa.
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Version returns the version of capa, "dev" when it is not set at link time.
+func Version() string {
+	return version
+}
+
+// Get returns the build metadata. The git commit and the build date fall back to the
+// version control info stamped by the go tool, when not set at link time.
+func Get() Info {
+	info := Info{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitCommit == "":
+				info.GitCommit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("capa version %s, git commit %s, built %s with %s for %s",
+		i.Version, i.GitCommit, i.BuildDate, i.GoVersion, i.Platform)
+}
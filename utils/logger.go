@@ -4,10 +4,15 @@ import log "github.com/sirupsen/logrus"
 
 func init() {
 	log.SetLevel(log.InfoLevel)
-	log.SetFormatter(&log.JSONFormatter{
+	log.SetFormatter(NewJSONFormatter())
+}
+
+// NewJSONFormatter returns the formatter of the Capa logs, shared by the access log.
+func NewJSONFormatter() *log.JSONFormatter {
+	return &log.JSONFormatter{
 		FieldMap: log.FieldMap{
 			log.FieldKeyTime:  "timestamp",
 			log.FieldKeyLevel: "level",
 			log.FieldKeyMsg:   "message",
-		}})
+		}}
 }
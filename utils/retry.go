@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is the number of retries of a retrier without retry policy.
+	DefaultRetryAttempts = 3
+	// DefaultRetryBackoff is the first backoff of a retrier whose policy sets none.
+	DefaultRetryBackoff = 500 * time.Millisecond
+)
+
+// RetryPolicy retries a failed call with an exponential backoff.
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first try.
+	Attempts   int      `json:"attempts"`
+	Backoff    Duration `json:"backoff,omitempty"`
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+}
+
+// Backoff is an exponential backoff, doubling from Base at each retry up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// NewBackoff returns the backoff of the config durations. The base defaults to
+// defaultBase, and the max to 10 times the base.
+func NewBackoff(base Duration, max Duration, defaultBase time.Duration) (Backoff, error) {
+	b := Backoff{Base: time.Duration(base), Max: time.Duration(max)}
+	if b.Base <= 0 {
+		b.Base = defaultBase
+	}
+	if b.Max <= 0 {
+		b.Max = 10 * b.Base
+	}
+	if b.Max < b.Base {
+		return Backoff{}, fmt.Errorf("max_backoff %s is less than backoff %s", b.Max, b.Base)
+	}
+	return b, nil
+}
+
+// Of returns the backoff before the given retry, starting from 1.
+func (b Backoff) Of(retry int) time.Duration {
+	backoff := b.Base << uint(retry-1)
+	if backoff <= 0 || backoff > b.Max {
+		return b.Max
+	}
+	return backoff
+}
+
+// Retrier calls a function again, after a backoff, until it succeeds or the attempts
+// of its retry policy are exhausted.
+type Retrier struct {
+	attempts int
+	backoff  Backoff
+}
+
+// NewRetrier returns the retrier of the retry policy. A nil policy retries
+// DefaultRetryAttempts times, and the backoff defaults to DefaultRetryBackoff.
+func NewRetrier(rp *RetryPolicy) (*Retrier, error) {
+	if rp == nil {
+		rp = &RetryPolicy{Attempts: DefaultRetryAttempts}
+	}
+	if rp.Attempts < 0 {
+		return nil, fmt.Errorf("negative retry attempts %d", rp.Attempts)
+	}
+	backoff, err := NewBackoff(rp.Backoff, rp.MaxBackoff, DefaultRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	return &Retrier{attempts: rp.Attempts, backoff: backoff}, nil
+}
+
+// Do calls fn until it returns nil, an error that isn't retryable, or the retries are
+// exhausted, and returns the number of calls with the last error. onRetry is called
+// before each backoff. The error of the context is returned once it is done.
+func (r *Retrier) Do(ctx context.Context, fn func() error, retryable func(err error) bool,
+	onRetry func(retry int, backoff time.Duration, err error)) (int, error) {
+	for calls := 1; ; calls++ {
+		err := fn()
+		if err == nil {
+			return calls, nil
+		}
+		if ctx.Err() != nil {
+			return calls, ctx.Err()
+		}
+		if calls > r.attempts || !retryable(err) {
+			return calls, err
+		}
+		backoff := r.backoff.Of(calls)
+		onRetry(calls, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return calls, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b, err := NewBackoff(Duration(100*time.Millisecond), Duration(time.Second), DefaultRetryBackoff)
+	if err != nil {
+		t.Fatalf("failed to create the backoff: %v", err)
+	}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, backoff := range expected {
+		if got := b.Of(i + 1); got != backoff {
+			t.Errorf("retry %d: expected %s, got %s", i+1, backoff, got)
+		}
+	}
+	// The shift overflows long before the last retry.
+	if got := b.Of(100); got != time.Second {
+		t.Errorf("expected the max backoff for an overflowing retry, got %s", got)
+	}
+
+	b, _ = NewBackoff(0, 0, DefaultRetryBackoff)
+	if b.Base != DefaultRetryBackoff || b.Max != 10*DefaultRetryBackoff {
+		t.Fatalf("unexpected defaults %+v", b)
+	}
+	if _, err := NewBackoff(Duration(time.Second), Duration(time.Millisecond), DefaultRetryBackoff); err == nil {
+		t.Fatalf("expected a max backoff less than the backoff to be rejected")
+	}
+}
+
+func TestNewRetrier(t *testing.T) {
+	r, err := NewRetrier(nil)
+	if err != nil || r.attempts != DefaultRetryAttempts || r.backoff.Base != DefaultRetryBackoff {
+		t.Fatalf("unexpected defaults %+v: %v", r, err)
+	}
+	if _, err := NewRetrier(&RetryPolicy{Attempts: -1}); err == nil {
+		t.Fatalf("expected negative attempts to be rejected")
+	}
+}
+
+func TestRetrierDo(t *testing.T) {
+	r, _ := NewRetrier(&RetryPolicy{Attempts: 2, Backoff: Duration(time.Millisecond)})
+	failure := errors.New("failure")
+	always := func(err error) bool { return true }
+
+	cases := []struct {
+		name      string
+		errs      []error
+		retryable func(err error) bool
+		calls     int
+		err       error
+	}{
+		{name: "success", errs: []error{nil}, retryable: always, calls: 1},
+		{name: "retried", errs: []error{failure, failure, nil}, retryable: always, calls: 3},
+		{name: "exhausted", errs: []error{failure, failure, failure}, retryable: always, calls: 3, err: failure},
+		{name: "not retryable", errs: []error{failure}, retryable: func(err error) bool { return false }, calls: 1, err: failure},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls, retries int
+			n, err := r.Do(context.Background(), func() error {
+				calls++
+				return c.errs[calls-1]
+			}, c.retryable, func(retry int, backoff time.Duration, err error) {
+				retries++
+				if retry != retries || backoff != r.backoff.Of(retry) {
+					t.Errorf("unexpected retry %d after %s", retry, backoff)
+				}
+			})
+			if n != c.calls || calls != c.calls || retries != c.calls-1 || err != c.err {
+				t.Fatalf("expected %d calls and %v, got %d calls, %d retries and %v", c.calls, c.err, n, retries, err)
+			}
+		})
+	}
+}
+
+func TestRetrierDoStopped(t *testing.T) {
+	r, _ := NewRetrier(&RetryPolicy{Attempts: 3, Backoff: Duration(time.Hour)})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls, err := r.Do(ctx, func() error { return errors.New("failure") },
+		func(err error) bool { return true },
+		func(retry int, backoff time.Duration, err error) {})
+	if calls != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the error of the context during the backoff, got %d calls: %v", calls, err)
+	}
+}